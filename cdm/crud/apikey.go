@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/grip211/crud/pkg/apikey"
+	"github.com/grip211/crud/pkg/auth"
+	"github.com/grip211/crud/pkg/tenant"
+)
+
+// тут команды управления API ключами: crud apikey create|list|revoke
+
+func apiKeyCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "apikey",
+		Usage: "manage API keys for /api/v1",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "create",
+				Usage: "issue a new API key, the key is printed only once",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "name",
+						Usage:    "who or what the key is for",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "scope",
+						Usage: "read, write or admin",
+						Value: auth.ScopeRead,
+					},
+					&cli.StringFlag{
+						Name:  "tenant",
+						Usage: "tenant the key belongs to",
+						Value: tenant.Default,
+					},
+					&cli.StringFlag{
+						Name:  "role",
+						Usage: "viewer, editor, pricing or admin, defaults to the role of the scope",
+					},
+					&cli.DurationFlag{
+						Name:  "expires",
+						Usage: "key lifetime, e.g. 720h, never expires if not set",
+					},
+				},
+				Action: apiKeyCreate,
+			},
+			{
+				Name:   "list",
+				Usage:  "list API keys",
+				Action: apiKeyList,
+			},
+			{
+				Name:      "revoke",
+				Usage:     "revoke an API key",
+				ArgsUsage: "<id>",
+				Action:    apiKeyRevoke,
+			},
+		},
+	}
+}
+
+func apiKeyRepo(ctx *cli.Context) (*apikey.Repo, error) {
+	conn, err := openDatabase(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return apikey.NewRepo(conn), nil
+}
+
+func apiKeyCreate(ctx *cli.Context) error {
+	repo, err := apiKeyRepo(ctx)
+	if err != nil {
+		return err
+	}
+
+	var expiresAt *time.Time
+	if ttl := ctx.Duration("expires"); ttl > 0 {
+		at := time.Now().Add(ttl)
+		expiresAt = &at
+	}
+
+	plain, key, err := repo.Create(ctx.Context, ctx.String("tenant"), ctx.String("name"), ctx.String("scope"), ctx.String("role"), expiresAt)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("id:     %d\ntenant: %s\nscope:  %s\nrole:   %s\nkey:    %s\n",
+		key.ID, key.TenantID, key.Scope, key.Principal().EffectiveRole(), plain)
+	fmt.Println("store the key now, it can not be shown again")
+	return nil
+}
+
+func apiKeyList(ctx *cli.Context) error {
+	repo, err := apiKeyRepo(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys, err := repo.List(ctx.Context)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTENANT\tNAME\tPREFIX\tSCOPE\tROLE\tEXPIRES\tLAST USED\tREVOKED")
+	for i := range keys {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			keys[i].ID,
+			keys[i].TenantID,
+			keys[i].Name,
+			keys[i].Prefix,
+			keys[i].Scope,
+			keys[i].Principal().EffectiveRole(),
+			formatTime(keys[i].ExpiresAt),
+			formatTime(keys[i].LastUsedAt),
+			formatTime(keys[i].RevokedAt),
+		)
+	}
+	return w.Flush()
+}
+
+func apiKeyRevoke(ctx *cli.Context) error {
+	id, err := strconv.Atoi(ctx.Args().First())
+	if err != nil {
+		return errors.New("usage: crud apikey revoke <id>")
+	}
+
+	repo, err := apiKeyRepo(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err = repo.Revoke(ctx.Context, id); err != nil {
+		return err
+	}
+	fmt.Printf("api key %d revoked\n", id)
+	return nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
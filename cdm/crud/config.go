@@ -0,0 +1,585 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/grip211/crud/pkg/config"
+	"github.com/grip211/crud/pkg/database"
+	"github.com/grip211/crud/pkg/database/mysql"
+	"github.com/grip211/crud/pkg/database/postgres"
+	"github.com/grip211/crud/pkg/jwtauth"
+	"github.com/grip211/crud/pkg/limits"
+)
+
+// тут флаги конфигурации и команда crud config print. Флаг или переменная окружения
+// перекрывают значение из файла, если заданы явно
+
+func configFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "config",
+			Usage:   "path to the YAML config file",
+			EnvVars: []string{"CONFIG_FILE"},
+		},
+		&cli.StringFlag{
+			Name:    "db-dialect",
+			Usage:   "database dialect, mysql or postgres (default: mysql)",
+			EnvVars: []string{"DB_DIALECT"},
+		},
+		&cli.StringFlag{
+			Name:    "db-schema",
+			Usage:   "schema with the service tables: the database in MySQL, a schema inside db-name in PostgreSQL (default: productdb)",
+			EnvVars: []string{"DB_SCHEMA"},
+		},
+		&cli.StringFlag{
+			Name:    "db-dsn",
+			Usage:   "full database DSN, overrides the other connection settings",
+			EnvVars: []string{"DB_DSN"},
+		},
+		&cli.StringFlag{
+			Name:    "db-host",
+			Usage:   "database host (default: 127.0.0.1)",
+			EnvVars: []string{"DB_HOST", "DB_Host"},
+		},
+		&cli.StringFlag{
+			Name:    "db-port",
+			Usage:   "database port (default: 3306, or the port given in db-host)",
+			EnvVars: []string{"DB_PORT"},
+		},
+		&cli.StringFlag{
+			Name:    "db-user",
+			Usage:   "database user",
+			EnvVars: []string{"DB_USER"},
+		},
+		&cli.StringFlag{
+			Name:    "db-password",
+			Usage:   "database password",
+			EnvVars: []string{"DB_PASSWORD", "DB_PASS"},
+		},
+		&cli.StringFlag{
+			Name:    "db-name",
+			Usage:   "database name (default: productdb)",
+			EnvVars: []string{"DB_NAME"},
+		},
+		&cli.BoolFlag{
+			Name:    "db-debug",
+			Usage:   "log every SQL query, needs --log-level debug",
+			EnvVars: []string{"DB_DEBUG"},
+		},
+		&cli.DurationFlag{
+			Name:    "db-slow-query-threshold",
+			Usage:   "log and count queries slower than this, 0 to disable (default: 500ms)",
+			EnvVars: []string{"DB_SLOW_QUERY_THRESHOLD"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "db-replica",
+			Usage:   "read replica host[:port], repeat for several replicas; user, password and pool settings are the primary's",
+			EnvVars: []string{"DB_REPLICAS"},
+		},
+		&cli.DurationFlag{
+			Name:    "db-replica-check-interval",
+			Usage:   "how often replicas are pinged, a failed replica is skipped until it answers again (default: 5s)",
+			EnvVars: []string{"DB_REPLICA_CHECK_INTERVAL"},
+		},
+		&cli.IntFlag{
+			Name:    "db-max-idle-conns",
+			Usage:   "maximum number of idle database connections (default: 9)",
+			EnvVars: []string{"DB_MAX_IDLE_CONNS"},
+		},
+		&cli.IntFlag{
+			Name:    "db-max-open-conns",
+			Usage:   "maximum number of open database connections (default: 10)",
+			EnvVars: []string{"DB_MAX_OPEN_CONNS"},
+		},
+		&cli.DurationFlag{
+			Name:    "db-conn-max-lifetime",
+			Usage:   "maximum lifetime of a database connection (default: 5m)",
+			EnvVars: []string{"DB_CONN_MAX_LIFETIME"},
+		},
+		&cli.StringFlag{
+			Name:    "db-tls",
+			Usage:   "true, false, skip-verify or preferred",
+			EnvVars: []string{"DB_TLS"},
+		},
+		&cli.DurationFlag{
+			Name:    "db-timeout",
+			Usage:   "database dial timeout",
+			EnvVars: []string{"DB_TIMEOUT"},
+		},
+		&cli.DurationFlag{
+			Name:    "db-read-timeout",
+			Usage:   "database I/O read timeout",
+			EnvVars: []string{"DB_READ_TIMEOUT"},
+		},
+		&cli.DurationFlag{
+			Name:    "db-write-timeout",
+			Usage:   "database I/O write timeout",
+			EnvVars: []string{"DB_WRITE_TIMEOUT"},
+		},
+		&cli.StringFlag{
+			Name:    "db-charset",
+			Usage:   "connection charset, e.g. utf8mb4",
+			EnvVars: []string{"DB_CHARSET"},
+		},
+		&cli.StringFlag{
+			Name:    "db-collation",
+			Usage:   "connection collation, e.g. utf8mb4_unicode_ci",
+			EnvVars: []string{"DB_COLLATION"},
+		},
+		&cli.StringFlag{
+			Name:    "db-loc",
+			Usage:   "time zone of time values, e.g. UTC or Local",
+			EnvVars: []string{"DB_LOC"},
+		},
+		&cli.IntFlag{
+			Name:    "db-connect-attempts",
+			Usage:   "how many times to try to connect to the database at startup (default: 10)",
+			EnvVars: []string{"DB_CONNECT_ATTEMPTS"},
+		},
+		&cli.DurationFlag{
+			Name:    "db-connect-backoff",
+			Usage:   "delay before the second connect attempt, doubled for every next one (default: 500ms)",
+			EnvVars: []string{"DB_CONNECT_BACKOFF"},
+		},
+		&cli.DurationFlag{
+			Name:    "db-connect-max-backoff",
+			Usage:   "maximum delay between connect attempts (default: 10s)",
+			EnvVars: []string{"DB_CONNECT_MAX_BACKOFF"},
+		},
+		&cli.DurationFlag{
+			Name:    "db-ping-timeout",
+			Usage:   "timeout of one connect attempt (default: 5s)",
+			EnvVars: []string{"DB_PING_TIMEOUT"},
+		},
+		&cli.StringFlag{
+			Name:    "http-listener",
+			Usage:   "tcp or unix (default: tcp)",
+			EnvVars: []string{"HTTP_LISTENER"},
+		},
+		&cli.StringFlag{
+			Name:    "http-addr",
+			Usage:   "address of the tcp listener (default: :8181)",
+			EnvVars: []string{"HTTP_ADDR"},
+		},
+		&cli.StringFlag{
+			Name:    "http-socket",
+			Usage:   "path of the unix socket (default: /tmp/crud.sock)",
+			EnvVars: []string{"HTTP_SOCKET"},
+		},
+		&cli.StringFlag{
+			Name:    "tracing-exporter",
+			Usage:   "none, otlp or stdout (default: none)",
+			EnvVars: []string{"TRACING_EXPORTER"},
+		},
+		&cli.StringFlag{
+			Name:    "tracing-endpoint",
+			Usage:   "OTLP/HTTP collector address, e.g. localhost:4318",
+			EnvVars: []string{"TRACING_ENDPOINT", "OTEL_EXPORTER_OTLP_ENDPOINT"},
+		},
+		&cli.BoolFlag{
+			Name:    "tracing-insecure",
+			Usage:   "send spans to the collector without TLS",
+			EnvVars: []string{"TRACING_INSECURE"},
+		},
+		&cli.StringFlag{
+			Name:    "tracing-service-name",
+			Usage:   "service name of the spans (default: crud)",
+			EnvVars: []string{"TRACING_SERVICE_NAME", "OTEL_SERVICE_NAME"},
+		},
+		&cli.Float64Flag{
+			Name:    "tracing-sample-ratio",
+			Usage:   "share of traces to record, from 0 to 1 (default: 1)",
+			EnvVars: []string{"TRACING_SAMPLE_RATIO"},
+		},
+		&cli.StringFlag{
+			Name:    "log-level",
+			Usage:   "debug, info, warn or error (default: info)",
+			EnvVars: []string{"LOG_LEVEL"},
+		},
+		&cli.StringFlag{
+			Name:    "log-format",
+			Usage:   "text or json (default: text)",
+			EnvVars: []string{"LOG_FORMAT"},
+		},
+		&cli.StringFlag{
+			Name:    "rate-limit-read",
+			Usage:   "reads per second and burst per client, e.g. 50:100, 0 to disable (default: 50:100)",
+			EnvVars: []string{"RATE_LIMIT_READ"},
+		},
+		&cli.StringFlag{
+			Name:    "rate-limit-write",
+			Usage:   "writes per second and burst per client (default: 10:20)",
+			EnvVars: []string{"RATE_LIMIT_WRITE"},
+		},
+		&cli.StringFlag{
+			Name:    "rate-limit-batch",
+			Usage:   "batch requests per second and burst per client (default: 1:5)",
+			EnvVars: []string{"RATE_LIMIT_BATCH"},
+		},
+		&cli.IntFlag{
+			Name:    "body-limit",
+			Usage:   "maximum body size of create and edit requests in bytes (default: 1048576)",
+			EnvVars: []string{"BODY_LIMIT"},
+		},
+		&cli.IntFlag{
+			Name:    "batch-body-limit",
+			Usage:   "maximum body size of batch requests in bytes (default: 4194304)",
+			EnvVars: []string{"BATCH_BODY_LIMIT"},
+		},
+		&cli.IntFlag{
+			Name:    "cache-size",
+			Usage:   "number of catalogue reads kept in memory, 0 to disable the cache (default: 10000)",
+			EnvVars: []string{"CACHE_SIZE"},
+		},
+		&cli.DurationFlag{
+			Name:    "cache-ttl",
+			Usage:   "lifetime of cached catalogue reads (default: 30s)",
+			EnvVars: []string{"CACHE_TTL"},
+		},
+		&cli.StringFlag{
+			Name:    "cache-control-list",
+			Usage:   "Cache-Control header of the product list (default: private, no-cache)",
+			EnvVars: []string{"CACHE_CONTROL_LIST"},
+		},
+		&cli.StringFlag{
+			Name:    "cache-control-product",
+			Usage:   "Cache-Control header of a single product (default: private, no-cache)",
+			EnvVars: []string{"CACHE_CONTROL_PRODUCT"},
+		},
+		&cli.StringFlag{
+			Name:    "metrics-path",
+			Usage:   "path of the Prometheus metrics endpoint, empty to disable it (default: /metrics)",
+			EnvVars: []string{"METRICS_PATH"},
+		},
+		&cli.StringFlag{
+			Name:    "jwt-jwks",
+			Usage:   "path or URL of the JWKS used to verify Bearer tokens, Bearer tokens are rejected if not set",
+			EnvVars: []string{"JWT_JWKS"},
+		},
+		&cli.DurationFlag{
+			Name:    "jwt-jwks-refresh",
+			Usage:   "how often the JWKS is reloaded (default: 10m)",
+			EnvVars: []string{"JWT_JWKS_REFRESH"},
+		},
+		&cli.StringFlag{
+			Name:    "jwt-issuer",
+			Usage:   "required iss claim of Bearer tokens, mandatory with --jwt-jwks",
+			EnvVars: []string{"JWT_ISSUER"},
+		},
+		&cli.StringFlag{
+			Name:    "jwt-audience",
+			Usage:   "required aud claim of Bearer tokens, mandatory with --jwt-jwks",
+			EnvVars: []string{"JWT_AUDIENCE"},
+		},
+		&cli.StringFlag{
+			Name:    "jwt-role-claim",
+			Usage:   "claim with the roles of the token subject (default: roles)",
+			EnvVars: []string{"JWT_ROLE_CLAIM"},
+		},
+		&cli.StringFlag{
+			Name:    "jwt-role-map",
+			Usage:   "mapping of gateway roles to catalogue roles, e.g. catalog-admin=admin,catalog-pricing=pricing",
+			EnvVars: []string{"JWT_ROLE_MAP"},
+		},
+		&cli.StringFlag{
+			Name:    "jwt-tenant-claim",
+			Usage:   "claim with the tenant of the token subject, tokens without it belong to the default tenant (default: tenant)",
+			EnvVars: []string{"JWT_TENANT_CLAIM"},
+		},
+		&cli.DurationFlag{
+			Name:    "session-ttl",
+			Usage:   "how long a login session of the HTML interface lives (default: 12h)",
+			EnvVars: []string{"SESSION_TTL"},
+		},
+		&cli.BoolFlag{
+			Name:    "session-cookie-secure",
+			Usage:   "mark the session cookie as Secure, enable when the interface is served over https",
+			EnvVars: []string{"SESSION_COOKIE_SECURE"},
+		},
+		&cli.StringFlag{
+			Name:    "tenant-base-domain",
+			Usage:   "resolve the tenant from the subdomain of this domain, e.g. catalog.example.com",
+			EnvVars: []string{"TENANT_BASE_DOMAIN"},
+		},
+		&cli.StringFlag{
+			Name:    "default-tenant",
+			Usage:   "tenant for requests that name no tenant, empty to require one (default: default)",
+			EnvVars: []string{"DEFAULT_TENANT"},
+		},
+		&cli.IntFlag{
+			Name:    "events-buffer-size",
+			Usage:   "number of recent product events kept for Last-Event-ID resume (default: 1024)",
+			EnvVars: []string{"EVENTS_BUFFER_SIZE"},
+		},
+		&cli.IntFlag{
+			Name:    "outbox-max-attempts",
+			Usage:   "number of relay attempts before an outbox message is parked (default: 20)",
+			EnvVars: []string{"OUTBOX_MAX_ATTEMPTS"},
+		},
+		&cli.BoolFlag{
+			Name:    "outbox-log",
+			Usage:   "print product events relayed from the outbox",
+			EnvVars: []string{"OUTBOX_LOG"},
+		},
+		&cli.StringFlag{
+			Name:    "nats-addr",
+			Usage:   "address of a NATS-compatible broker to relay product events to, e.g. 127.0.0.1:4222",
+			EnvVars: []string{"NATS_ADDR"},
+		},
+		&cli.StringFlag{
+			Name:    "nats-subject-prefix",
+			Usage:   "subject prefix for product events published to the broker (default: crud)",
+			EnvVars: []string{"NATS_SUBJECT_PREFIX"},
+		},
+		&cli.IntFlag{
+			Name:    "webhook-max-attempts",
+			Usage:   "number of delivery attempts before a webhook delivery is marked as failed (default: 10)",
+			EnvVars: []string{"WEBHOOK_MAX_ATTEMPTS"},
+		},
+		&cli.DurationFlag{
+			Name:    "idempotency-ttl",
+			Usage:   "how long responses of requests with Idempotency-Key are kept (default: 24h)",
+			EnvVars: []string{"IDEMPOTENCY_TTL"},
+		},
+		&cli.IntFlag{
+			Name:    "batch-max-size",
+			Usage:   "maximum number of operations in one batch request (default: 100)",
+			EnvVars: []string{"BATCH_MAX_SIZE"},
+		},
+	}
+}
+
+// loadConfig собирает конфигурацию: значения по умолчанию, файл, затем окружение и флаги
+func loadConfig(ctx *cli.Context) (*config.Config, error) {
+	cfg, err := config.Load(ctx.String("config"))
+	if err != nil {
+		return nil, err
+	}
+
+	overrideString(ctx, "db-dialect", &cfg.Database.Dialect)
+	overrideString(ctx, "db-schema", &cfg.Database.Schema)
+	overrideString(ctx, "db-dsn", &cfg.Database.DSN)
+	overrideString(ctx, "db-host", &cfg.Database.Host)
+	overrideString(ctx, "db-port", &cfg.Database.Port)
+	overrideString(ctx, "db-user", &cfg.Database.User)
+	overrideString(ctx, "db-password", &cfg.Database.Password)
+	overrideString(ctx, "db-name", &cfg.Database.Name)
+	if ctx.IsSet("db-debug") {
+		cfg.Database.Debug = ctx.Bool("db-debug")
+	}
+	overrideDuration(ctx, "db-slow-query-threshold", &cfg.Database.SlowQueryThreshold)
+	if ctx.IsSet("db-replica") {
+		cfg.Database.Replicas = parseReplicas(ctx.StringSlice("db-replica"))
+	}
+	overrideDuration(ctx, "db-replica-check-interval", &cfg.Database.ReplicaCheckInterval)
+	if ctx.IsSet("db-max-idle-conns") {
+		cfg.Database.MaxIdleConns = ctx.Int("db-max-idle-conns")
+	}
+	if ctx.IsSet("db-max-open-conns") {
+		cfg.Database.MaxOpenConns = ctx.Int("db-max-open-conns")
+	}
+	overrideDuration(ctx, "db-conn-max-lifetime", &cfg.Database.MaxConnMaxLifetime)
+	overrideString(ctx, "db-tls", &cfg.Database.TLS)
+	overrideDuration(ctx, "db-timeout", &cfg.Database.Timeout)
+	overrideDuration(ctx, "db-read-timeout", &cfg.Database.ReadTimeout)
+	overrideDuration(ctx, "db-write-timeout", &cfg.Database.WriteTimeout)
+	overrideString(ctx, "db-charset", &cfg.Database.Charset)
+	overrideString(ctx, "db-collation", &cfg.Database.Collation)
+	overrideString(ctx, "db-loc", &cfg.Database.Loc)
+	if ctx.IsSet("db-connect-attempts") {
+		cfg.Database.Retry.Attempts = ctx.Int("db-connect-attempts")
+	}
+	overrideDuration(ctx, "db-connect-backoff", &cfg.Database.Retry.Backoff)
+	overrideDuration(ctx, "db-connect-max-backoff", &cfg.Database.Retry.MaxBackoff)
+	overrideDuration(ctx, "db-ping-timeout", &cfg.Database.Retry.PingTimeout)
+	overrideString(ctx, "http-listener", &cfg.HTTP.Listener)
+	overrideString(ctx, "http-addr", &cfg.HTTP.Addr)
+	overrideString(ctx, "http-socket", &cfg.HTTP.Socket)
+	overrideString(ctx, "tracing-exporter", &cfg.Tracing.Exporter)
+	overrideString(ctx, "tracing-endpoint", &cfg.Tracing.Endpoint)
+	if ctx.IsSet("tracing-insecure") {
+		cfg.Tracing.Insecure = ctx.Bool("tracing-insecure")
+	}
+	overrideString(ctx, "tracing-service-name", &cfg.Tracing.ServiceName)
+	if ctx.IsSet("tracing-sample-ratio") {
+		cfg.Tracing.SampleRatio = ctx.Float64("tracing-sample-ratio")
+	}
+	overrideString(ctx, "log-level", &cfg.Log.Level)
+	overrideString(ctx, "log-format", &cfg.Log.Format)
+	if err = overrideRate(ctx, "rate-limit-read", &cfg.Limits.Read); err != nil {
+		return nil, err
+	}
+	if err = overrideRate(ctx, "rate-limit-write", &cfg.Limits.Write); err != nil {
+		return nil, err
+	}
+	if err = overrideRate(ctx, "rate-limit-batch", &cfg.Limits.Batch); err != nil {
+		return nil, err
+	}
+	if ctx.IsSet("body-limit") {
+		cfg.Limits.Body = ctx.Int("body-limit")
+	}
+	if ctx.IsSet("batch-body-limit") {
+		cfg.Limits.BatchBody = ctx.Int("batch-body-limit")
+	}
+	if ctx.IsSet("cache-size") {
+		cfg.Cache.Size = ctx.Int("cache-size")
+	}
+	overrideDuration(ctx, "cache-ttl", &cfg.Cache.TTL)
+	overrideString(ctx, "cache-control-list", &cfg.HTTP.CacheControl.List)
+	overrideString(ctx, "cache-control-product", &cfg.HTTP.CacheControl.Product)
+	overrideString(ctx, "metrics-path", &cfg.Metrics.Path)
+	overrideString(ctx, "jwt-jwks", &cfg.Auth.JWT.JWKS)
+	overrideDuration(ctx, "jwt-jwks-refresh", &cfg.Auth.JWT.JWKSRefresh)
+	overrideString(ctx, "jwt-issuer", &cfg.Auth.JWT.Issuer)
+	overrideString(ctx, "jwt-audience", &cfg.Auth.JWT.Audience)
+	overrideString(ctx, "jwt-role-claim", &cfg.Auth.JWT.RoleClaim)
+	if ctx.IsSet("jwt-role-map") {
+		if cfg.Auth.JWT.RoleMap, err = jwtauth.ParseRoleMapping(ctx.String("jwt-role-map")); err != nil {
+			return nil, fmt.Errorf("--jwt-role-map: %w", err)
+		}
+	}
+	overrideString(ctx, "jwt-tenant-claim", &cfg.Auth.JWT.TenantClaim)
+	overrideDuration(ctx, "session-ttl", &cfg.Auth.Session.TTL)
+	if ctx.IsSet("session-cookie-secure") {
+		cfg.Auth.Session.Secure = ctx.Bool("session-cookie-secure")
+	}
+	overrideString(ctx, "tenant-base-domain", &cfg.Tenant.BaseDomain)
+	overrideString(ctx, "default-tenant", &cfg.Tenant.Default)
+	overrideInt(ctx, "events-buffer-size", &cfg.Events.BufferSize)
+	overrideInt(ctx, "outbox-max-attempts", &cfg.Outbox.Relay.MaxAttempts)
+	if ctx.IsSet("outbox-log") {
+		cfg.Outbox.Log = ctx.Bool("outbox-log")
+	}
+	overrideString(ctx, "nats-addr", &cfg.Outbox.NATS.Addr)
+	overrideString(ctx, "nats-subject-prefix", &cfg.Outbox.NATS.SubjectPrefix)
+	overrideInt(ctx, "webhook-max-attempts", &cfg.Webhook.MaxAttempts)
+	overrideDuration(ctx, "idempotency-ttl", &cfg.Idempotency.TTL)
+	overrideInt(ctx, "batch-max-size", &cfg.Batch.MaxSize)
+
+	if err = cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func overrideString(ctx *cli.Context, name string, value *string) {
+	if ctx.IsSet(name) {
+		*value = ctx.String(name)
+	}
+}
+
+func overrideInt(ctx *cli.Context, name string, value *int) {
+	if ctx.IsSet(name) {
+		*value = ctx.Int(name)
+	}
+}
+
+func overrideDuration(ctx *cli.Context, name string, value *time.Duration) {
+	if ctx.IsSet(name) {
+		*value = ctx.Duration(name)
+	}
+}
+
+// parseReplicas адреса реплик host или host:port
+func parseReplicas(addrs []string) []database.Replica {
+	replicas := make([]database.Replica, 0, len(addrs))
+	for _, addr := range addrs {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			// порт не указан
+			host, port = addr, ""
+		}
+		replicas = append(replicas, database.Replica{Host: host, Port: port})
+	}
+	return replicas
+}
+
+func overrideRate(ctx *cli.Context, name string, value *limits.Rate) error {
+	if !ctx.IsSet(name) {
+		return nil
+	}
+	rate, err := limits.ParseRate(ctx.String(name))
+	if err != nil {
+		return fmt.Errorf("--%s: %w", name, err)
+	}
+	*value = rate
+	return nil
+}
+
+// connection пул любого диалекта
+type connection interface {
+	database.Pool
+	// DB пул database/sql для статистики соединений
+	DB() *sql.DB
+	Connect(ctx context.Context, onRetry database.RetryFunc) error
+}
+
+// openPool пул диалекта opt.Dialect без обращения к базе
+func openPool(opt *database.Opt, observer database.QueryObserver) (connection, error) {
+	if opt.Dialect == database.DialectPostgres {
+		pool, err := postgres.Open(opt, observer)
+		if err != nil {
+			return nil, err
+		}
+		return pool, nil
+	}
+	pool, err := mysql.Open(opt, observer)
+	if err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// openDatabase подключение для служебных команд
+func openDatabase(ctx *cli.Context) (database.Pool, error) {
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Database.Dialect == database.DialectPostgres {
+		conn, err := postgres.New(ctx.Context, &cfg.Database)
+		if err != nil {
+			return nil, err
+		}
+		return conn, nil
+	}
+	conn, err := mysql.New(ctx.Context, &cfg.Database)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+func configCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "config",
+		Usage: "inspect the configuration",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "print",
+				Usage:  "print the effective configuration with secrets masked",
+				Action: configPrint,
+			},
+		},
+	}
+}
+
+func configPrint(ctx *cli.Context) error {
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := cfg.Masked().YAML()
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(out))
+	return nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/grip211/crud/pkg/events"
+	"github.com/grip211/crud/pkg/tenant"
+)
+
+const sseHeartbeat = time.Second * 15
+
+// поток событий об изменении товаров в формате Server-Sent Events
+// GET http://localhost:8181/api/v1/products/events?product_id=1&company=Apple
+func buildRestEventsHandler(appContext context.Context, bus *events.Bus) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		tenantID, err := tenant.Require(ctx.UserContext())
+		if err != nil {
+			return err
+		}
+
+		filter := events.Filter{
+			Tenant:    tenantID,
+			ProductID: ctx.QueryInt("product_id"),
+			Company:   ctx.Query("company"),
+		}
+
+		lastID, _ := strconv.ParseUint(ctx.Get("Last-Event-ID", ctx.Query("last_event_id")), 10, 64)
+		sub, missed := bus.Subscribe(lastID, filter)
+
+		ctx.Set(fiber.HeaderContentType, "text/event-stream")
+		ctx.Set(fiber.HeaderCacheControl, "no-cache")
+		ctx.Set(fiber.HeaderConnection, "keep-alive")
+		ctx.Set("X-Accel-Buffering", "no")
+
+		ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			defer sub.Close()
+
+			for i := range missed {
+				if err := writeEvent(w, &missed[i]); err != nil {
+					return
+				}
+			}
+			if err := w.Flush(); err != nil {
+				return
+			}
+
+			heartbeat := time.NewTicker(sseHeartbeat)
+			defer heartbeat.Stop()
+
+			for {
+				select {
+				case <-appContext.Done():
+					return
+				case event, ok := <-sub.C():
+					if !ok {
+						return
+					}
+					if err := writeEvent(w, &event); err != nil {
+						return
+					}
+				case <-heartbeat.C:
+					// комментарий держит соединение и позволяет заметить отключившегося клиента
+					if _, err := w.WriteString(": ping\n\n"); err != nil {
+						return
+					}
+				}
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+		})
+
+		return nil
+	}
+}
+
+func writeEvent(w *bufio.Writer, event *events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+// WebSocket с событиями об изменении товаров для живого обновления таблицы на главной странице
+// ws://localhost:8181/ws/products?last_event_id=10
+func buildWebSocketEventsHandler(appContext context.Context, bus *events.Bus) fiber.Handler {
+	return websocket.New(func(conn *websocket.Conn) {
+		tenantID, _ := conn.Locals(tenant.LocalsKey).(string)
+		if tenantID == "" {
+			_ = conn.WriteMessage(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, tenant.ErrMissing.Error()))
+			return
+		}
+
+		lastID, _ := strconv.ParseUint(conn.Query("last_event_id"), 10, 64)
+		sub, missed := bus.Subscribe(lastID, events.Filter{Tenant: tenantID})
+		defer sub.Close()
+
+		// читаем входящие сообщения только чтобы заметить закрытие соединения клиентом
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		for i := range missed {
+			if err := conn.WriteJSON(&missed[i]); err != nil {
+				return
+			}
+		}
+
+		heartbeat := time.NewTicker(sseHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-appContext.Done():
+				_ = conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutdown"))
+				return
+			case <-closed:
+				return
+			case event, ok := <-sub.C():
+				if !ok {
+					// не успевали отправлять, клиент переподключится с last_event_id
+					_ = conn.WriteMessage(websocket.CloseMessage,
+						websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "subscriber is too slow"))
+					return
+				}
+				if err := conn.WriteJSON(&event); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+					return
+				}
+			}
+		}
+	})
+}
+
+// пропускаем дальше только запросы на апгрейд до WebSocket
+func requireWebSocketUpgrade(ctx *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(ctx) {
+		return fiber.ErrUpgradeRequired
+	}
+	return ctx.Next()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp/fasthttputil"
+
+	"github.com/grip211/crud/pkg/events"
+	"github.com/grip211/crud/pkg/tenant"
+)
+
+func TestWebSocketEventsHandler(t *testing.T) {
+	appContext, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bus := events.NewBus(10)
+	bus.Publish(
+		events.Event{Type: events.TypeCreated, ProductID: 1, Tenant: "shop-a"},
+		events.Event{Type: events.TypeCreated, ProductID: 2, Tenant: "shop-b"},
+		events.Event{Type: events.TypeUpdated, ProductID: 1, Tenant: "shop-a"},
+	)
+
+	resolver := tenant.NewResolver(knownTenants{"shop-a": true, "shop-b": true}, tenant.Options{})
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/ws/products", resolver.Requested(), resolver.Enforce(), requireWebSocketUpgrade, buildWebSocketEventsHandler(appContext, bus))
+	// маршрут без выбора арендатора, чтобы обработчик получил соединение без него
+	app.Get("/ws/untenanted", requireWebSocketUpgrade, buildWebSocketEventsHandler(appContext, bus))
+
+	listener := fasthttputil.NewInmemoryListener()
+	go func() {
+		_ = app.Listener(listener)
+	}()
+	defer func() {
+		require.NoError(t, app.Shutdown())
+	}()
+
+	dial := func(t *testing.T, path, tenantID string) *websocket.Conn {
+		dialer := websocket.Dialer{
+			NetDial: func(string, string) (net.Conn, error) {
+				return listener.Dial()
+			},
+			HandshakeTimeout: time.Second * 5,
+		}
+		header := http.Header{}
+		if tenantID != "" {
+			header.Set(tenant.HeaderTenant, tenantID)
+		}
+		conn, resp, err := dialer.Dial("ws://localhost"+path, header)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		t.Cleanup(func() {
+			_ = conn.Close()
+		})
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second*5)))
+		return conn
+	}
+	read := func(t *testing.T, conn *websocket.Conn) events.Event {
+		var event events.Event
+		require.NoError(t, conn.ReadJSON(&event))
+		return event
+	}
+
+	t.Run("missing tenant closes the connection", func(t *testing.T) {
+		conn := dial(t, "/ws/untenanted", "")
+
+		_, _, err := conn.ReadMessage()
+		require.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation), err)
+		require.Contains(t, err.Error(), tenant.ErrMissing.Error())
+	})
+
+	t.Run("missed events are replayed from last_event_id", func(t *testing.T) {
+		conn := dial(t, "/ws/products?last_event_id=1", "shop-a")
+
+		// событие 2 другого арендатора пропущено
+		event := read(t, conn)
+		require.Equal(t, uint64(3), event.ID)
+		require.Equal(t, "shop-a", event.Tenant)
+	})
+
+	t.Run("live events are filtered by tenant", func(t *testing.T) {
+		conn := dial(t, "/ws/products?last_event_id=1", "shop-b")
+		// повтор пришел, значит подписка уже есть и живые события не потеряются
+		require.Equal(t, uint64(2), read(t, conn).ID)
+
+		bus.Publish(
+			events.Event{Type: events.TypeStock, ProductID: 1, Tenant: "shop-a"},
+			events.Event{Type: events.TypeStock, ProductID: 2, Tenant: "shop-b"},
+		)
+
+		event := read(t, conn)
+		require.Equal(t, uint64(5), event.ID)
+		require.Equal(t, "shop-b", event.Tenant)
+	})
+}
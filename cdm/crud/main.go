@@ -2,11 +2,14 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -15,16 +18,35 @@ import (
 	"github.com/gofiber/template/html/v2"
 	"github.com/urfave/cli/v2"
 
+	"github.com/grip211/crud/migrations"
+	"github.com/grip211/crud/pkg/apikey"
 	"github.com/grip211/crud/pkg/apperror"
+	"github.com/grip211/crud/pkg/auth"
+	"github.com/grip211/crud/pkg/authz"
+	"github.com/grip211/crud/pkg/cache"
 	"github.com/grip211/crud/pkg/commands"
 	"github.com/grip211/crud/pkg/database"
-	"github.com/grip211/crud/pkg/database/mysql"
+	"github.com/grip211/crud/pkg/events"
+	"github.com/grip211/crud/pkg/health"
+	"github.com/grip211/crud/pkg/httpcache"
+	"github.com/grip211/crud/pkg/idempotency"
+	"github.com/grip211/crud/pkg/jwtauth"
+	"github.com/grip211/crud/pkg/limits"
+	"github.com/grip211/crud/pkg/logging"
+	"github.com/grip211/crud/pkg/metrics"
+	"github.com/grip211/crud/pkg/outbox"
 	"github.com/grip211/crud/pkg/repository"
+	"github.com/grip211/crud/pkg/requestid"
+	"github.com/grip211/crud/pkg/session"
 	"github.com/grip211/crud/pkg/signal"
+	"github.com/grip211/crud/pkg/tenant"
+	"github.com/grip211/crud/pkg/tracing"
+	"github.com/grip211/crud/pkg/user"
+	"github.com/grip211/crud/pkg/webhook"
 )
 
 // удаление наименований
-func buildRestDeleteHandler(repo *repository.Repo) fiber.Handler {
+func buildRestDeleteHandler(repo *authz.Repo) fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
 		id := ctx.Params("id")
 		command, err := commands.NewDeleteCommand(id)
@@ -33,7 +55,7 @@ func buildRestDeleteHandler(repo *repository.Repo) fiber.Handler {
 			return err
 		}
 
-		_, err = repo.Delete(ctx.Context(), command)
+		_, err = repo.Delete(ctx.UserContext(), command)
 		if err != nil {
 			// убрать после того как добавишь обработку ошибок в ErrorHandler
 			return err
@@ -57,7 +79,7 @@ type EditForm struct {
 }
 
 // получаем измененные данные и сохраняем их в БД
-func buildRestEditHandler(repo *repository.Repo) fiber.Handler {
+func buildRestEditHandler(repo *authz.Repo) fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
 		edit := &EditForm{}
 		if err := ctx.BodyParser(edit); err != nil {
@@ -80,7 +102,7 @@ func buildRestEditHandler(repo *repository.Repo) fiber.Handler {
 			return err
 		}
 
-		err = repo.Update(ctx.Context(), updateCommand)
+		err = repo.Update(ctx.UserContext(), updateCommand)
 		if err != nil {
 			// убрать после того как добавишь обработку ошибок в ErrorHandler
 			return err
@@ -102,7 +124,7 @@ type CreatForm struct {
 	Camera  string `form:"camera" json:"camera"`
 }
 
-func buildRestCreateHandler(repo *repository.Repo) fiber.Handler {
+func buildRestCreateHandler(repo *authz.Repo) fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
 		if ctx.Method() == "POST" {
 			creat := &CreatForm{}
@@ -125,7 +147,7 @@ func buildRestCreateHandler(repo *repository.Repo) fiber.Handler {
 				return apperror.ErrEndFound
 			}
 
-			_, err = repo.Create(ctx.Context(), createCommand)
+			_, err = repo.Create(ctx.UserContext(), createCommand)
 			if err != nil {
 				// убрать после того как добавишь обработку ошибок в ErrorHandler
 				return err
@@ -137,17 +159,34 @@ func buildRestCreateHandler(repo *repository.Repo) fiber.Handler {
 	}
 }
 
-func buildRestIndexHandler(repo *repository.Repo) fiber.Handler {
+// readYourWrites после записи запрос читает из основной базы, а не из реплики
+func readYourWrites(ctx *fiber.Ctx) error {
+	ctx.SetUserContext(database.WithSession(ctx.UserContext()))
+	return ctx.Next()
+}
+
+func buildRestIndexHandler(repo *authz.Repo) fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
-		products, err := repo.Read(ctx.Context())
+		products, err := repo.Read(ctx.UserContext())
 		if err != nil {
 			return err
 		}
+
+		// только для информации: удаление не двигает время, поэтому 304 для списка дает лишь ETag
+		var modified time.Time
+		for _, product := range products {
+			if product.UpdatedAt.After(modified) {
+				modified = product.UpdatedAt
+			}
+		}
+		if !modified.IsZero() {
+			ctx.Set(fiber.HeaderLastModified, httpcache.FormatTime(modified))
+		}
 		return ctx.JSON(products)
 	}
 }
 
-func buildRestFeatureHandler(repo *repository.Repo) fiber.Handler {
+func buildRestFeatureHandler(repo *authz.Repo) fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
 		id := ctx.Params("id")
 
@@ -157,19 +196,90 @@ func buildRestFeatureHandler(repo *repository.Repo) fiber.Handler {
 			return err
 		}
 
-		product, err := repo.ReadOneWithFeatures(ctx.Context(), iid)
+		product, err := repo.ReadOneWithFeatures(ctx.UserContext(), iid)
 		if err != nil {
 			// убрать после того как добавишь обработку ошибок в ErrorHandler
 			return err
 		}
 
+		httpcache.LastModified(ctx, product.UpdatedAt)
 		return ctx.JSON(product)
 	}
 }
 
+type BatchForm struct {
+	Mode       string          `json:"mode"`
+	Operations []BatchItemForm `json:"operations"`
+}
+
+type BatchItemForm struct {
+	Op string `json:"op"`
+	EditForm
+}
+
+// пакетное создание, изменение и удаление наименований
+func buildRestBatchHandler(repo *authz.Repo, maxSize int) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		batch := &BatchForm{}
+		if err := ctx.BodyParser(batch); err != nil {
+			return err
+		}
+
+		operations := make([]*commands.BatchOperation, 0, len(batch.Operations))
+		for _, item := range batch.Operations {
+			operations = append(operations, commands.NewBatchOperation(
+				item.Op,
+				item.ID,
+				item.Model,
+				item.Company,
+				item.Quantity,
+				item.Price,
+				item.CPU,
+				item.Memory,
+				item.Display,
+				item.Camera,
+			))
+		}
+
+		command, err := commands.NewBatchCommand(batch.Mode, maxSize, operations)
+		if err != nil {
+			status := fiber.StatusBadRequest
+			if errors.Is(err, commands.ErrBatchTooLarge) {
+				status = fiber.StatusRequestEntityTooLarge
+			}
+			return ctx.Status(status).JSON(apperror.NewErrorHandler(err, "invalid batch", err.Error(), "batch_invalid"))
+		}
+
+		results, err := repo.Batch(ctx.UserContext(), command)
+		if errors.Is(err, auth.ErrForbidden) {
+			return ctx.Status(fiber.StatusForbidden).
+				JSON(apperror.NewErrorHandler(err, "batch rolled back", err.Error(), "forbidden"))
+		}
+		if err != nil {
+			var batchErr *repository.BatchError
+			if errors.As(err, &batchErr) {
+				return ctx.Status(fiber.StatusUnprocessableEntity).
+					JSON(apperror.NewErrorHandler(err, "batch rolled back", err.Error(), "batch_failed"))
+			}
+			return err
+		}
+
+		return ctx.JSON(fiber.Map{
+			"mode":    command.Mode,
+			"results": results,
+		})
+	}
+}
+
 func main() {
 	application := &cli.App{
-		Flags:  []cli.Flag{},
+		Flags: configFlags(),
+		Commands: []*cli.Command{
+			configCommand(),
+			apiKeyCommand(),
+			userCommand(),
+			tenantCommand(),
+		},
 		Action: Main,
 	}
 	if err := application.Run(os.Args); err != nil {
@@ -184,74 +294,296 @@ func Main(ctx *cli.Context) error {
 		<-time.After(time.Second * 1)
 	}()
 
+	readiness := health.NewReadiness()
 	await, stop := signal.Notifier(func() {
-		fmt.Println("received a system signal, start shutdown process..")
+		slog.Info("received a system signal, start shutdown process")
+		// балансировщик должен перестать слать запросы как можно раньше
+		readiness.Set(health.StateStopping, "shutting down")
 	})
 
-	conn, err := mysql.New(appContext, &database.Opt{
-		Host:               os.Getenv("DB_Host"),
-		User:               os.Getenv("DB_USER"),
-		Password:           os.Getenv("DB_PASS"),
-		Name:               os.Getenv("DB_NAME"),
-		Dialect:            "mysql",
-		MaxConnMaxLifetime: time.Minute * 5,
-		MaxOpenConns:       10,
-		MaxIdleConns:       9,
-		Debug:              true,
-	})
+	cfg, err := loadConfig(ctx)
 	if err != nil {
 		return err
 	}
 
-	repo := repository.New(conn)
+	logger, err := logging.New(os.Stderr, cfg.Log)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	appContext = logging.WithLogger(appContext, logger)
+
+	shutdownTracing, err := tracing.Setup(appContext, cfg.Tracing)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// дописываем накопленные спаны, даже если контекст приложения уже отменен
+		flushContext, flushCancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer flushCancel()
+		if err := shutdownTracing(flushContext); err != nil {
+			logger.Error("flush spans", "error", err)
+		}
+	}()
+
+	observability := metrics.New()
+
+	// пул создаем сразу, а к базе подключаемся в фоне, пока сервер уже отвечает на /readyz
+	conn, err := openPool(&cfg.Database, observability)
+	if err != nil {
+		return err
+	}
+
+	listening := &health.Flag{}
+	checker := health.NewChecker(readiness, time.Second*2)
+	checker.Add("database", health.Ping(conn))
+	checker.Add("migrations", health.Schema(conn, migrations.Version()))
+	checker.Add("listener", listening.Check)
+
+	bus := events.NewBus(cfg.Events.BufferSize)
+	defer bus.Close()
+
+	if err = observability.RegisterDB(conn.DB(), cfg.Database.Name); err != nil {
+		return err
+	}
+
+	// каталог читает из реплик, остальные хранилища работают только с основной базой
+	var catalogPool database.Pool = conn
+	if len(cfg.Database.Replicas) > 0 {
+		replicas := make([]database.Pool, len(cfg.Database.Replicas))
+		names := make([]string, len(cfg.Database.Replicas))
+		for i := range cfg.Database.Replicas {
+			names[i] = cfg.Database.ReplicaName(i)
+			replica, err := openPool(cfg.Database.Replica(i), observability)
+			if err != nil {
+				return fmt.Errorf("replica %s: %w", names[i], err)
+			}
+			if err = observability.RegisterDB(replica.DB(), names[i]); err != nil {
+				return err
+			}
+			replicas[i] = replica
+		}
+		replicated := database.NewReplicated(conn, replicas, names).WithObserver(observability)
+		go replicated.Run(appContext, cfg.Database.ReplicaCheckInterval, time.Second*2)
+		catalogPool = replicated
+	}
+
+	repo := repository.New(catalogPool).WithObserver(observability)
+	var products repository.Catalog = repo
+	if cfg.Cache.Size > 0 {
+		products = cache.New(repo, cache.NewLRU(cfg.Cache.Size), cfg.Cache.TTL).WithObserver(observability)
+	}
+	catalog := authz.New(products)
+
+	idempotencyStore := idempotency.NewRepo(conn)
+
+	authenticators := []auth.Authenticator{
+		apikey.NewAuthenticator(apikey.NewRepo(conn)),
+	}
+	if jwt := &cfg.Auth.JWT; jwt.JWKS != "" {
+		keys := jwtauth.NewKeySet(jwt.JWKS)
+		if err = keys.Load(appContext); err != nil {
+			return err
+		}
+		go keys.Run(appContext, jwt.JWKSRefresh)
+
+		// токен без claim арендатора привязан к арендатору по умолчанию, а не к запрошенному
+		authenticators = append(authenticators, jwtauth.NewValidator(keys, jwt.Options(cfg.Tenant.Default)))
+	}
+
+	resolver := tenant.NewResolver(tenant.NewRepo(conn), cfg.Tenant)
+
+	limiter := limits.NewLimiter(limits.NewMemory(), cfg.Limits)
+
+	users := user.NewRepo(conn)
+	sessionStore := session.NewRepo(conn)
+	sessions := session.NewManager(sessionStore, cfg.Auth.Session)
+
+	webhookStore := webhook.NewRepo(conn)
+	dispatcher := webhook.NewDispatcher(webhookStore, cfg.Webhook)
+
+	sinks := []outbox.Sink{
+		outbox.NewWebhookSink(dispatcher),
+		outbox.NewBusSink(bus),
+	}
+	if cfg.Outbox.Log {
+		sinks = append(sinks, outbox.LogSink{})
+	}
+	if nats := &cfg.Outbox.NATS; nats.Addr != "" {
+		broker := outbox.NewNATSSink(nats.Addr, nats.SubjectPrefix, time.Second*5)
+		defer broker.Close()
+		sinks = append(sinks, broker)
+	}
+
+	relay := outbox.NewRelay(outbox.NewRepo(conn), cfg.Outbox.Relay, sinks...).WithObserver(observability)
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+
+		readiness.Set(health.StateStarting, "waiting for the database")
+		err := conn.Connect(appContext, func(attempt int, err error, delay time.Duration) {
+			logger.Warn("database is not ready", "attempt", attempt, "error", err, "retry_in", delay.Round(time.Millisecond))
+			readiness.Set(health.StateStarting, fmt.Sprintf("waiting for the database (attempt %d): %v", attempt, err))
+		})
+		if err != nil {
+			// отмена при остановке это не ошибка
+			if appContext.Err() == nil {
+				stop(err)
+			}
+			return
+		}
+		readiness.Set(health.StateReady, "")
+
+		// фоновые процессы работают с базой, запускаем их после подключения
+		go idempotencyStore.RunCleanup(appContext, time.Hour)
+		go sessionStore.RunCleanup(appContext, time.Hour)
+		go dispatcher.Run(appContext)
+		relay.Run(appContext)
+	}()
 
 	go func() {
 		engine := html.New("./templates", ".html")
 
 		server := fiber.New(fiber.Config{
-			Views: engine,
+			Views:     engine,
+			BodyLimit: cfg.Limits.MaxBody(),
 			ErrorHandler: func(ctx *fiber.Ctx, err error) error {
-				// тут будут печататься все ошибки с хедлеров
-				fmt.Println(err)
+				// тут логируются все ошибки с хедлеров, логгер запроса уже с пользователем и арендатором
+				requestLogger := logging.FromContext(ctx.UserContext()).With("route", ctx.Route().Path, "error", err)
+				if errors.Is(err, auth.ErrUnauthenticated) || errors.Is(err, auth.ErrForbidden) {
+					requestLogger.Info("request denied")
+				} else {
+					requestLogger.Error("request failed")
+				}
+
+				switch {
+				case errors.Is(err, auth.ErrUnauthenticated):
+					return ctx.Status(fiber.StatusUnauthorized).JSON(apperror.ErrUnauthorized)
+				case errors.Is(err, auth.ErrForbidden) && strings.HasPrefix(ctx.Path(), "/api/"):
+					return ctx.Status(fiber.StatusForbidden).
+						JSON(apperror.NewErrorHandler(err, "forbidden", err.Error(), "forbidden"))
+				case errors.Is(err, auth.ErrForbidden):
+					return ctx.Status(fiber.StatusForbidden).SendString("Forbidden")
+				}
 
 				// показываем страницу ошибки
 				return ctx.Render("error", nil)
 			},
 		})
 
-		server.Get("/", buildIndexHandler(repo))
-		server.Get("/create", buildCreateHandler(repo))
-		server.Get("/delete/:id", buildDeleteHandler(repo))
-		server.Get("/edit/:id", buildEditPageHandler(repo))
-		server.Get("/feature/:id", buildFeatureHandler(repo))
-
-		server.Post("/edit/:id?", buildEditHandler(repo))
-		server.Post("/create", buildCreateHandler(repo))
+		// метрики и проверки состояния отвечают и пока база не готова, в метрики запросов они не попадают
+		if path := cfg.Metrics.Path; path != "" {
+			server.Get(path, observability.Handler())
+		}
+		server.Get("/healthz", checker.Live())
+		server.Get("/readyz", checker.Ready())
+		server.Get("/debug/health", checker.Debug())
+		server.Use(requestid.New())
+		server.Use(logging.Middleware(logger))
+		server.Use(readYourWrites)
+		server.Use(tracing.Middleware())
+		server.Use(observability.Middleware())
+		// ошибки обработчиков превращаются в ответ здесь, middleware выше видят готовый статус
+		server.Use(apperror.Resolve())
+		server.Use(readiness.Gate())
+
+		// запрошенный арендатор (заголовок или поддомен) нужен и странице входа
+		server.Use(resolver.Requested())
+
+		server.Get("/login", buildLoginPageHandler())
+		// подбор паролей ограничиваем лимитом изменений по адресу клиента
+		server.Post("/login", limiter.Class(limits.ClassWrite), buildLoginHandler(users, sessions))
+
+		requireLogin := sessions.Require()
+		bodyLimit := limits.Body(cfg.Limits.Body)
+		enforceTenant := resolver.Enforce()
+		server.Get("/", requireLogin, enforceTenant, buildIndexHandler(catalog))
+		server.Get("/create", requireLogin, enforceTenant, buildCreateHandler(catalog))
+		server.Get("/delete/:id", requireLogin, enforceTenant, buildDeletePageHandler(catalog))
+		server.Get("/edit/:id", requireLogin, enforceTenant, buildEditPageHandler(catalog))
+		server.Get("/feature/:id", requireLogin, enforceTenant, buildFeatureHandler(catalog))
+		server.Get("/ws/products", requireLogin, enforceTenant, auth.Permit(auth.PermList), requireWebSocketUpgrade, buildWebSocketEventsHandler(appContext, bus))
+
+		server.Post("/edit/:id?", requireLogin, enforceTenant, bodyLimit, buildEditHandler(catalog))
+		server.Post("/create", requireLogin, enforceTenant, bodyLimit, buildCreateHandler(catalog))
+		server.Post("/delete/:id", requireLogin, enforceTenant, buildDeleteHandler(catalog))
+		server.Post("/logout", requireLogin, buildLogoutHandler(sessions))
 
 		v1 := server.Group("/api/v1")
-		v1.Get("/products", buildRestIndexHandler(repo)) // http://localhost:8181/api/v1/products
-		v1.Post("/create", buildRestCreateHandler(repo)) // POST http://localhost:8181/api/v1/create
-		v1.Post("/edit/:id", buildRestEditHandler(repo)) // POST http://localhost:8181/api/v1/edit/:id
-		v1.Delete("/delete/:id", buildRestDeleteHandler(repo))
-		v1.Get("/feature/:id", buildRestFeatureHandler(repo))
-
-		ln, err := signal.Listener(appContext, 1, "/tmp/crud.sock", ":8181")
+		v1.Use(auth.New(authenticators...))
+		v1.Use(resolver.Enforce())
+		v1.Use(limiter.Middleware())
+		v1.Use(idempotency.New(idempotencyStore, cfg.Idempotency.TTL))
+		v1.Get("/products", httpcache.New(cfg.HTTP.CacheControl.List), buildRestIndexHandler(catalog)) // http://localhost:8181/api/v1/products
+		v1.Get("/products/events", auth.Permit(auth.PermList), buildRestEventsHandler(appContext, bus))
+		v1.Post("/create", bodyLimit, buildRestCreateHandler(catalog)) // POST http://localhost:8181/api/v1/create
+		v1.Post("/edit/:id", bodyLimit, buildRestEditHandler(catalog)) // POST http://localhost:8181/api/v1/edit/:id
+		v1.Delete("/delete/:id", buildRestDeleteHandler(catalog))
+		v1.Get("/feature/:id", httpcache.New(cfg.HTTP.CacheControl.Product), buildRestFeatureHandler(catalog))
+		v1.Post("/webhooks", auth.Require(auth.ScopeAdmin), buildRestWebhookCreateHandler(webhookStore))
+		v1.Get("/webhooks", auth.Require(auth.ScopeAdmin), buildRestWebhookListHandler(webhookStore))
+		v1.Delete("/webhooks/:id", auth.Require(auth.ScopeAdmin), buildRestWebhookDeleteHandler(webhookStore))
+		v1.Get("/webhooks/:id/deliveries", auth.Require(auth.ScopeAdmin), buildRestWebhookDeliveriesHandler(webhookStore))
+		v1.Post("/products\\:batch", limiter.Class(limits.ClassBatch), limits.Body(cfg.Limits.BatchBody), buildRestBatchHandler(catalog, cfg.Batch.MaxSize)) // POST http://localhost:8181/api/v1/products:batch
+
+		ln, err := signal.Listener(appContext, cfg.HTTP.ListenerMode(), cfg.HTTP.Socket, cfg.HTTP.Addr)
 		if err != nil {
 			stop(err)
 			return
 		}
 
-		if err := server.Listener(ln); err != nil {
+		listening.Set(true)
+		err = server.Listener(ln)
+		listening.Set(false)
+		if err != nil {
 			stop(err)
 		}
 	}()
-	return await()
+
+	err = await()
+
+	// останавливаем фоновые процессы и ждем, пока relay закончит текущий проход
+	cancel()
+	<-relayDone
+
+	return err
 }
 
 // non REST methods
 
+// pageError отказ в доступе отдаем как есть, остальные ошибки пока показываем как not found
+func pageError(err error) error {
+	if errors.Is(err, auth.ErrForbidden) {
+		return err
+	}
+	return apperror.ErrEndFound
+}
+
+// страница подтверждения удаления
+func buildDeletePageHandler(repo *authz.Repo) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		iid, err := strconv.Atoi(ctx.Params("id"))
+		if err != nil {
+			// убрать после того как добавишь обработку ошибок в ErrorHandler
+			return apperror.ErrEndFound
+		}
+
+		product, err := repo.ReadOne(ctx.UserContext(), iid)
+		if errors.Is(err, auth.ErrForbidden) {
+			return err
+		}
+		if err != nil {
+			return ctx.Status(http.StatusNotFound).SendString("NotFound")
+		}
+
+		return ctx.Render("delete", fiber.Map{
+			"Product": product,
+		})
+	}
+}
+
 // удаление наименований
-func buildDeleteHandler(repo *repository.Repo) fiber.Handler {
+func buildDeleteHandler(repo *authz.Repo) fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
 		id := ctx.Params("id")
 		command, err := commands.NewDeleteCommand(id)
@@ -260,17 +592,17 @@ func buildDeleteHandler(repo *repository.Repo) fiber.Handler {
 			return apperror.ErrEndFound
 		}
 
-		_, err = repo.Delete(ctx.Context(), command)
+		_, err = repo.Delete(ctx.UserContext(), command)
 		if err != nil {
 			// убрать после того как добавишь обработку ошибок в ErrorHandler
-			return apperror.ErrEndFound
+			return pageError(err)
 		}
 
-		return ctx.Redirect("/", 301)
+		return ctx.Redirect("/", fiber.StatusSeeOther)
 	}
 }
 
-func buildEditPageHandler(repo *repository.Repo) fiber.Handler {
+func buildEditPageHandler(repo *authz.Repo) fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
 		id := ctx.Params("id")
 
@@ -280,17 +612,22 @@ func buildEditPageHandler(repo *repository.Repo) fiber.Handler {
 			return apperror.ErrEndFound
 		}
 
-		prod, err := repo.ReadOneWithFeatures(ctx.Context(), iid)
+		prod, err := repo.ReadOneWithFeatures(ctx.UserContext(), iid)
+		if errors.Is(err, auth.ErrForbidden) {
+			return err
+		}
 		if err != nil {
 			return ctx.Status(http.StatusNotFound).SendString("NotFound")
 		}
 
-		return ctx.Render("edit", prod)
+		return ctx.Render("edit", fiber.Map{
+			"Product": prod,
+		})
 	}
 }
 
 // получаем измененные данные и сохраняем их в БД
-func buildEditHandler(repo *repository.Repo) fiber.Handler {
+func buildEditHandler(repo *authz.Repo) fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
 		edit := &EditForm{}
 		if err := ctx.BodyParser(edit); err != nil {
@@ -313,16 +650,16 @@ func buildEditHandler(repo *repository.Repo) fiber.Handler {
 			return apperror.ErrEndFound
 		}
 
-		err = repo.Update(ctx.Context(), updateCommand)
+		err = repo.Update(ctx.UserContext(), updateCommand)
 		if err != nil {
 			// убрать после того как добавишь обработку ошибок в ErrorHandler
-			return apperror.ErrEndFound
+			return pageError(err)
 		}
 		return ctx.Redirect("/", 301)
 	}
 }
 
-func buildCreateHandler(repo *repository.Repo) fiber.Handler {
+func buildCreateHandler(repo *authz.Repo) fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
 		if ctx.Method() == "POST" {
 			creat := &CreatForm{}
@@ -345,10 +682,10 @@ func buildCreateHandler(repo *repository.Repo) fiber.Handler {
 				return apperror.ErrEndFound
 			}
 
-			_, err = repo.Create(ctx.Context(), createCommand)
+			_, err = repo.Create(ctx.UserContext(), createCommand)
 			if err != nil {
 				// убрать после того как добавишь обработку ошибок в ErrorHandler
-				return apperror.ErrEndFound
+				return pageError(err)
 			}
 			return ctx.Redirect("/", 301)
 		}
@@ -357,12 +694,12 @@ func buildCreateHandler(repo *repository.Repo) fiber.Handler {
 	}
 }
 
-func buildIndexHandler(repo *repository.Repo) fiber.Handler {
+func buildIndexHandler(repo *authz.Repo) fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
-		products, err := repo.Read(ctx.Context())
+		products, err := repo.Read(ctx.UserContext())
 		if err != nil {
 			// убрать после того как добавишь обработку ошибок в ErrorHandler
-			return apperror.ErrEndFound
+			return pageError(err)
 		}
 
 		return ctx.Render("index", fiber.Map{
@@ -371,7 +708,7 @@ func buildIndexHandler(repo *repository.Repo) fiber.Handler {
 	}
 }
 
-func buildFeatureHandler(repo *repository.Repo) fiber.Handler {
+func buildFeatureHandler(repo *authz.Repo) fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
 		id := ctx.Params("id")
 
@@ -381,10 +718,10 @@ func buildFeatureHandler(repo *repository.Repo) fiber.Handler {
 			return apperror.ErrEndFound
 		}
 
-		product, err := repo.ReadOneWithFeatures(ctx.Context(), iid)
+		product, err := repo.ReadOneWithFeatures(ctx.UserContext(), iid)
 		if err != nil {
 			// убрать после того как добавишь обработку ошибок в ErrorHandler
-			return apperror.ErrEndFound
+			return pageError(err)
 		}
 
 		return ctx.Render("feature", product)
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/grip211/crud/pkg/session"
+	"github.com/grip211/crud/pkg/tenant"
+	"github.com/grip211/crud/pkg/user"
+)
+
+// тут вход и выход пользователей HTML интерфейса
+
+type LoginForm struct {
+	Username string `form:"username"`
+	Password string `form:"password"`
+	Next     string `form:"next"`
+}
+
+func buildLoginPageHandler() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		return ctx.Render("login", fiber.Map{
+			"Next": session.SafeRedirect(ctx.Query("next")),
+		})
+	}
+}
+
+func buildLoginHandler(users *user.Repo, sessions *session.Manager) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		form := &LoginForm{}
+		if err := ctx.BodyParser(form); err != nil {
+			return err
+		}
+		next := session.SafeRedirect(form.Next)
+
+		account, err := users.Authenticate(ctx.Context(), form.Username, form.Password)
+		if err == nil {
+			// на поддомене другого магазина пользователь не может войти, ответ такой же как на неверный пароль
+			if requested := tenant.RequestedFrom(ctx); requested != "" && requested != account.TenantID {
+				err = user.ErrInvalidCredentials
+			}
+		}
+		if errors.Is(err, user.ErrInvalidCredentials) {
+			return ctx.Status(fiber.StatusUnauthorized).Render("login", fiber.Map{
+				"Next":     next,
+				"Username": form.Username,
+				"Error":    "Неверное имя пользователя или пароль",
+			})
+		}
+		if err != nil {
+			return err
+		}
+
+		if err = sessions.Login(ctx, account.ID); err != nil {
+			return err
+		}
+		return ctx.Redirect(next, fiber.StatusSeeOther)
+	}
+}
+
+func buildLogoutHandler(sessions *session.Manager) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		if err := sessions.Logout(ctx); err != nil {
+			return err
+		}
+		return ctx.Redirect(sessions.LoginPath(), fiber.StatusSeeOther)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/grip211/crud/pkg/tenant"
+)
+
+// тут команды управления арендаторами: crud tenant create|list
+
+func tenantCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "tenant",
+		Usage: "manage tenants, each tenant has its own catalogue",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "create",
+				Usage:     "create a tenant",
+				ArgsUsage: "<id>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "name",
+						Usage: "display name of the tenant, defaults to the id",
+					},
+				},
+				Action: tenantCreate,
+			},
+			{
+				Name:   "list",
+				Usage:  "list tenants",
+				Action: tenantList,
+			},
+		},
+	}
+}
+
+func tenantRepo(ctx *cli.Context) (*tenant.Repo, error) {
+	conn, err := openDatabase(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return tenant.NewRepo(conn), nil
+}
+
+func tenantCreate(ctx *cli.Context) error {
+	id := ctx.Args().First()
+	if id == "" {
+		return errors.New("usage: crud tenant create <id> [--name <name>]")
+	}
+	name := ctx.String("name")
+	if name == "" {
+		name = id
+	}
+
+	repo, err := tenantRepo(ctx)
+	if err != nil {
+		return err
+	}
+
+	created, err := repo.Create(ctx.Context, id, name)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("tenant %s (%s) created\n", created.ID, created.Name)
+	return nil
+}
+
+func tenantList(ctx *cli.Context) error {
+	repo, err := tenantRepo(ctx)
+	if err != nil {
+		return err
+	}
+
+	tenants, err := repo.List(ctx.Context)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tCREATED")
+	for i := range tenants {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", tenants[i].ID, tenants[i].Name, formatTime(&tenants[i].CreatedAt))
+	}
+	return w.Flush()
+}
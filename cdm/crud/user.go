@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/grip211/crud/pkg/auth"
+	"github.com/grip211/crud/pkg/tenant"
+	"github.com/grip211/crud/pkg/user"
+)
+
+// тут команды управления пользователями HTML интерфейса: crud user create|set-role
+
+func userCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "user",
+		Usage: "manage users of the HTML interface",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "create",
+				Usage: "create a user, the password is read from stdin",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "username",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "role",
+						Usage: "viewer, editor, pricing or admin",
+						Value: auth.RoleViewer,
+					},
+					&cli.StringFlag{
+						Name:  "tenant",
+						Usage: "tenant the user belongs to",
+						Value: tenant.Default,
+					},
+				},
+				Action: userCreate,
+			},
+			{
+				Name:  "set-role",
+				Usage: "change the role of a user",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "username",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "role",
+						Usage:    "viewer, editor, pricing or admin",
+						Required: true,
+					},
+				},
+				Action: userSetRole,
+			},
+		},
+	}
+}
+
+func userCreate(ctx *cli.Context) error {
+	fmt.Fprint(os.Stderr, "password: ")
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		return fmt.Errorf("read password: %w", err)
+	}
+	password = strings.TrimRight(password, "\r\n")
+
+	conn, err := openDatabase(ctx)
+	if err != nil {
+		return err
+	}
+
+	account, err := user.NewRepo(conn).Create(ctx.Context, ctx.String("tenant"), ctx.String("username"), password, ctx.String("role"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("user %s created with id %d, role %s in tenant %s\n", account.Username, account.ID, account.Role, account.TenantID)
+	return nil
+}
+
+func userSetRole(ctx *cli.Context) error {
+	conn, err := openDatabase(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err = user.NewRepo(conn).SetRole(ctx.Context, ctx.String("username"), ctx.String("role")); err != nil {
+		return err
+	}
+	fmt.Printf("user %s now has role %s\n", ctx.String("username"), ctx.String("role"))
+	return nil
+}
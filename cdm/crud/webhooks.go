@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/grip211/crud/pkg/apperror"
+	"github.com/grip211/crud/pkg/webhook"
+)
+
+const webhookDeliveriesLimit = 100
+
+type WebhookForm struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Secret     string   `json:"secret"`
+}
+
+// создание подписки на вебхуки, секрет возвращается только в ответе на создание
+// POST http://localhost:8181/api/v1/webhooks
+func buildRestWebhookCreateHandler(store webhook.SubscriptionStore) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		form := &WebhookForm{}
+		if err := ctx.BodyParser(form); err != nil {
+			return err
+		}
+
+		target, err := url.Parse(form.URL)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			return ctx.Status(fiber.StatusBadRequest).JSON(apperror.NewErrorHandler(
+				webhook.ErrInvalidURL, "invalid webhook", webhook.ErrInvalidURL.Error(), "webhook_invalid",
+			))
+		}
+
+		subscription := &webhook.Subscription{
+			URL:        form.URL,
+			EventTypes: form.EventTypes,
+			Secret:     form.Secret,
+			Active:     true,
+		}
+		if subscription.Secret == "" {
+			if subscription.Secret, err = webhook.NewSecret(); err != nil {
+				return err
+			}
+		}
+
+		if subscription.ID, err = store.CreateSubscription(ctx.UserContext(), subscription); err != nil {
+			return err
+		}
+
+		return ctx.Status(fiber.StatusCreated).JSON(subscription)
+	}
+}
+
+func buildRestWebhookListHandler(store webhook.SubscriptionStore) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		subscriptions, err := store.Subscriptions(ctx.UserContext())
+		if err != nil {
+			return err
+		}
+		for i := range subscriptions {
+			subscriptions[i].Secret = ""
+		}
+		return ctx.JSON(subscriptions)
+	}
+}
+
+func buildRestWebhookDeleteHandler(store webhook.SubscriptionStore) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		id, err := strconv.Atoi(ctx.Params("id"))
+		if err != nil {
+			return err
+		}
+
+		err = store.DeleteSubscription(ctx.UserContext(), id)
+		if errors.Is(err, webhook.ErrNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(apperror.ErrEndFound)
+		}
+		if err != nil {
+			return err
+		}
+
+		return ctx.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// журнал доставок подписки
+// GET http://localhost:8181/api/v1/webhooks/:id/deliveries
+func buildRestWebhookDeliveriesHandler(store webhook.SubscriptionStore) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		id, err := strconv.Atoi(ctx.Params("id"))
+		if err != nil {
+			return err
+		}
+
+		deliveries, err := store.Deliveries(ctx.UserContext(), id, webhookDeliveriesLimit)
+		if err != nil {
+			return err
+		}
+
+		return ctx.JSON(deliveries)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grip211/crud/pkg/tenant"
+	"github.com/grip211/crud/pkg/webhook"
+)
+
+type knownTenants map[string]bool
+
+func (l knownTenants) Exists(_ context.Context, id string) (bool, error) {
+	return l[id], nil
+}
+
+// memoryWebhooks хранит подписки по арендатору из ctx, как и webhook.Repo
+type memoryWebhooks struct {
+	subscriptions map[string][]webhook.Subscription
+}
+
+func (m *memoryWebhooks) CreateSubscription(ctx context.Context, subscription *webhook.Subscription) (int, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return 0, err
+	}
+	subscription.ID = len(m.subscriptions[tenantID]) + 1
+	m.subscriptions[tenantID] = append(m.subscriptions[tenantID], *subscription)
+	return subscription.ID, nil
+}
+
+func (m *memoryWebhooks) Subscriptions(ctx context.Context) ([]webhook.Subscription, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return m.subscriptions[tenantID], nil
+}
+
+func (m *memoryWebhooks) DeleteSubscription(ctx context.Context, _ int) error {
+	_, err := tenant.Require(ctx)
+	return err
+}
+
+func (m *memoryWebhooks) Deliveries(ctx context.Context, _, _ int) ([]webhook.Delivery, error) {
+	_, err := tenant.Require(ctx)
+	return nil, err
+}
+
+func TestWebhookHandlers(t *testing.T) {
+	store := &memoryWebhooks{subscriptions: map[string][]webhook.Subscription{}}
+	resolver := tenant.NewResolver(knownTenants{tenant.Default: true, "shop-a": true}, tenant.Options{Default: tenant.Default})
+
+	app := fiber.New()
+	app.Use(resolver.Requested(), resolver.Enforce())
+	app.Post("/webhooks", buildRestWebhookCreateHandler(store))
+	app.Get("/webhooks", buildRestWebhookListHandler(store))
+	app.Delete("/webhooks/:id", buildRestWebhookDeleteHandler(store))
+	app.Get("/webhooks/:id/deliveries", buildRestWebhookDeliveriesHandler(store))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		tenant string
+		body   string
+		status int
+		want   string
+	}{
+		{
+			name:   "create",
+			method: fiber.MethodPost,
+			path:   "/webhooks",
+			tenant: "shop-a",
+			body:   `{"url":"https://hooks.example/a","secret":"s"}`,
+			status: fiber.StatusCreated,
+		},
+		{name: "list of the tenant", method: fiber.MethodGet, path: "/webhooks", tenant: "shop-a", status: fiber.StatusOK, want: "hooks.example/a"},
+		{name: "other tenant sees nothing", method: fiber.MethodGet, path: "/webhooks", status: fiber.StatusOK, want: "null"},
+		{name: "delete", method: fiber.MethodDelete, path: "/webhooks/1", tenant: "shop-a", status: fiber.StatusNoContent},
+		{name: "deliveries", method: fiber.MethodGet, path: "/webhooks/1/deliveries", tenant: "shop-a", status: fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			if tt.tenant != "" {
+				req.Header.Set(tenant.HeaderTenant, tt.tenant)
+			}
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, tt.status, resp.StatusCode, string(body))
+			require.Contains(t, string(body), tt.want)
+		})
+	}
+}
@@ -0,0 +1,13 @@
+use productdb;
+
+create table productdb.IdempotencyKeys
+(
+    idempotency_key varchar(255) primary key,
+    fingerprint     char(64)     not null,
+    status          int          not null default 0,
+    content_type    varchar(255) not null default '',
+    body            mediumblob,
+    created_at      datetime     not null default current_timestamp,
+    expires_at      datetime     not null,
+    index (expires_at)
+);
@@ -0,0 +1,29 @@
+use productdb;
+
+create table productdb.WebhookSubscriptions
+(
+    id          int auto_increment primary key,
+    url         varchar(2048) not null,
+    event_types varchar(255)  not null default '',
+    secret      varchar(255)  not null,
+    active      bool          not null default true,
+    created_at  datetime      not null default current_timestamp
+);
+
+create table productdb.WebhookDeliveries
+(
+    id               bigint auto_increment primary key,
+    subscription_id  int          not null,
+    event_id         bigint       not null,
+    event_type       varchar(64)  not null,
+    payload          mediumblob   not null,
+    status           varchar(16)  not null default 'pending',
+    attempts         int          not null default 0,
+    next_attempt_at  datetime     not null default current_timestamp,
+    last_status_code int          not null default 0,
+    last_error       varchar(1024) not null default '',
+    created_at       datetime     not null default current_timestamp,
+    delivered_at     datetime     null,
+    index (status, next_attempt_at),
+    CONSTRAINT fk_webhook_subscription_id FOREIGN KEY (subscription_id) REFERENCES WebhookSubscriptions(id) ON DELETE CASCADE
+);
@@ -0,0 +1,14 @@
+use productdb;
+
+create table productdb.Outbox
+(
+    id           bigint auto_increment primary key,
+    product_id   int           not null,
+    event_type   varchar(64)   not null,
+    payload      mediumblob    not null,
+    attempts     int           not null default 0,
+    last_error   varchar(1024) not null default '',
+    created_at   datetime      not null default current_timestamp,
+    published_at datetime      null,
+    index (published_at, id)
+);
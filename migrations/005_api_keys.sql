@@ -0,0 +1,15 @@
+use productdb;
+
+create table productdb.ApiKeys
+(
+    id           int auto_increment primary key,
+    name         varchar(255) not null,
+    prefix       char(8)      not null,
+    hash         char(64)     not null,
+    scope        varchar(16)  not null,
+    expires_at   datetime     null,
+    last_used_at datetime     null,
+    revoked_at   datetime     null,
+    created_at   datetime     not null default current_timestamp,
+    UNIQUE KEY   (prefix)
+);
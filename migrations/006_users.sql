@@ -0,0 +1,21 @@
+use productdb;
+
+create table productdb.Users
+(
+    id            int auto_increment primary key,
+    username      varchar(64)  not null,
+    password_hash varchar(255) not null,
+    created_at    datetime     not null default current_timestamp,
+    UNIQUE KEY    (username)
+);
+
+create table productdb.Sessions
+(
+    id         char(64)  not null primary key,
+    user_id    int       not null,
+    csrf_token char(64)  not null,
+    expires_at datetime  not null,
+    created_at datetime  not null default current_timestamp,
+    index (expires_at),
+    CONSTRAINT fk_session_user_id FOREIGN KEY (user_id) REFERENCES Users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,7 @@
+use productdb;
+
+alter table productdb.Users
+    add role varchar(16) not null default 'viewer';
+
+alter table productdb.ApiKeys
+    add role varchar(16) not null default '';
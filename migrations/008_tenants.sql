@@ -0,0 +1,39 @@
+use productdb;
+
+create table productdb.Tenants
+(
+    id         varchar(63)  not null primary key,
+    name       varchar(255) not null default '',
+    created_at datetime     not null default current_timestamp
+);
+
+-- существующие данные принадлежат арендатору по умолчанию
+insert into productdb.Tenants (id, name)
+values ('default', 'Default');
+
+alter table productdb.Products
+    add tenant_id varchar(63) not null default 'default' after id,
+    add index (tenant_id, id),
+    add CONSTRAINT fk_product_tenant_id FOREIGN KEY (tenant_id) REFERENCES Tenants(id);
+
+alter table productdb.ProductsFeatures
+    add tenant_id varchar(63) not null default 'default' after id,
+    add index (tenant_id, product_id),
+    add CONSTRAINT fk_product_feature_tenant_id FOREIGN KEY (tenant_id) REFERENCES Tenants(id);
+
+alter table productdb.ApiKeys
+    add tenant_id varchar(63) not null default 'default',
+    add CONSTRAINT fk_api_key_tenant_id FOREIGN KEY (tenant_id) REFERENCES Tenants(id);
+
+alter table productdb.Users
+    add tenant_id varchar(63) not null default 'default',
+    add CONSTRAINT fk_user_tenant_id FOREIGN KEY (tenant_id) REFERENCES Tenants(id);
+
+alter table productdb.WebhookSubscriptions
+    add tenant_id varchar(63) not null default 'default',
+    add index (tenant_id),
+    add CONSTRAINT fk_webhook_subscription_tenant_id FOREIGN KEY (tenant_id) REFERENCES Tenants(id);
+
+-- ключи идемпотентности хранятся с префиксом арендатора "<tenant>:<key>"
+alter table productdb.IdempotencyKeys
+    modify idempotency_key varchar(320) not null;
@@ -0,0 +1,12 @@
+use productdb;
+
+-- версия схемы, /readyz сравнивает ее с последней миграцией, известной сервису.
+-- каждая следующая миграция добавляет сюда свой номер
+create table productdb.SchemaMigrations
+(
+    version    int primary key,
+    applied_at datetime not null default current_timestamp
+);
+
+insert into productdb.SchemaMigrations (version)
+values (9);
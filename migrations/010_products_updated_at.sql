@@ -0,0 +1,9 @@
+use productdb;
+
+-- время последнего изменения товара для Last-Modified. Изменение одних характеристик
+-- строку Products не трогает, поэтому репозиторий выставляет updated_at явно
+alter table productdb.Products
+    add updated_at datetime not null default current_timestamp on update current_timestamp;
+
+insert into productdb.SchemaMigrations (version)
+values (10);
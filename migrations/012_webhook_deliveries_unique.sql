@@ -0,0 +1,16 @@
+use productdb;
+
+-- relay повторяет событие целиком, если не принял любой из получателей, поэтому одна и та же
+-- доставка может быть поставлена в очередь несколько раз. Дубли убираем, новые отбрасывает ключ
+delete d
+from productdb.WebhookDeliveries d
+         join productdb.WebhookDeliveries kept
+              on kept.subscription_id = d.subscription_id
+                  and kept.event_id = d.event_id
+                  and kept.id < d.id;
+
+alter table productdb.WebhookDeliveries
+    add unique key uq_webhook_delivery_event (subscription_id, event_id);
+
+insert into productdb.SchemaMigrations (version)
+values (12);
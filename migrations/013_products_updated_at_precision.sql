@@ -0,0 +1,9 @@
+use productdb;
+
+-- с точностью до секунды два изменения в одну секунду неразличимы по updated_at,
+-- и If-Modified-Since отдал бы 304 со старым содержимым
+alter table productdb.Products
+    modify updated_at datetime(6) not null default current_timestamp(6) on update current_timestamp(6);
+
+insert into productdb.SchemaMigrations (version)
+values (13);
@@ -0,0 +1,49 @@
+package migrations
+
+import (
+	"embed"
+	"strconv"
+	"strings"
+)
+
+// тут SQL миграции, встроенные в бинарник, чтобы сервис знал, до какой версии должна быть обновлена схема.
+// Номер миграции это число в начале имени файла, init.sql первая. В postgres те же миграции для PostgreSQL
+// с теми же номерами, поэтому версия схемы не зависит от диалекта
+
+//go:embed *.sql postgres/*.sql
+var files embed.FS
+
+// Version номер последней миграции
+func Version() int {
+	return version(".")
+}
+
+func version(dir string) int {
+	entries, err := files.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+
+	version := 0
+	for _, entry := range entries {
+		if n := number(entry.Name()); n > version {
+			version = n
+		}
+	}
+	return version
+}
+
+func number(name string) int {
+	if name == "init.sql" {
+		return 1
+	}
+	prefix, _, ok := strings.Cut(name, "_")
+	if !ok {
+		return 0
+	}
+	n, err := strconv.Atoi(prefix)
+	if err != nil {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNumber(t *testing.T) {
+	tests := []struct {
+		name string
+		want int
+	}{
+		{name: "init.sql", want: 1},
+		{name: "002_idempotency_keys.sql", want: 2},
+		{name: "010_something.sql", want: 10},
+		{name: "notes.sql", want: 0},
+		{name: "draft_table.sql", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, number(tt.name))
+		})
+	}
+}
+
+func TestVersion(t *testing.T) {
+	require.GreaterOrEqual(t, Version(), 10)
+}
+
+func TestPostgres(t *testing.T) {
+	numbers := func(dir string) []int {
+		entries, err := files.ReadDir(dir)
+		require.NoError(t, err)
+
+		var list []int
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				list = append(list, number(entry.Name()))
+			}
+		}
+		return list
+	}
+
+	require.Equal(t, numbers("."), numbers("postgres"))
+	require.Equal(t, Version(), version("postgres"))
+}
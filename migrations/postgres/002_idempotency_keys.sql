@@ -0,0 +1,13 @@
+create table productdb."IdempotencyKeys"
+(
+    idempotency_key varchar(255) primary key,
+    fingerprint     char(64)     not null,
+    status          int          not null default 0,
+    content_type    varchar(255) not null default '',
+    -- goqu подставляет []byte строковым литералом, поэтому text, а не bytea
+    body            text,
+    created_at      timestamptz  not null default now(),
+    expires_at      timestamptz  not null
+);
+
+create index on productdb."IdempotencyKeys" (expires_at);
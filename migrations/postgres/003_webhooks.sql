@@ -0,0 +1,28 @@
+create table productdb."WebhookSubscriptions"
+(
+    id          serial primary key,
+    url         varchar(2048) not null,
+    event_types varchar(255)  not null default '',
+    secret      varchar(255)  not null,
+    active      boolean       not null default true,
+    created_at  timestamptz   not null default now()
+);
+
+create table productdb."WebhookDeliveries"
+(
+    id               bigserial primary key,
+    subscription_id  int           not null,
+    event_id         bigint        not null,
+    event_type       varchar(64)   not null,
+    payload          text          not null,
+    status           varchar(16)   not null default 'pending',
+    attempts         int           not null default 0,
+    next_attempt_at  timestamptz   not null default now(),
+    last_status_code int           not null default 0,
+    last_error       varchar(1024) not null default '',
+    created_at       timestamptz   not null default now(),
+    delivered_at     timestamptz   null,
+    constraint fk_webhook_subscription_id foreign key (subscription_id) references productdb."WebhookSubscriptions" (id) on delete cascade
+);
+
+create index on productdb."WebhookDeliveries" (status, next_attempt_at);
@@ -0,0 +1,13 @@
+create table productdb."Outbox"
+(
+    id           bigserial primary key,
+    product_id   int           not null,
+    event_type   varchar(64)   not null,
+    payload      text          not null,
+    attempts     int           not null default 0,
+    last_error   varchar(1024) not null default '',
+    created_at   timestamptz   not null default now(),
+    published_at timestamptz   null
+);
+
+create index on productdb."Outbox" (published_at, id);
@@ -0,0 +1,12 @@
+create table productdb."ApiKeys"
+(
+    id           serial primary key,
+    name         varchar(255) not null,
+    prefix       char(8)      not null unique,
+    hash         char(64)     not null,
+    scope        varchar(16)  not null,
+    expires_at   timestamptz  null,
+    last_used_at timestamptz  null,
+    revoked_at   timestamptz  null,
+    created_at   timestamptz  not null default now()
+);
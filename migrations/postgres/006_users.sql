@@ -0,0 +1,19 @@
+create table productdb."Users"
+(
+    id            serial primary key,
+    username      varchar(64)  not null unique,
+    password_hash varchar(255) not null,
+    created_at    timestamptz  not null default now()
+);
+
+create table productdb."Sessions"
+(
+    id         char(64)    not null primary key,
+    user_id    int         not null,
+    csrf_token char(64)    not null,
+    expires_at timestamptz not null,
+    created_at timestamptz not null default now(),
+    constraint fk_session_user_id foreign key (user_id) references productdb."Users" (id) on delete cascade
+);
+
+create index on productdb."Sessions" (expires_at);
@@ -0,0 +1,5 @@
+alter table productdb."Users"
+    add role varchar(16) not null default 'viewer';
+
+alter table productdb."ApiKeys"
+    add role varchar(16) not null default '';
@@ -0,0 +1,40 @@
+create table productdb."Tenants"
+(
+    id         varchar(63)  not null primary key,
+    name       varchar(255) not null default '',
+    created_at timestamptz  not null default now()
+);
+
+-- существующие данные принадлежат арендатору по умолчанию
+insert into productdb."Tenants" (id, name)
+values ('default', 'Default');
+
+alter table productdb."Products"
+    add tenant_id varchar(63) not null default 'default',
+    add constraint fk_product_tenant_id foreign key (tenant_id) references productdb."Tenants" (id);
+
+create index on productdb."Products" (tenant_id, id);
+
+alter table productdb."ProductsFeatures"
+    add tenant_id varchar(63) not null default 'default',
+    add constraint fk_product_feature_tenant_id foreign key (tenant_id) references productdb."Tenants" (id);
+
+create index on productdb."ProductsFeatures" (tenant_id, product_id);
+
+alter table productdb."ApiKeys"
+    add tenant_id varchar(63) not null default 'default',
+    add constraint fk_api_key_tenant_id foreign key (tenant_id) references productdb."Tenants" (id);
+
+alter table productdb."Users"
+    add tenant_id varchar(63) not null default 'default',
+    add constraint fk_user_tenant_id foreign key (tenant_id) references productdb."Tenants" (id);
+
+alter table productdb."WebhookSubscriptions"
+    add tenant_id varchar(63) not null default 'default',
+    add constraint fk_webhook_subscription_tenant_id foreign key (tenant_id) references productdb."Tenants" (id);
+
+create index on productdb."WebhookSubscriptions" (tenant_id);
+
+-- ключи идемпотентности хранятся с префиксом арендатора "<tenant>:<key>"
+alter table productdb."IdempotencyKeys"
+    alter column idempotency_key type varchar(320);
@@ -0,0 +1,10 @@
+-- версия схемы, /readyz сравнивает ее с последней миграцией, известной сервису.
+-- каждая следующая миграция добавляет сюда свой номер
+create table productdb."SchemaMigrations"
+(
+    version    int primary key,
+    applied_at timestamptz not null default now()
+);
+
+insert into productdb."SchemaMigrations" (version)
+values (9);
@@ -0,0 +1,7 @@
+-- время последнего изменения товара для Last-Modified. ON UPDATE в PostgreSQL нет,
+-- репозиторий выставляет updated_at явно при каждом изменении
+alter table productdb."Products"
+    add updated_at timestamptz not null default now();
+
+insert into productdb."SchemaMigrations" (version)
+values (10);
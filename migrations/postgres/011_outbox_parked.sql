@@ -0,0 +1,7 @@
+-- сообщения, которые не удалось опубликовать за отведенное число попыток, откладываются:
+-- relay их больше не берет, и они не держат следующие события товара
+alter table productdb."Outbox"
+    add parked_at timestamptz null;
+
+insert into productdb."SchemaMigrations" (version)
+values (11);
@@ -0,0 +1,14 @@
+-- relay повторяет событие целиком, если не принял любой из получателей, поэтому одна и та же
+-- доставка может быть поставлена в очередь несколько раз. Дубли убираем, новые отбрасывает ключ
+delete
+from productdb."WebhookDeliveries" d
+    using productdb."WebhookDeliveries" kept
+where kept.subscription_id = d.subscription_id
+  and kept.event_id = d.event_id
+  and kept.id < d.id;
+
+alter table productdb."WebhookDeliveries"
+    add constraint uq_webhook_delivery_event unique (subscription_id, event_id);
+
+insert into productdb."SchemaMigrations" (version)
+values (12);
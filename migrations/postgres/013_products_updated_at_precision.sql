@@ -0,0 +1,4 @@
+-- в MySQL updated_at переводится на микросекунды, timestamptz в PostgreSQL уже хранит их,
+-- миграция только держит номер версии схемы одинаковым для обоих диалектов
+insert into productdb."SchemaMigrations" (version)
+values (13);
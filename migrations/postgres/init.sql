@@ -0,0 +1,30 @@
+-- PostgreSQL вариант миграций: таблицы лежат в схеме productdb, имена в кавычках,
+-- потому что goqu всегда их экранирует и регистр должен совпадать с MySQL
+create schema productdb;
+
+create table productdb."Products"
+(
+    id       serial primary key,
+    model    varchar(30)    not null,
+    company  varchar(30)    not null,
+    quantity int            not null default 0,
+    price    numeric(10, 0) not null
+);
+
+insert into productdb."Products" (model, company, quantity, price)
+values ('iPhone X', 'Apple', 74, 10000),
+       ('Pixel 2', 'Google', 62, 22000),
+       ('Galaxy S9', 'Samsung', 65, 22000),
+       ('Xaiomi', 'redmi', 37, 23000),
+       ('S21', 'Samsung', 22, 21222);
+
+create table productdb."ProductsFeatures"
+(
+    id           serial primary key,
+    product_id   int            not null unique,
+    cpu          int            not null,
+    memory       int            not null,
+    display_size int                     default 0,
+    camera       numeric(10, 0) not null,
+    constraint fk_product_id foreign key (product_id) references productdb."Products" (id) on delete cascade
+);
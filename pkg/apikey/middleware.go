@@ -0,0 +1,66 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/grip211/crud/pkg/auth"
+)
+
+const (
+	HeaderKey = "X-API-Key"
+
+	authorizationScheme = "ApiKey "
+)
+
+type Store interface {
+	Authenticate(ctx context.Context, plain string) (*Key, error)
+}
+
+type Authenticator struct {
+	store Store
+}
+
+// NewAuthenticator аутентификация по API ключу в заголовке X-API-Key или Authorization: ApiKey <key>
+func NewAuthenticator(store Store) *Authenticator {
+	return &Authenticator{
+		store: store,
+	}
+}
+
+func (a *Authenticator) Authenticate(ctx *fiber.Ctx) (*auth.Principal, error) {
+	plain := extract(ctx)
+	if plain == "" {
+		return nil, auth.ErrNoCredentials
+	}
+
+	key, err := a.store.Authenticate(ctx.Context(), plain)
+	switch {
+	case errors.Is(err, ErrInvalidKey), errors.Is(err, ErrRevoked), errors.Is(err, ErrExpired):
+		return nil, fmt.Errorf("%w: %w", auth.ErrInvalidCredentials, err)
+	case err != nil:
+		return nil, err
+	}
+
+	return key.Principal(), nil
+}
+
+// New возвращает middleware, которое требует API ключ
+func New(store Store) fiber.Handler {
+	return auth.New(NewAuthenticator(store))
+}
+
+func extract(ctx *fiber.Ctx) string {
+	if key := ctx.Get(HeaderKey); key != "" {
+		return key
+	}
+	header := ctx.Get(fiber.HeaderAuthorization)
+	if len(header) > len(authorizationScheme) && strings.EqualFold(header[:len(authorizationScheme)], authorizationScheme) {
+		return strings.TrimSpace(header[len(authorizationScheme):])
+	}
+	return ""
+}
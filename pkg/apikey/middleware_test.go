@@ -0,0 +1,69 @@
+package apikey
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grip211/crud/pkg/auth"
+)
+
+type memoryAuthenticator map[string]*Key
+
+func (m memoryAuthenticator) Authenticate(_ context.Context, plain string) (*Key, error) {
+	key, ok := m[plain]
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	if key.RevokedAt != nil {
+		return nil, ErrRevoked
+	}
+	return key, nil
+}
+
+func TestNew(t *testing.T) {
+	authenticator := memoryAuthenticator{
+		"reader": {ID: 1, Name: "reader", Scope: auth.ScopeRead},
+		"writer": {ID: 2, Name: "writer", Scope: auth.ScopeWrite},
+	}
+
+	app := fiber.New()
+	app.Use(New(authenticator))
+	handler := func(ctx *fiber.Ctx) error {
+		principal, ok := auth.PrincipalFrom(ctx)
+		require.True(t, ok)
+		return ctx.SendString(principal.Name)
+	}
+	app.Get("/products", handler)
+	app.Post("/products", handler)
+
+	tests := []struct {
+		name   string
+		method string
+		header string
+		value  string
+		status int
+	}{
+		{name: "missing key", method: fiber.MethodGet, status: fiber.StatusUnauthorized},
+		{name: "unknown key", method: fiber.MethodGet, header: HeaderKey, value: "other", status: fiber.StatusUnauthorized},
+		{name: "read with read scope", method: fiber.MethodGet, header: HeaderKey, value: "reader", status: fiber.StatusOK},
+		{name: "write with read scope", method: fiber.MethodPost, header: HeaderKey, value: "reader", status: fiber.StatusForbidden},
+		{name: "write with write scope", method: fiber.MethodPost, header: HeaderKey, value: "writer", status: fiber.StatusOK},
+		{name: "authorization header", method: fiber.MethodGet, header: fiber.HeaderAuthorization, value: "ApiKey reader", status: fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/products", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			require.Equal(t, tt.status, resp.StatusCode)
+		})
+	}
+}
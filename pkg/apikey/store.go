@@ -0,0 +1,215 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	builder "github.com/doug-martin/goqu/v9"
+
+	"github.com/grip211/crud/pkg/auth"
+	"github.com/grip211/crud/pkg/database"
+)
+
+// тут храним API ключи в таблице ApiKeys. Сам ключ не хранится, только его sha256,
+// ключ имеет вид crud_<prefix>_<secret>, по prefix ищем запись, по хешу проверяем
+
+const (
+	keyPrefix    = "crud"
+	prefixLength = 8
+	secretLength = 32
+
+	// touchInterval как часто обновлять last_used_at, чтобы не писать в базу на каждый запрос
+	touchInterval = time.Minute
+)
+
+var (
+	ErrNotFound     = errors.New("api key not found")
+	ErrInvalidKey   = errors.New("invalid api key")
+	ErrExpired      = errors.New("api key expired")
+	ErrRevoked      = errors.New("api key revoked")
+	ErrInvalidScope = errors.New("api key scope must be one of read, write, admin")
+	ErrInvalidRole  = errors.New("api key role must be one of viewer, editor, pricing, admin and fit the scope")
+)
+
+type Key struct {
+	ID         int        `db:"id" json:"id"`
+	Name       string     `db:"name" json:"name"`
+	Prefix     string     `db:"prefix" json:"prefix"`
+	Hash       string     `db:"hash" json:"-"`
+	Scope      string     `db:"scope" json:"scope"`
+	Role       string     `db:"role" json:"role,omitempty"`
+	TenantID   string     `db:"tenant_id" json:"tenant"`
+	ExpiresAt  *time.Time `db:"expires_at" json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `db:"last_used_at" json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+}
+
+func (k *Key) Principal() *auth.Principal {
+	return &auth.Principal{
+		Method: auth.MethodAPIKey,
+		ID:     fmt.Sprintf("%d", k.ID),
+		Name:   k.Name,
+		Scope:  k.Scope,
+		Role:   k.Role,
+		Tenant: k.TenantID,
+	}
+}
+
+type Repo struct {
+	db database.Pool
+}
+
+func NewRepo(db database.Pool) *Repo {
+	return &Repo{
+		db: db,
+	}
+}
+
+// Create выпускает новый ключ арендатора tenantID, открытый ключ возвращается только здесь.
+// Пустая роль значит роль по scope
+func (r *Repo) Create(ctx context.Context, tenantID, name, scope, role string, expiresAt *time.Time) (string, *Key, error) {
+	if !auth.ValidScope(scope) {
+		return "", nil, ErrInvalidScope
+	}
+	if role != "" && (!auth.ValidRole(role) || !auth.ScopeAllows(scope, auth.RoleScope(role))) {
+		return "", nil, ErrInvalidRole
+	}
+
+	prefix, err := randomHex(prefixLength / 2)
+	if err != nil {
+		return "", nil, err
+	}
+	secret, err := randomHex(secretLength / 2)
+	if err != nil {
+		return "", nil, err
+	}
+	plain := fmt.Sprintf("%s_%s_%s", keyPrefix, prefix, secret)
+
+	key := &Key{
+		Name:      name,
+		Prefix:    prefix,
+		Hash:      hash(plain),
+		Scope:     scope,
+		Role:      role,
+		TenantID:  tenantID,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+
+	id, err := database.InsertID(ctx, r.db.Builder().
+		Insert(database.Table(r.db, "ApiKeys")).
+		Rows(builder.Record{
+			"name":       key.Name,
+			"prefix":     key.Prefix,
+			"hash":       key.Hash,
+			"scope":      key.Scope,
+			"role":       key.Role,
+			"tenant_id":  key.TenantID,
+			"expires_at": key.ExpiresAt,
+		}))
+	if err != nil {
+		return "", nil, fmt.Errorf("insert api key: %w", err)
+	}
+	key.ID = int(id)
+
+	return plain, key, nil
+}
+
+func (r *Repo) List(ctx context.Context) ([]Key, error) {
+	var keys []Key
+	err := r.db.Builder().
+		From(database.Table(r.db, "ApiKeys")).
+		Order(builder.C("id").Asc()).
+		ScanStructsContext(ctx, &keys)
+	if err != nil {
+		return nil, fmt.Errorf("fetch api keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (r *Repo) Revoke(ctx context.Context, id int) error {
+	res, err := r.db.Builder().
+		Update(database.Table(r.db, "ApiKeys")).
+		Set(builder.Record{"revoked_at": time.Now()}).
+		Where(
+			builder.C("id").Eq(id),
+			builder.C("revoked_at").IsNull(),
+		).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("revoke api key: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("revoke api key: %w", err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Authenticate проверяет открытый ключ и возвращает его запись
+func (r *Repo) Authenticate(ctx context.Context, plain string) (*Key, error) {
+	parts := strings.Split(plain, "_")
+	if len(parts) != 3 || parts[0] != keyPrefix || len(parts[1]) != prefixLength {
+		return nil, ErrInvalidKey
+	}
+
+	var key Key
+	found, err := r.db.Builder().
+		From(database.Table(r.db, "ApiKeys")).
+		Where(builder.C("prefix").Eq(parts[1])).
+		ScanStructContext(ctx, &key)
+	if err != nil {
+		return nil, fmt.Errorf("fetch api key: %w", err)
+	}
+	if !found || subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hash(plain))) != 1 {
+		return nil, ErrInvalidKey
+	}
+
+	now := time.Now()
+	if key.RevokedAt != nil {
+		return nil, ErrRevoked
+	}
+	if key.ExpiresAt != nil && key.ExpiresAt.Before(now) {
+		return nil, ErrExpired
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > touchInterval {
+		_, err = r.db.Builder().
+			Update(database.Table(r.db, "ApiKeys")).
+			Set(builder.Record{"last_used_at": now}).
+			Where(builder.C("id").Eq(key.ID)).
+			Executor().
+			ExecContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("touch api key: %w", err)
+		}
+		key.LastUsedAt = &now
+	}
+
+	return &key, nil
+}
+
+func hash(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -5,11 +5,13 @@ import (
 )
 
 var (
-	ErrEndFound = NewErrorHandler(nil, "not found", "", "")
+	ErrEndFound     = NewErrorHandler(nil, "not found", "", "")
+	ErrUnauthorized = NewErrorHandler(nil, "unauthorized", "missing or invalid credentials", "unauthorized")
+	ErrForbidden    = NewErrorHandler(nil, "forbidden", "credentials do not allow this operation", "forbidden")
 )
 
 type ErrorHandler struct {
-	Err              error  `json:"_"`
+	Err              error  `json:"-"`
 	Message          string `json:"message,omitempty"`
 	DeveloperMessage string `json:"developer_message,omitempty"`
 	Code             string `json:"code,omitempty"`
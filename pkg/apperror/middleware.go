@@ -0,0 +1,30 @@
+package apperror
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+const localsKey = "apperror.handled"
+
+// Resolve middleware один раз превращает ошибку обработчика в ответ через ErrorHandler приложения.
+// Ставится после метрик, трассировки и логов: им остается прочитать готовый статус ответа,
+// а исходную ошибку они берут из Handled
+func Resolve() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		err := ctx.Next()
+		if err == nil {
+			return nil
+		}
+		ctx.Locals(localsKey, err)
+		if err = ctx.App().ErrorHandler(ctx, err); err != nil {
+			_ = ctx.SendStatus(fiber.StatusInternalServerError)
+		}
+		return nil
+	}
+}
+
+// Handled ошибка обработчика, которую Resolve превратил в ответ, nil если ее не было
+func Handled(ctx *fiber.Ctx) error {
+	err, _ := ctx.Locals(localsKey).(error)
+	return err
+}
@@ -0,0 +1,71 @@
+package apperror
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolve(t *testing.T) {
+	errFailed := errors.New("failed")
+	calls := 0
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: func(ctx *fiber.Ctx, err error) error {
+			calls++
+			if errors.Is(err, errFailed) {
+				return ctx.Status(fiber.StatusConflict).SendString("conflict")
+			}
+			return err
+		},
+	})
+	var handled error
+	app.Use(func(ctx *fiber.Ctx) error {
+		err := ctx.Next()
+		handled = Handled(ctx)
+		return err
+	})
+	app.Use(Resolve())
+	app.Get("/ok", func(ctx *fiber.Ctx) error {
+		return ctx.SendString("ok")
+	})
+	app.Get("/fail", func(ctx *fiber.Ctx) error {
+		return errFailed
+	})
+	app.Get("/broken", func(ctx *fiber.Ctx) error {
+		return fiber.ErrBadGateway
+	})
+
+	tests := []struct {
+		name        string
+		path        string
+		wantStatus  int
+		wantBody    string
+		wantHandled error
+		wantCalls   int
+	}{
+		{name: "no error", path: "/ok", wantStatus: fiber.StatusOK, wantBody: "ok"},
+		{name: "error handler response", path: "/fail", wantStatus: fiber.StatusConflict, wantBody: "conflict", wantHandled: errFailed, wantCalls: 1},
+		{name: "error handler fails", path: "/broken", wantStatus: fiber.StatusInternalServerError, wantBody: "Internal Server Error", wantHandled: fiber.ErrBadGateway, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls, handled = 0, nil
+
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, tt.path, nil))
+			require.NoError(t, err)
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantBody, string(body))
+			require.Equal(t, tt.wantHandled, handled)
+			// ошибка обработана ровно один раз, до приложения она не доходит
+			require.Equal(t, tt.wantCalls, calls)
+		})
+	}
+}
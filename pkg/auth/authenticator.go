@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/grip211/crud/pkg/apperror"
+)
+
+var (
+	// ErrNoCredentials в запросе нет данных для этого способа аутентификации, пробуем следующий
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials данные есть, но они неверные, просрочены или отозваны
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Authenticator один способ аутентификации: API ключ, JWT и т.д.
+type Authenticator interface {
+	Authenticate(ctx *fiber.Ctx) (*Principal, error)
+}
+
+// New возвращает middleware, которое пробует способы аутентификации по очереди.
+// Без принципала запрос получает 401, с недостаточным scope для метода 403:
+// для GET/HEAD достаточно read, для остальных методов нужен write
+func New(authenticators ...Authenticator) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		for _, authenticator := range authenticators {
+			principal, err := authenticator.Authenticate(ctx)
+			switch {
+			case errors.Is(err, ErrNoCredentials):
+				continue
+			case errors.Is(err, ErrInvalidCredentials):
+				return ctx.Status(fiber.StatusUnauthorized).JSON(apperror.NewErrorHandler(
+					err, "unauthorized", err.Error(), "unauthorized",
+				))
+			case err != nil:
+				return err
+			}
+
+			if !principal.Allows(MethodScope(ctx.Method())) {
+				return ctx.Status(fiber.StatusForbidden).JSON(apperror.ErrForbidden)
+			}
+
+			SetPrincipal(ctx, principal)
+			return ctx.Next()
+		}
+
+		return ctx.Status(fiber.StatusUnauthorized).JSON(apperror.ErrUnauthorized)
+	}
+}
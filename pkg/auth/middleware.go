@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/grip211/crud/pkg/apperror"
+)
+
+// Require пропускает запрос, только если принципал в запросе имеет scope не ниже требуемого
+func Require(scope string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		principal, ok := PrincipalFrom(ctx)
+		if !ok {
+			return ctx.Status(fiber.StatusUnauthorized).JSON(apperror.ErrUnauthorized)
+		}
+		if !principal.Allows(scope) {
+			return ctx.Status(fiber.StatusForbidden).JSON(apperror.ErrForbidden)
+		}
+		return ctx.Next()
+	}
+}
+
+// Permit пропускает запрос, только если у принципала есть право permission
+func Permit(permission Permission) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		principal, ok := PrincipalFrom(ctx)
+		if !ok {
+			return ctx.Status(fiber.StatusUnauthorized).JSON(apperror.ErrUnauthorized)
+		}
+		if !principal.Can(permission) {
+			return ctx.Status(fiber.StatusForbidden).JSON(apperror.ErrForbidden)
+		}
+		return ctx.Next()
+	}
+}
+
+// MethodScope scope, нужный для метода: чтение для безопасных методов, запись для остальных
+func MethodScope(method string) string {
+	switch method {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		return ScopeRead
+	default:
+		return ScopeWrite
+	}
+}
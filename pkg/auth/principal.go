@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/grip211/crud/pkg/logging"
+)
+
+// тут описываем того, кто выполняет запрос, его кладут в контекст middleware аутентификации
+
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+
+	MethodAPIKey  = "apikey"
+	MethodSession = "session"
+
+	localsKey = "auth.principal"
+)
+
+type Principal struct {
+	// Method способ аутентификации
+	Method string `json:"method"`
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Scope  string `json:"scope"`
+	// Role роль для проверки прав на каталог, если пустая берется по scope
+	Role string `json:"role,omitempty"`
+	// Tenant арендатор, к которому привязаны учетные данные, пустой если не привязаны
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// Allows проверяет, покрывает ли scope принципала требуемый: admin > write > read
+func (p *Principal) Allows(scope string) bool {
+	return ScopeAllows(p.Scope, scope)
+}
+
+// Can проверяет право на операцию с каталогом
+func (p *Principal) Can(permission Permission) bool {
+	return RoleAllows(p.EffectiveRole(), permission)
+}
+
+func (p *Principal) EffectiveRole() string {
+	if p.Role != "" {
+		return p.Role
+	}
+	return ScopeRole(p.Scope)
+}
+
+// ScopeAllows покрывает ли scope have требуемый required
+func ScopeAllows(have, required string) bool {
+	return scopeLevel(have) >= scopeLevel(required) && scopeLevel(required) > 0
+}
+
+func ValidScope(scope string) bool {
+	return scopeLevel(scope) > 0
+}
+
+func scopeLevel(scope string) int {
+	switch scope {
+	case ScopeRead:
+		return 1
+	case ScopeWrite:
+		return 2
+	case ScopeAdmin:
+		return 3
+	default:
+		return 0
+	}
+}
+
+type contextKey struct{}
+
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, principal)
+}
+
+func FromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(contextKey{}).(*Principal)
+	return principal, ok
+}
+
+// SetPrincipal сохраняет принципала в запросе fiber, его имя попадает в логи запроса
+func SetPrincipal(ctx *fiber.Ctx, principal *Principal) {
+	ctx.Locals(localsKey, principal)
+	userContext := logging.With(ctx.UserContext(), "user", principal.Name, "auth", principal.Method)
+	ctx.SetUserContext(WithPrincipal(userContext, principal))
+}
+
+func PrincipalFrom(ctx *fiber.Ctx) (*Principal, bool) {
+	principal, ok := ctx.Locals(localsKey).(*Principal)
+	return principal, ok
+}
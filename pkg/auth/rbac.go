@@ -0,0 +1,79 @@
+package auth
+
+import "errors"
+
+// тут роли и права на операции с каталогом. Права проверяются в одном месте (pkg/authz),
+// поэтому одинаково работают для HTML, REST и любого другого транспорта
+
+const (
+	RoleViewer  = "viewer"
+	RoleEditor  = "editor"
+	RolePricing = "pricing"
+	RoleAdmin   = "admin"
+)
+
+type Permission string
+
+const (
+	PermList        Permission = "product.list"
+	PermRead        Permission = "product.read"
+	PermCreate      Permission = "product.create"
+	PermUpdate      Permission = "product.update"
+	PermDelete      Permission = "product.delete"
+	PermPriceChange Permission = "product.price_change"
+	PermStockAdjust Permission = "product.stock_adjust"
+)
+
+var (
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrForbidden       = errors.New("forbidden")
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleViewer:  {PermList, PermRead},
+	RoleEditor:  {PermList, PermRead, PermCreate, PermUpdate, PermDelete, PermStockAdjust},
+	RolePricing: {PermList, PermRead, PermPriceChange},
+	RoleAdmin:   {PermList, PermRead, PermCreate, PermUpdate, PermDelete, PermPriceChange, PermStockAdjust},
+}
+
+// roleScopes минимальный scope API ключа, с которым имеет смысл роль
+var roleScopes = map[string]string{
+	RoleViewer:  ScopeRead,
+	RoleEditor:  ScopeWrite,
+	RolePricing: ScopeWrite,
+	RoleAdmin:   ScopeAdmin,
+}
+
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RoleScope scope, который нужен роли
+func RoleScope(role string) string {
+	return roleScopes[role]
+}
+
+// ScopeRole роль по умолчанию для scope, если роль не задана явно
+func ScopeRole(scope string) string {
+	switch scope {
+	case ScopeRead:
+		return RoleViewer
+	case ScopeWrite:
+		return RoleEditor
+	case ScopeAdmin:
+		return RoleAdmin
+	default:
+		return ""
+	}
+}
+
+// RoleAllows есть ли у роли право
+func RoleAllows(role string, permission Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
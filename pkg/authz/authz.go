@@ -0,0 +1,154 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/grip211/crud/pkg/auth"
+	"github.com/grip211/crud/pkg/commands"
+	"github.com/grip211/crud/pkg/models"
+	"github.com/grip211/crud/pkg/repository"
+)
+
+// тут общий слой авторизации для операций с каталогом: права берутся у принципала из ctx,
+// поэтому HTML, REST и любой другой транспорт проверяются одинаково, достаточно положить принципала в ctx
+
+type Repo struct {
+	repo repository.Catalog
+}
+
+func New(repo repository.Catalog) *Repo {
+	return &Repo{
+		repo: repo,
+	}
+}
+
+// Check проверяет, что у принципала из ctx есть все права permissions
+func Check(ctx context.Context, permissions ...auth.Permission) error {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return auth.ErrUnauthenticated
+	}
+	for _, permission := range permissions {
+		if !principal.Can(permission) {
+			return fmt.Errorf("%w: role %q has no %s permission", auth.ErrForbidden, principal.EffectiveRole(), permission)
+		}
+	}
+	return nil
+}
+
+func (r *Repo) Read(ctx context.Context) ([]models.Product, error) {
+	if err := Check(ctx, auth.PermList); err != nil {
+		return nil, err
+	}
+	return r.repo.Read(ctx)
+}
+
+func (r *Repo) ReadOne(ctx context.Context, id int) (*models.Product, error) {
+	if err := Check(ctx, auth.PermRead); err != nil {
+		return nil, err
+	}
+	return r.repo.ReadOne(ctx, id)
+}
+
+func (r *Repo) ReadOneWithFeatures(ctx context.Context, id int) (*models.Product, error) {
+	if err := Check(ctx, auth.PermRead); err != nil {
+		return nil, err
+	}
+	return r.repo.ReadOneWithFeatures(ctx, id)
+}
+
+func (r *Repo) Create(ctx context.Context, command *commands.CreateCommand) (int, error) {
+	if err := Check(ctx, auth.PermCreate); err != nil {
+		return 0, err
+	}
+	return r.repo.Create(ctx, command)
+}
+
+// Update права на поля проверяются в транзакции изменения по заблокированной строке,
+// кеш и реплики могут отдать устаревшее состояние
+func (r *Repo) Update(ctx context.Context, command *commands.UpdateCommand) error {
+	if err := Check(ctx, auth.PermRead); err != nil {
+		return err
+	}
+	return r.repo.Update(withUpdateCheck(ctx), command)
+}
+
+func (r *Repo) Delete(ctx context.Context, command *commands.DeleteCommand) (int64, error) {
+	if err := Check(ctx, auth.PermDelete); err != nil {
+		return 0, err
+	}
+	return r.repo.Delete(ctx, command)
+}
+
+// Batch проверяет каждую операцию отдельно, запрещенная операция получает ошибку,
+// дальше пакет ведет себя как с любой другой ошибкой операции: atomic откатывается целиком
+func (r *Repo) Batch(ctx context.Context, command *commands.BatchCommand) ([]models.BatchResult, error) {
+	if _, ok := auth.FromContext(ctx); !ok {
+		return nil, auth.ErrUnauthenticated
+	}
+
+	for _, operation := range command.Operations {
+		if operation.Err != nil {
+			continue
+		}
+		switch operation.Op {
+		case commands.BatchOpCreate:
+			operation.Err = Check(ctx, auth.PermCreate)
+		case commands.BatchOpUpdate:
+			// права на поля проверит сам репозиторий, см. Update
+			operation.Err = Check(ctx, auth.PermRead)
+		case commands.BatchOpDelete:
+			operation.Err = Check(ctx, auth.PermDelete)
+		}
+	}
+
+	return r.repo.Batch(withUpdateCheck(ctx), command)
+}
+
+func withUpdateCheck(ctx context.Context) context.Context {
+	return repository.WithUpdateCheck(ctx, func(current *models.Product, command *commands.UpdateCommand) error {
+		return checkUpdate(ctx, current, command)
+	})
+}
+
+// checkUpdate проверяет права на поля, которые реально меняются: цена и остаток требуют
+// отдельных прав, остальные поля требуют права на изменение
+func checkUpdate(ctx context.Context, current *models.Product, command *commands.UpdateCommand) error {
+	permissions := UpdatePermissions(current, command)
+	if len(permissions) == 0 {
+		// ничего не меняется, но запись все равно изменяющая, нужно хоть одно право на изменение
+		return checkAny(ctx, auth.PermUpdate, auth.PermPriceChange, auth.PermStockAdjust)
+	}
+	return Check(ctx, permissions...)
+}
+
+func checkAny(ctx context.Context, permissions ...auth.Permission) error {
+	var err error
+	for _, permission := range permissions {
+		if err = Check(ctx, permission); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// UpdatePermissions права, которые нужны, чтобы привести current к command
+func UpdatePermissions(current *models.Product, command *commands.UpdateCommand) []auth.Permission {
+	var permissions []auth.Permission
+	if current.Price != command.Price {
+		permissions = append(permissions, auth.PermPriceChange)
+	}
+	if current.Quantity != command.Quantity {
+		permissions = append(permissions, auth.PermStockAdjust)
+	}
+	if current.Model != command.Model ||
+		current.Company != command.Company ||
+		current.Features.CPU.Int32 != int32(command.CPU) ||
+		current.Features.Memory.Int32 != int32(command.Memory) ||
+		current.Features.Display.Int32 != int32(command.DisplaySize) ||
+		current.Features.Camera.Int32 != int32(command.Camera) {
+		permissions = append(permissions, auth.PermUpdate)
+	}
+	return permissions
+}
@@ -0,0 +1,139 @@
+package authz
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grip211/crud/pkg/auth"
+	"github.com/grip211/crud/pkg/commands"
+	"github.com/grip211/crud/pkg/models"
+	"github.com/grip211/crud/pkg/repository"
+)
+
+func TestUpdatePermissions(t *testing.T) {
+	current := &models.Product{
+		ID:       1,
+		Model:    "iPhone 14",
+		Company:  "Apple",
+		Quantity: 10,
+		Price:    999,
+		Features: models.Features{CPU: sql.NullInt32{Int32: 6, Valid: true}},
+	}
+	unchanged := commands.UpdateCommand{ID: 1, Model: "iPhone 14", Company: "Apple", Quantity: 10, Price: 999, CPU: 6}
+
+	tests := []struct {
+		name   string
+		update func(command *commands.UpdateCommand)
+		want   []auth.Permission
+	}{
+		{name: "nothing changed", update: func(*commands.UpdateCommand) {}},
+		{name: "price", update: func(c *commands.UpdateCommand) { c.Price = 899 }, want: []auth.Permission{auth.PermPriceChange}},
+		{name: "quantity", update: func(c *commands.UpdateCommand) { c.Quantity = 5 }, want: []auth.Permission{auth.PermStockAdjust}},
+		{name: "model", update: func(c *commands.UpdateCommand) { c.Model = "iPhone 15" }, want: []auth.Permission{auth.PermUpdate}},
+		{name: "features", update: func(c *commands.UpdateCommand) { c.CPU = 8 }, want: []auth.Permission{auth.PermUpdate}},
+		{
+			name:   "price and model",
+			update: func(c *commands.UpdateCommand) { c.Price = 899; c.Model = "iPhone 15" },
+			want:   []auth.Permission{auth.PermPriceChange, auth.PermUpdate},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command := unchanged
+			tt.update(&command)
+			require.Equal(t, tt.want, UpdatePermissions(current, &command))
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		principal   *auth.Principal
+		permissions []auth.Permission
+		err         error
+	}{
+		{name: "no principal", permissions: []auth.Permission{auth.PermList}, err: auth.ErrUnauthenticated},
+		{name: "viewer lists", principal: &auth.Principal{Role: auth.RoleViewer}, permissions: []auth.Permission{auth.PermList}},
+		{name: "viewer deletes", principal: &auth.Principal{Role: auth.RoleViewer}, permissions: []auth.Permission{auth.PermDelete}, err: auth.ErrForbidden},
+		{name: "editor changes price", principal: &auth.Principal{Role: auth.RoleEditor}, permissions: []auth.Permission{auth.PermUpdate, auth.PermPriceChange}, err: auth.ErrForbidden},
+		{name: "pricing changes price", principal: &auth.Principal{Role: auth.RolePricing}, permissions: []auth.Permission{auth.PermPriceChange}},
+		{name: "pricing changes model", principal: &auth.Principal{Role: auth.RolePricing}, permissions: []auth.Permission{auth.PermUpdate}, err: auth.ErrForbidden},
+		{name: "write scope without role", principal: &auth.Principal{Scope: auth.ScopeWrite}, permissions: []auth.Permission{auth.PermStockAdjust}},
+		{name: "admin", principal: &auth.Principal{Role: auth.RoleAdmin}, permissions: []auth.Permission{auth.PermDelete, auth.PermPriceChange}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.principal != nil {
+				ctx = auth.WithPrincipal(ctx, tt.principal)
+			}
+			err := Check(ctx, tt.permissions...)
+			if tt.err == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+// staleCatalog отдает на чтение устаревший товар, а Update проверяет изменение по актуальному,
+// как это делает репозиторий в транзакции
+type staleCatalog struct {
+	repository.Catalog
+	stale   *models.Product
+	current *models.Product
+	updated bool
+}
+
+func (c *staleCatalog) ReadOneWithFeatures(context.Context, int) (*models.Product, error) {
+	return c.stale, nil
+}
+
+func (c *staleCatalog) Update(ctx context.Context, command *commands.UpdateCommand) error {
+	if err := repository.CheckUpdate(ctx, c.current, command); err != nil {
+		return err
+	}
+	c.updated = true
+	return nil
+}
+
+func TestRepo_Update(t *testing.T) {
+	tests := []struct {
+		name    string
+		stale   float32
+		current float32
+		price   float32
+		err     error
+	}{
+		// в кеше старая цена, клиент присылает ее же, а в базе уже другая: это изменение цены
+		{name: "stale read does not hide a price change", stale: 899, current: 999, price: 899, err: auth.ErrForbidden},
+		// клиент присылает цену из базы: цена не меняется, хотя в кеше другая
+		{name: "stale read does not forbid an unchanged price", stale: 899, current: 999, price: 999},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			catalog := &staleCatalog{
+				stale:   &models.Product{ID: 1, Model: "iPhone 14", Price: tt.stale},
+				current: &models.Product{ID: 1, Model: "iPhone 14", Price: tt.current},
+			}
+			ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Role: auth.RoleEditor})
+
+			err := New(catalog).Update(ctx, &commands.UpdateCommand{ID: 1, Model: "iPhone 14", Price: tt.price})
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				require.False(t, catalog.updated)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, catalog.updated)
+		})
+	}
+}
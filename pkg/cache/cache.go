@@ -0,0 +1,231 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/grip211/crud/pkg/commands"
+	"github.com/grip211/crud/pkg/database"
+	"github.com/grip211/crud/pkg/logging"
+	"github.com/grip211/crud/pkg/models"
+	"github.com/grip211/crud/pkg/repository"
+	"github.com/grip211/crud/pkg/tenant"
+)
+
+// тут кеш чтений каталога поверх репозитория. Ключи свои у каждого арендатора, записи через этот же
+// кеш сбрасывают затронутые товары и список. Одновременные промахи по одному ключу собираются
+// в один запрос к базе. Значения хранятся в JSON, поэтому бэкендом может быть и внешний кеш
+
+// loadTimeout предел общей загрузки из базы, она не зависит от запросов, которые ее ждут
+const loadTimeout = time.Second * 30
+
+const (
+	KindList     = "list"
+	KindProduct  = "product"
+	KindFeatures = "features"
+)
+
+// Backend хранилище кеша. LRU держит записи в памяти процесса, внешний кеш (Redis, memcached)
+// нужен, когда экземпляров несколько и записи одного должны сбрасывать кеш остальных
+type Backend interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+type Options struct {
+	// Size сколько записей держать в памяти, 0 отключает кеш
+	Size int           `yaml:"size"`
+	TTL  time.Duration `yaml:"ttl"`
+}
+
+// Observer получает попадания и промахи, например для метрик
+type Observer interface {
+	ObserveCache(kind string, hit bool)
+}
+
+type Repo struct {
+	next    repository.Catalog
+	backend Backend
+	ttl     time.Duration
+	group   singleflight.Group
+	// generation растет с каждой записью, загрузка, начатая до записи, не кладет в кеш устаревшее значение
+	generation atomic.Uint64
+	observer   Observer
+}
+
+func New(next repository.Catalog, backend Backend, ttl time.Duration) *Repo {
+	return &Repo{
+		next:    next,
+		backend: backend,
+		ttl:     ttl,
+	}
+}
+
+// WithObserver включает учет попаданий, вызывать до начала работы
+func (r *Repo) WithObserver(observer Observer) *Repo {
+	r.observer = observer
+	return r
+}
+
+func (r *Repo) Read(ctx context.Context) ([]models.Product, error) {
+	return load(ctx, r, KindList, 0, func(ctx context.Context) ([]models.Product, error) {
+		return r.next.Read(ctx)
+	})
+}
+
+func (r *Repo) ReadOne(ctx context.Context, id int) (*models.Product, error) {
+	return load(ctx, r, KindProduct, id, func(ctx context.Context) (*models.Product, error) {
+		return r.next.ReadOne(ctx, id)
+	})
+}
+
+func (r *Repo) ReadOneWithFeatures(ctx context.Context, id int) (*models.Product, error) {
+	return load(ctx, r, KindFeatures, id, func(ctx context.Context) (*models.Product, error) {
+		return r.next.ReadOneWithFeatures(ctx, id)
+	})
+}
+
+func (r *Repo) Create(ctx context.Context, command *commands.CreateCommand) (int, error) {
+	id, err := r.next.Create(ctx, command)
+	if err == nil {
+		r.invalidate(ctx)
+	}
+	return id, err
+}
+
+func (r *Repo) Update(ctx context.Context, command *commands.UpdateCommand) error {
+	err := r.next.Update(ctx, command)
+	if err == nil {
+		r.invalidate(ctx, command.ID)
+	}
+	return err
+}
+
+func (r *Repo) Delete(ctx context.Context, command *commands.DeleteCommand) (int64, error) {
+	deleted, err := r.next.Delete(ctx, command)
+	if err == nil {
+		r.invalidate(ctx, command.ID)
+	}
+	return deleted, err
+}
+
+// Batch сбрасывает кеш даже при ошибке, в режиме independent часть операций могла пройти
+func (r *Repo) Batch(ctx context.Context, command *commands.BatchCommand) ([]models.BatchResult, error) {
+	results, err := r.next.Batch(ctx, command)
+
+	var ids []int
+	for _, operation := range command.Operations {
+		switch {
+		case operation.Update != nil:
+			ids = append(ids, operation.Update.ID)
+		case operation.Delete != nil:
+			ids = append(ids, operation.Delete.ID)
+		}
+	}
+	r.invalidate(ctx, ids...)
+	return results, err
+}
+
+// load отдает значение из кеша или загружает его fetch. Ошибки не кешируются,
+// ошибки самого кеша только пишутся в лог, чтение тогда идет в базу
+func load[T any](ctx context.Context, r *Repo, kind string, id int, fetch func(ctx context.Context) (T, error)) (T, error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		// без арендатора кешировать нельзя, ошибку вернет репозиторий
+		return fetch(ctx)
+	}
+	key := Key(tenantID, kind, id)
+	logger := logging.FromContext(ctx)
+
+	var value T
+	data, found, err := r.backend.Get(ctx, key)
+	if err != nil {
+		logger.Warn("cache get failed", "key", key, "error", err)
+	}
+	if found && json.Unmarshal(data, &value) == nil {
+		r.observe(kind, true)
+		return value, nil
+	}
+	r.observe(kind, false)
+
+	// промах после записи не должен присоединиться к загрузке, начатой до нее
+	generation := r.generation.Load()
+	loaded := r.group.DoChan(key+"@"+strconv.FormatUint(generation, 10), func() (any, error) {
+		// загрузка общая для всех ждущих, отмена запроса первого из них не должна ее прерывать.
+		// Значения ctx (арендатор, логгер, спан) остаются
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+		defer cancel()
+
+		// значение проживет в кеше ttl, поэтому читаем его без отставания реплики
+		fetched, err := fetch(database.UsePrimary(loadCtx))
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(fetched)
+		if err != nil {
+			return nil, err
+		}
+		if r.generation.Load() == generation {
+			if err = r.backend.Set(loadCtx, key, data, r.ttl); err != nil {
+				logger.Warn("cache set failed", "key", key, "error", err)
+			}
+			// запись могла пройти между проверкой и Set и сбросить ключ раньше, чем его записали мы.
+			// Если после Set номер тот же, сброс записи будет позже и удалит значение сам
+			if r.generation.Load() != generation {
+				if err = r.backend.Delete(loadCtx, key); err != nil {
+					logger.Warn("cache invalidation failed", "keys", []string{key}, "error", err)
+				}
+			}
+		}
+		return data, nil
+	})
+
+	var result singleflight.Result
+	select {
+	case <-ctx.Done():
+		// отмененный запрос перестает ждать, загрузка продолжается для остальных
+		return value, ctx.Err()
+	case result = <-loaded:
+	}
+	if result.Err != nil {
+		return value, result.Err
+	}
+	// каждый вызывающий получает свою копию, общие объекты нельзя было бы менять
+	err = json.Unmarshal(result.Val.([]byte), &value)
+	return value, err
+}
+
+// invalidate сбрасывает список арендатора и товары ids
+func (r *Repo) invalidate(ctx context.Context, ids ...int) {
+	// номер растет до удаления ключей, на этот порядок рассчитана проверка после Set в load
+	r.generation.Add(1)
+
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return
+	}
+	keys := []string{Key(tenantID, KindList, 0)}
+	for _, id := range ids {
+		keys = append(keys, Key(tenantID, KindProduct, id), Key(tenantID, KindFeatures, id))
+	}
+	if err := r.backend.Delete(ctx, keys...); err != nil {
+		logging.FromContext(ctx).Warn("cache invalidation failed", "keys", keys, "error", err)
+	}
+}
+
+func (r *Repo) observe(kind string, hit bool) {
+	if r.observer != nil {
+		r.observer.ObserveCache(kind, hit)
+	}
+}
+
+// Key ключ записи, id у списка 0
+func Key(tenantID, kind string, id int) string {
+	return "catalog:" + tenantID + ":" + kind + ":" + strconv.Itoa(id)
+}
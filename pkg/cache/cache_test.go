@@ -0,0 +1,391 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grip211/crud/pkg/commands"
+	"github.com/grip211/crud/pkg/models"
+	"github.com/grip211/crud/pkg/repository"
+	"github.com/grip211/crud/pkg/tenant"
+)
+
+// memoryCatalog каталог в памяти, считает обращения к "базе"
+type memoryCatalog struct {
+	mu       sync.Mutex
+	products map[string]map[int]models.Product
+	reads    atomic.Int32
+	// delay задержка чтения, чтобы одновременные промахи успели встретиться
+	delay time.Duration
+	// afterRead вызывается один раз после чтения товара, до возврата результата
+	afterRead func()
+}
+
+func newMemoryCatalog() *memoryCatalog {
+	return &memoryCatalog{
+		products: map[string]map[int]models.Product{
+			"shop-a": {1: {ID: 1, Model: "phone", Price: 100}},
+			"shop-b": {1: {ID: 1, Model: "tablet", Price: 200}},
+		},
+	}
+}
+
+func (m *memoryCatalog) Read(ctx context.Context) ([]models.Product, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.reads.Add(1)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	products := make([]models.Product, 0, len(m.products[tenantID]))
+	for _, product := range m.products[tenantID] {
+		products = append(products, product)
+	}
+	return products, nil
+}
+
+func (m *memoryCatalog) ReadOne(ctx context.Context, id int) (*models.Product, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.reads.Add(1)
+	select {
+	case <-time.After(m.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	m.mu.Lock()
+	product, ok := m.products[tenantID][id]
+	afterRead := m.afterRead
+	m.afterRead = nil
+	m.mu.Unlock()
+
+	if afterRead != nil {
+		afterRead()
+	}
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &product, nil
+}
+
+func (m *memoryCatalog) ReadOneWithFeatures(ctx context.Context, id int) (*models.Product, error) {
+	return m.ReadOne(ctx, id)
+}
+
+func (m *memoryCatalog) Create(ctx context.Context, command *commands.CreateCommand) (int, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := len(m.products[tenantID]) + 1
+	m.products[tenantID][id] = models.Product{ID: id, Model: command.Model}
+	return id, nil
+}
+
+func (m *memoryCatalog) Update(ctx context.Context, command *commands.UpdateCommand) error {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.products[tenantID][command.ID] = models.Product{ID: command.ID, Model: command.Model, Price: command.Price}
+	return nil
+}
+
+func (m *memoryCatalog) Delete(ctx context.Context, command *commands.DeleteCommand) (int64, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.products[tenantID], command.ID)
+	return 1, nil
+}
+
+func (m *memoryCatalog) Batch(ctx context.Context, command *commands.BatchCommand) ([]models.BatchResult, error) {
+	for _, operation := range command.Operations {
+		if operation.Update != nil {
+			if err := m.Update(ctx, operation.Update); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nil, nil
+}
+
+type hits struct {
+	mu     sync.Mutex
+	hit    int
+	missed int
+}
+
+func (h *hits) ObserveCache(_ string, hit bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if hit {
+		h.hit++
+	} else {
+		h.missed++
+	}
+}
+
+func TestRepo(t *testing.T) {
+	shopA := tenant.WithTenant(context.Background(), "shop-a")
+	shopB := tenant.WithTenant(context.Background(), "shop-b")
+
+	tests := []struct {
+		name      string
+		run       func(t *testing.T, repo *Repo)
+		wantReads int32
+	}{
+		{
+			name: "second read is a hit",
+			run: func(t *testing.T, repo *Repo) {
+				for i := 0; i < 2; i++ {
+					product, err := repo.ReadOne(shopA, 1)
+					require.NoError(t, err)
+					require.Equal(t, "phone", product.Model)
+				}
+			},
+			wantReads: 1,
+		},
+		{
+			name: "tenants do not share entries",
+			run: func(t *testing.T, repo *Repo) {
+				product, err := repo.ReadOne(shopA, 1)
+				require.NoError(t, err)
+				require.Equal(t, "phone", product.Model)
+
+				product, err = repo.ReadOne(shopB, 1)
+				require.NoError(t, err)
+				require.Equal(t, "tablet", product.Model)
+			},
+			wantReads: 2,
+		},
+		{
+			name: "update invalidates the product and the list",
+			run: func(t *testing.T, repo *Repo) {
+				_, err := repo.ReadOne(shopA, 1)
+				require.NoError(t, err)
+				_, err = repo.Read(shopA)
+				require.NoError(t, err)
+
+				require.NoError(t, repo.Update(shopA, &commands.UpdateCommand{ID: 1, Model: "phone 2", Price: 150}))
+
+				product, err := repo.ReadOne(shopA, 1)
+				require.NoError(t, err)
+				require.Equal(t, "phone 2", product.Model)
+				list, err := repo.Read(shopA)
+				require.NoError(t, err)
+				require.Equal(t, "phone 2", list[0].Model)
+			},
+			wantReads: 4,
+		},
+		{
+			name: "create invalidates the list",
+			run: func(t *testing.T, repo *Repo) {
+				_, err := repo.Read(shopA)
+				require.NoError(t, err)
+
+				_, err = repo.Create(shopA, &commands.CreateCommand{Model: "watch"})
+				require.NoError(t, err)
+
+				list, err := repo.Read(shopA)
+				require.NoError(t, err)
+				require.Len(t, list, 2)
+			},
+			wantReads: 2,
+		},
+		{
+			name: "delete and batch invalidate their products",
+			run: func(t *testing.T, repo *Repo) {
+				_, err := repo.ReadOneWithFeatures(shopA, 1)
+				require.NoError(t, err)
+				_, err = repo.Batch(shopA, &commands.BatchCommand{Operations: []*commands.BatchOperation{
+					{Op: commands.BatchOpUpdate, Update: &commands.UpdateCommand{ID: 1, Model: "phone 3"}},
+				}})
+				require.NoError(t, err)
+				product, err := repo.ReadOneWithFeatures(shopA, 1)
+				require.NoError(t, err)
+				require.Equal(t, "phone 3", product.Model)
+
+				_, err = repo.Delete(shopA, &commands.DeleteCommand{ID: 1})
+				require.NoError(t, err)
+				_, err = repo.ReadOneWithFeatures(shopA, 1)
+				require.ErrorIs(t, err, repository.ErrNotFound)
+			},
+			wantReads: 3,
+		},
+		{
+			name: "errors are not cached",
+			run: func(t *testing.T, repo *Repo) {
+				for i := 0; i < 2; i++ {
+					_, err := repo.ReadOne(shopA, 42)
+					require.ErrorIs(t, err, repository.ErrNotFound)
+				}
+			},
+			wantReads: 2,
+		},
+		{
+			name: "without tenant the cache is bypassed",
+			run: func(t *testing.T, repo *Repo) {
+				_, err := repo.ReadOne(context.Background(), 1)
+				require.ErrorIs(t, err, tenant.ErrMissing)
+			},
+		},
+		{
+			name: "cached value is a copy",
+			run: func(t *testing.T, repo *Repo) {
+				product, err := repo.ReadOne(shopA, 1)
+				require.NoError(t, err)
+				product.Model = "changed by caller"
+
+				product, err = repo.ReadOne(shopA, 1)
+				require.NoError(t, err)
+				require.Equal(t, "phone", product.Model)
+			},
+			wantReads: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			catalog := newMemoryCatalog()
+			repo := New(catalog, NewLRU(100), time.Minute)
+			tt.run(t, repo)
+			require.Equal(t, tt.wantReads, catalog.reads.Load())
+		})
+	}
+}
+
+func TestRepo_Singleflight(t *testing.T) {
+	ctx := tenant.WithTenant(context.Background(), "shop-a")
+	catalog := newMemoryCatalog()
+	catalog.delay = time.Millisecond * 50
+	observer := &hits{}
+	repo := New(catalog, NewLRU(100), time.Minute).WithObserver(observer)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			product, err := repo.ReadOne(ctx, 1)
+			require.NoError(t, err)
+			require.Equal(t, "phone", product.Model)
+		}()
+	}
+	wg.Wait()
+
+	// одновременные промахи ушли в базу одним запросом
+	require.Equal(t, int32(1), catalog.reads.Load())
+	require.Equal(t, 10, observer.missed)
+
+	_, err := repo.ReadOne(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, observer.hit)
+}
+
+func TestRepo_SingleflightCanceledCaller(t *testing.T) {
+	ctx := tenant.WithTenant(context.Background(), "shop-a")
+	catalog := newMemoryCatalog()
+	catalog.delay = time.Millisecond * 100
+	repo := New(catalog, NewLRU(100), time.Minute)
+
+	// загрузку начинает запрос, который отменяется, пока ее ждет второй
+	firstCtx, cancel := context.WithCancel(ctx)
+	first := make(chan error, 1)
+	go func() {
+		_, err := repo.ReadOne(firstCtx, 1)
+		first <- err
+	}()
+	time.Sleep(time.Millisecond * 20)
+
+	second := make(chan *models.Product, 1)
+	go func() {
+		product, err := repo.ReadOne(ctx, 1)
+		require.NoError(t, err)
+		second <- product
+	}()
+	time.Sleep(time.Millisecond * 20)
+	cancel()
+
+	require.ErrorIs(t, <-first, context.Canceled)
+	product := <-second
+	require.Equal(t, "phone", product.Model)
+	require.Equal(t, int32(1), catalog.reads.Load())
+
+	// загруженное значение попало в кеш
+	_, err := repo.ReadOne(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), catalog.reads.Load())
+}
+
+// setHook бэкенд, который вызывает beforeSet один раз перед записью
+type setHook struct {
+	Backend
+	beforeSet func()
+}
+
+func (h *setHook) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if beforeSet := h.beforeSet; beforeSet != nil {
+		h.beforeSet = nil
+		beforeSet()
+	}
+	return h.Backend.Set(ctx, key, value, ttl)
+}
+
+func TestRepo_InvalidateDuringLoad(t *testing.T) {
+	ctx := tenant.WithTenant(context.Background(), "shop-a")
+
+	tests := []struct {
+		name string
+		// hook ставит запись в нужный момент загрузки
+		hook func(catalog *memoryCatalog, backend *setHook, write func())
+	}{
+		{
+			name: "write while the fetch is blocked",
+			hook: func(catalog *memoryCatalog, _ *setHook, write func()) { catalog.afterRead = write },
+		},
+		{
+			name: "write between the generation check and set",
+			hook: func(_ *memoryCatalog, backend *setHook, write func()) { backend.beforeSet = write },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			catalog := newMemoryCatalog()
+			backend := &setHook{Backend: NewLRU(100)}
+			repo := New(catalog, backend, time.Minute)
+
+			tt.hook(catalog, backend, func() {
+				require.NoError(t, repo.Update(ctx, &commands.UpdateCommand{ID: 1, Model: "updated", Price: 150}))
+			})
+
+			// загрузка, начатая до записи, может вернуть старое значение, но не должна его закешировать
+			_, err := repo.ReadOne(ctx, 1)
+			require.NoError(t, err)
+
+			product, err := repo.ReadOne(ctx, 1)
+			require.NoError(t, err)
+			require.Equal(t, "updated", product.Model)
+		})
+	}
+}
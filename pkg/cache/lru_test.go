@@ -0,0 +1,39 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	lru := NewLRU(2)
+	lru.now = func() time.Time { return now }
+
+	require.NoError(t, lru.Set(ctx, "a", []byte("1"), time.Minute))
+	require.NoError(t, lru.Set(ctx, "b", []byte("2"), 0))
+
+	// чтение a делает давно не читанной b, она и вытесняется
+	value, found, err := lru.Get(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "1", string(value))
+
+	require.NoError(t, lru.Set(ctx, "c", []byte("3"), time.Minute))
+	require.Equal(t, 2, lru.Len())
+	_, found, _ = lru.Get(ctx, "b")
+	require.False(t, found)
+
+	// просроченная запись не отдается
+	now = now.Add(time.Minute)
+	_, found, _ = lru.Get(ctx, "a")
+	require.False(t, found)
+	require.Equal(t, 1, lru.Len())
+
+	require.NoError(t, lru.Delete(ctx, "c", "missing"))
+	require.Equal(t, 0, lru.Len())
+}
@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+)
+
+// тут описываем пакетные операции: набор create/update/delete, выполняемых за один запрос
+
+const (
+	BatchOpCreate = "create"
+	BatchOpUpdate = "update"
+	BatchOpDelete = "delete"
+
+	// BatchModeAtomic все операции выполняются в одной транзакции: либо все, либо ничего
+	BatchModeAtomic = "atomic"
+	// BatchModeIndependent каждая операция выполняется отдельно, результат возвращается по каждой
+	BatchModeIndependent = "independent"
+)
+
+var (
+	ErrBatchEmpty       = errors.New("batch is empty")
+	ErrBatchTooLarge    = errors.New("batch is too large")
+	ErrBatchUnknownMode = errors.New("unknown batch mode")
+	ErrBatchUnknownOp   = errors.New("unknown batch operation")
+)
+
+type BatchOperation struct {
+	Op     string
+	Create *CreateCommand
+	Update *UpdateCommand
+	Delete *DeleteCommand
+
+	// Err ошибка разбора входных данных операции, в режиме independent
+	// она попадает в результат операции, в режиме atomic отклоняет весь пакет
+	Err error
+}
+
+func NewBatchOperation(
+	op, id, model, company, quantity, price, cpu, memory, display, camera string,
+) *BatchOperation {
+	operation := &BatchOperation{Op: op}
+
+	switch op {
+	case BatchOpCreate:
+		operation.Create, operation.Err = NewCreteCommand(model, company, quantity, price, cpu, memory, display, camera)
+	case BatchOpUpdate:
+		operation.Update, operation.Err = NewUpdateCommand(id, model, company, quantity, price, cpu, memory, display, camera)
+	case BatchOpDelete:
+		operation.Delete, operation.Err = NewDeleteCommand(id)
+	default:
+		operation.Err = fmt.Errorf("%w: %q", ErrBatchUnknownOp, op)
+	}
+
+	return operation
+}
+
+type BatchCommand struct {
+	Mode       string
+	Operations []*BatchOperation
+}
+
+func NewBatchCommand(mode string, maxSize int, operations []*BatchOperation) (*BatchCommand, error) {
+	if mode == "" {
+		mode = BatchModeAtomic
+	}
+	if mode != BatchModeAtomic && mode != BatchModeIndependent {
+		return nil, fmt.Errorf("%w: %q", ErrBatchUnknownMode, mode)
+	}
+	if len(operations) == 0 {
+		return nil, ErrBatchEmpty
+	}
+	if maxSize > 0 && len(operations) > maxSize {
+		return nil, fmt.Errorf("%w: %d operations, max %d", ErrBatchTooLarge, len(operations), maxSize)
+	}
+
+	return &BatchCommand{
+		Mode:       mode,
+		Operations: operations,
+	}, nil
+}
@@ -0,0 +1,451 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	driver "github.com/go-sql-driver/mysql"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grip211/crud/pkg/auth"
+	"github.com/grip211/crud/pkg/cache"
+	"github.com/grip211/crud/pkg/database"
+	"github.com/grip211/crud/pkg/httpcache"
+	"github.com/grip211/crud/pkg/jwtauth"
+	"github.com/grip211/crud/pkg/limits"
+	"github.com/grip211/crud/pkg/logging"
+	"github.com/grip211/crud/pkg/outbox"
+	"github.com/grip211/crud/pkg/session"
+	"github.com/grip211/crud/pkg/signal"
+	"github.com/grip211/crud/pkg/tenant"
+	"github.com/grip211/crud/pkg/tracing"
+	"github.com/grip211/crud/pkg/webhook"
+)
+
+// тут конфигурация сервиса. Слои по возрастанию приоритета: значения по умолчанию, YAML файл,
+// переменные окружения, флаги. Окружение и флаги накладывает cli, здесь только файл и проверка
+
+const (
+	ListenerTCP  = "tcp"
+	ListenerUnix = "unix"
+
+	masked = "******"
+)
+
+var ErrInvalid = errors.New("invalid config")
+
+type Config struct {
+	Database    database.Opt    `yaml:"database"`
+	HTTP        HTTP            `yaml:"http"`
+	Tracing     tracing.Options `yaml:"tracing"`
+	Log         logging.Options `yaml:"log"`
+	Limits      limits.Options  `yaml:"limits"`
+	Cache       cache.Options   `yaml:"cache"`
+	Metrics     Metrics         `yaml:"metrics"`
+	Auth        Auth            `yaml:"auth"`
+	Tenant      tenant.Options  `yaml:"tenant"`
+	Events      Events          `yaml:"events"`
+	Outbox      Outbox          `yaml:"outbox"`
+	Webhook     webhook.Options `yaml:"webhook"`
+	Idempotency Idempotency     `yaml:"idempotency"`
+	Batch       Batch           `yaml:"batch"`
+}
+
+type HTTP struct {
+	// Listener tcp или unix
+	Listener string `yaml:"listener"`
+	Addr     string `yaml:"addr"`
+	Socket   string `yaml:"socket"`
+	// CacheControl заголовок Cache-Control для чтений каталога
+	CacheControl httpcache.Options `yaml:"cache_control"`
+}
+
+type Metrics struct {
+	// Path адрес /metrics, пустой отключает метрики
+	Path string `yaml:"path"`
+}
+
+type Auth struct {
+	JWT     JWT             `yaml:"jwt"`
+	Session session.Options `yaml:"session"`
+}
+
+// JWT проверка Bearer токенов шлюза, без JWKS они не принимаются
+type JWT struct {
+	// JWKS путь или URL набора ключей
+	JWKS        string        `yaml:"jwks"`
+	JWKSRefresh time.Duration `yaml:"jwks_refresh"`
+	Issuer      string        `yaml:"issuer"`
+	Audience    string        `yaml:"audience"`
+	RoleClaim   string        `yaml:"role_claim"`
+	// RoleMap роль шлюза -> роль каталога
+	RoleMap     map[string]string `yaml:"role_map"`
+	TenantClaim string            `yaml:"tenant_claim"`
+}
+
+// Options настройки проверки токенов, токены без claim арендатора привязываются к defaultTenant
+func (j *JWT) Options(defaultTenant string) jwtauth.Options {
+	return jwtauth.Options{
+		Issuer:        j.Issuer,
+		Audience:      j.Audience,
+		RoleClaim:     j.RoleClaim,
+		RoleMapping:   j.RoleMap,
+		TenantClaim:   j.TenantClaim,
+		DefaultTenant: defaultTenant,
+	}
+}
+
+type Events struct {
+	// BufferSize сколько последних событий хранить для продолжения по Last-Event-ID
+	BufferSize int `yaml:"buffer_size"`
+}
+
+type Outbox struct {
+	Relay outbox.Options `yaml:",inline"`
+	// Log печатать опубликованные события в лог
+	Log  bool `yaml:"log"`
+	NATS NATS `yaml:"nats"`
+}
+
+type NATS struct {
+	// Addr брокер, пустой отключает публикацию
+	Addr          string `yaml:"addr"`
+	SubjectPrefix string `yaml:"subject_prefix"`
+}
+
+type Idempotency struct {
+	// TTL сколько хранить ответы на запросы с Idempotency-Key
+	TTL time.Duration `yaml:"ttl"`
+}
+
+type Batch struct {
+	MaxSize int `yaml:"max_size"`
+}
+
+// ListenerMode режим для signal.Listener
+func (h *HTTP) ListenerMode() int {
+	if h.Listener == ListenerUnix {
+		return signal.ListenerUDS
+	}
+	return signal.ListenerTCP
+}
+
+func Default() *Config {
+	return &Config{
+		Database: database.Opt{
+			Host:                 "127.0.0.1",
+			Name:                 "productdb",
+			Schema:               database.DefaultSchema,
+			Dialect:              database.DialectMySQL,
+			MaxIdleConns:         9,
+			MaxOpenConns:         10,
+			MaxConnMaxLifetime:   time.Minute * 5,
+			SlowQueryThreshold:   time.Millisecond * 500,
+			ReplicaCheckInterval: time.Second * 5,
+			Retry: database.Retry{
+				Attempts:    10,
+				Backoff:     time.Millisecond * 500,
+				MaxBackoff:  time.Second * 10,
+				PingTimeout: time.Second * 5,
+			},
+		},
+		HTTP: HTTP{
+			Listener: ListenerTCP,
+			Addr:     ":8181",
+			Socket:   "/tmp/crud.sock",
+			// ответы зависят от пользователя, клиент каждый раз проверяет актуальность по ETag
+			CacheControl: httpcache.Options{
+				List:    "private, no-cache",
+				Product: "private, no-cache",
+			},
+		},
+		Tracing: tracing.Options{
+			Exporter:    tracing.ExporterNone,
+			ServiceName: "crud",
+			SampleRatio: 1,
+		},
+		Log: logging.Options{
+			Level:  "info",
+			Format: logging.FormatText,
+		},
+		Limits: limits.Options{
+			Read:      limits.Rate{PerSecond: 50, Burst: 100},
+			Write:     limits.Rate{PerSecond: 10, Burst: 20},
+			Batch:     limits.Rate{PerSecond: 1, Burst: 5},
+			Body:      1024 * 1024,
+			BatchBody: limits.DefaultBodyLimit,
+		},
+		Cache: cache.Options{
+			Size: 10000,
+			TTL:  time.Second * 30,
+		},
+		Metrics: Metrics{
+			Path: "/metrics",
+		},
+		Auth: Auth{
+			JWT: JWT{
+				JWKSRefresh: time.Minute * 10,
+				RoleClaim:   "roles",
+				TenantClaim: "tenant",
+			},
+			Session: session.Options{
+				CookieName: "crud_session",
+				TTL:        time.Hour * 12,
+			},
+		},
+		Tenant: tenant.Options{
+			Default: tenant.Default,
+		},
+		Events: Events{
+			BufferSize: 1024,
+		},
+		Outbox: Outbox{
+			Relay: outbox.Options{
+				PollInterval: time.Millisecond * 500,
+				BatchSize:    100,
+				Retention:    time.Hour * 24,
+				MaxAttempts:  20,
+			},
+			NATS: NATS{
+				SubjectPrefix: "crud",
+			},
+		},
+		Webhook: webhook.Options{
+			PollInterval: time.Second,
+			BatchSize:    50,
+			MaxAttempts:  10,
+			BaseBackoff:  time.Second * 5,
+			MaxBackoff:   time.Hour,
+			Timeout:      time.Second * 10,
+		},
+		Idempotency: Idempotency{
+			TTL: time.Hour * 24,
+		},
+		Batch: Batch{
+			MaxSize: 100,
+		},
+	}
+}
+
+// Load значения по умолчанию, поверх них файл path, если он задан
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path == "" {
+		return cfg, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open config: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	// опечатка в ключе не должна молча оставлять значение по умолчанию
+	decoder.KnownFields(true)
+	// пустой файл ничего не меняет
+	if err = decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Validate возвращает все найденные ошибки сразу
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%w: "+format, append([]interface{}{ErrInvalid}, args...)...))
+	}
+
+	if c.Database.Dialect != database.DialectMySQL && c.Database.Dialect != database.DialectPostgres {
+		invalid("database.dialect %q is not supported, use mysql or postgres", c.Database.Dialect)
+	}
+	if err := c.Database.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("%w: database: %w", ErrInvalid, err))
+	}
+
+	if err := c.Tracing.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("%w: %w", ErrInvalid, err))
+	}
+	if err := c.Log.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("%w: %w", ErrInvalid, err))
+	}
+	if err := c.Limits.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("%w: %w", ErrInvalid, err))
+	}
+	if err := c.HTTP.CacheControl.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("%w: http.cache_control: %w", ErrInvalid, err))
+	}
+	if c.Cache.Size < 0 || c.Cache.TTL < 0 {
+		invalid("cache.size and cache.ttl must not be negative")
+	}
+
+	if c.Metrics.Path != "" && !strings.HasPrefix(c.Metrics.Path, "/") {
+		invalid("metrics.path must start with /, got %q", c.Metrics.Path)
+	}
+	errs = append(errs, c.Auth.validate()...)
+	if c.Tenant.Default != "" && !tenant.Valid(c.Tenant.Default) {
+		invalid("tenant.default %q is not a valid tenant id", c.Tenant.Default)
+	}
+	if strings.ContainsAny(c.Tenant.BaseDomain, "/:") {
+		invalid("tenant.base_domain must be a bare domain, got %q", c.Tenant.BaseDomain)
+	}
+	if c.Events.BufferSize <= 0 {
+		invalid("events.buffer_size must be positive")
+	}
+	relay := &c.Outbox.Relay
+	if relay.PollInterval <= 0 || relay.BatchSize <= 0 || relay.Retention <= 0 || relay.MaxAttempts <= 0 {
+		invalid("outbox.poll_interval, batch_size, retention and max_attempts must be positive")
+	}
+	if c.Outbox.NATS.Addr != "" && c.Outbox.NATS.SubjectPrefix == "" {
+		invalid("outbox.nats.subject_prefix is required with outbox.nats.addr")
+	}
+	hooks := &c.Webhook
+	if hooks.PollInterval <= 0 || hooks.BatchSize <= 0 || hooks.MaxAttempts <= 0 ||
+		hooks.BaseBackoff <= 0 || hooks.MaxBackoff <= 0 || hooks.Timeout <= 0 {
+		invalid("webhook settings must be positive")
+	} else if hooks.MaxBackoff < hooks.BaseBackoff {
+		invalid("webhook.max_backoff must not be less than webhook.base_backoff")
+	}
+	if c.Idempotency.TTL <= 0 {
+		invalid("idempotency.ttl must be positive")
+	}
+	if c.Batch.MaxSize <= 0 {
+		invalid("batch.max_size must be positive")
+	}
+
+	switch c.HTTP.Listener {
+	case ListenerTCP:
+		if c.HTTP.Addr == "" {
+			invalid("http.addr is required for the tcp listener")
+		}
+	case ListenerUnix:
+		if c.HTTP.Socket == "" {
+			invalid("http.socket is required for the unix listener")
+		}
+	default:
+		invalid("http.listener must be tcp or unix, got %q", c.HTTP.Listener)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (a *Auth) validate() []error {
+	var errs []error
+	invalid := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%w: "+format, append([]interface{}{ErrInvalid}, args...)...))
+	}
+
+	if a.JWT.JWKS != "" {
+		if a.JWT.JWKSRefresh <= 0 {
+			invalid("auth.jwt.jwks_refresh must be positive")
+		}
+		if a.JWT.RoleClaim == "" || a.JWT.TenantClaim == "" {
+			invalid("auth.jwt.role_claim and auth.jwt.tenant_claim are required with auth.jwt.jwks")
+		}
+		// без них подходит любой токен, подписанный ключами JWKS, в том числе выпущенный для другого сервиса
+		if a.JWT.Issuer == "" || a.JWT.Audience == "" {
+			invalid("auth.jwt.issuer and auth.jwt.audience are required with auth.jwt.jwks")
+		}
+	}
+	// ключи по порядку, чтобы ошибки не менялись от запуска к запуску
+	roles := make([]string, 0, len(a.JWT.RoleMap))
+	for from := range a.JWT.RoleMap {
+		roles = append(roles, from)
+	}
+	sort.Strings(roles)
+	for _, from := range roles {
+		if to := a.JWT.RoleMap[from]; from == "" || !auth.ValidRole(to) {
+			invalid("auth.jwt.role_map %q=%q, expected <claim role>=<viewer|editor|pricing|admin>", from, to)
+		}
+	}
+	if a.Session.TTL <= 0 {
+		invalid("auth.session.ttl must be positive")
+	}
+	if a.Session.CookieName == "" {
+		invalid("auth.session.cookie_name is required")
+	}
+	return errs
+}
+
+// Masked копия для вывода, секреты заменены звездочками
+func (c *Config) Masked() *Config {
+	clone := *c
+	// в адресе JWKS бывают учетные данные и токены доступа
+	clone.Auth.JWT.JWKS = maskURL(c.Auth.JWT.JWKS)
+	if clone.Database.Password != "" {
+		clone.Database.Password = masked
+	}
+	if clone.Database.DSN != "" {
+		clone.Database.DSN = maskDSN(clone.Database.Dialect, clone.Database.DSN)
+	}
+	// срез общий с исходной конфигурацией, меняем копию
+	clone.Database.Replicas = append([]database.Replica(nil), c.Database.Replicas...)
+	for i := range clone.Database.Replicas {
+		if clone.Database.Replicas[i].DSN != "" {
+			clone.Database.Replicas[i].DSN = maskDSN(clone.Database.Dialect, clone.Database.Replicas[i].DSN)
+		}
+	}
+	return &clone
+}
+
+func (c *Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c)
+}
+
+func maskDSN(dialect, dsn string) string {
+	if dialect == database.DialectPostgres {
+		return maskPostgresDSN(dsn)
+	}
+	cfg, err := driver.ParseDSN(dsn)
+	if err != nil {
+		return masked
+	}
+	if cfg.Passwd != "" {
+		cfg.Passwd = masked
+	}
+	return cfg.FormatDSN()
+}
+
+// maskURL в URL заменяем пароль и значения параметров, путь к файлу оставляем как есть
+func maskURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return masked
+	}
+	if u.Scheme == "" {
+		return raw
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), masked)
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			query.Set(key, masked)
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+// maskPostgresDSN в URL заменяем пароль, строку key=value с паролем целиком
+func maskPostgresDSN(dsn string) string {
+	u, err := url.Parse(dsn)
+	if err != nil || u.Scheme == "" {
+		if strings.Contains(dsn, "password") {
+			return masked
+		}
+		return dsn
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), masked)
+	}
+	return u.String()
+}
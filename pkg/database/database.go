@@ -1,10 +1,21 @@
 package database
 
-import builder "github.com/doug-martin/goqu/v9"
+import (
+	"context"
+
+	builder "github.com/doug-martin/goqu/v9"
+)
 
 // тут мы пишем интефейс, для получения доступа к пулу коннектов базы данных
 // его мы и будем использовать, а не частный случай MySQL
 
 type Pool interface {
+	// Builder основная база, для записи и чтения, которое должно видеть все записи
 	Builder() *builder.Database
+	// Reader база для чтения, может быть репликой с отставанием
+	Reader(ctx context.Context) *builder.Database
+	// Schema схема с таблицами сервиса, см. Table
+	Schema() string
+	// Ping проверяет, что база отвечает
+	Ping(ctx context.Context) error
 }
@@ -0,0 +1,64 @@
+package dbtest
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grip211/crud/pkg/database"
+	"github.com/grip211/crud/pkg/database/mysql"
+	"github.com/grip211/crud/pkg/database/postgres"
+)
+
+// тут общие помощники тестов хранилищ, которым нужна настоящая база
+
+// ForEachDialect запускает тест для каждого диалекта из DB_DIALECTS (по умолчанию только mysql).
+// MySQL берет адрес из DB_*, PostgreSQL из PG_*
+func ForEachDialect(ctx context.Context, t *testing.T, test func(t *testing.T, conn database.Pool)) {
+	dialects := os.Getenv("DB_DIALECTS")
+	if dialects == "" {
+		dialects = database.DialectMySQL
+	}
+
+	for _, dialect := range strings.Split(dialects, ",") {
+		dialect = strings.TrimSpace(dialect)
+		t.Run(dialect, func(t *testing.T) {
+			opt := &database.Opt{
+				Dialect:            dialect,
+				MaxConnMaxLifetime: time.Minute * 5,
+				MaxOpenConns:       10,
+				MaxIdleConns:       9,
+				Debug:              true,
+			}
+
+			var (
+				conn database.Pool
+				err  error
+			)
+			switch dialect {
+			case database.DialectMySQL:
+				opt.Host = os.Getenv("DB_Host")
+				opt.User = os.Getenv("DB_USER")
+				opt.Password = os.Getenv("DB_PASS")
+				opt.Name = os.Getenv("DB_NAME")
+				conn, err = mysql.New(ctx, opt)
+			case database.DialectPostgres:
+				opt.Host = os.Getenv("PG_HOST")
+				opt.Port = os.Getenv("PG_PORT")
+				opt.User = os.Getenv("PG_USER")
+				opt.Password = os.Getenv("PG_PASS")
+				opt.Name = os.Getenv("PG_NAME")
+				conn, err = postgres.New(ctx, opt)
+			default:
+				t.Fatalf("unknown dialect %q", dialect)
+			}
+			require.NoError(t, err)
+
+			test(t, conn)
+		})
+	}
+}
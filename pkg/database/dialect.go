@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	builder "github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	mysql "github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+
+	"github.com/grip211/crud/pkg/tracing"
+)
+
+// тут различия MySQL и PostgreSQL, которые видны хранилищам: имена таблиц, id вставленных строк
+// и нарушение уникальности. Остальное goqu строит сам по диалекту пула
+
+const (
+	DialectMySQL    = "mysql"
+	DialectPostgres = "postgres"
+
+	// DefaultSchema схема с таблицами сервиса: в MySQL это отдельная база, в PostgreSQL схема внутри базы
+	DefaultSchema = "productdb"
+)
+
+const (
+	mysqlDuplicateEntry   = 1062
+	postgresUniqueViolate = "23505"
+)
+
+var ErrLastInsertID = errors.New("get last insert id")
+
+// Quotes кавычки диалекта для очистки текста запросов в логах и спанах
+func Quotes(dialect string) tracing.SQLQuotes {
+	if dialect == DialectPostgres {
+		return tracing.QuotesPostgres
+	}
+	return tracing.QuotesMySQL
+}
+
+// Table таблица name в схеме пула
+func Table(pool Pool, name string) exp.IdentifierExpression {
+	return builder.S(pool.Schema()).Table(name)
+}
+
+// InsertIDs выполняет INSERT и возвращает id вставленных строк по порядку. PostgreSQL отдает их
+// через RETURNING, MySQL только LastInsertId первой строки, остальные идут подряд
+// (для простых INSERT при auto_increment_increment = 1). rows число вставляемых строк
+func InsertIDs(ctx context.Context, insert *builder.InsertDataset, rows int) ([]int64, error) {
+	if insert.Dialect().Dialect() != DialectMySQL {
+		ids := make([]int64, 0, rows)
+		if err := insert.Returning("id").Executor().ScanValsContext(ctx, &ids); err != nil {
+			return nil, err
+		}
+		if len(ids) != rows {
+			return nil, fmt.Errorf("%w: inserted %d rows, expected %d", ErrLastInsertID, len(ids), rows)
+		}
+		return ids, nil
+	}
+
+	result, err := insert.Executor().ExecContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	first, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLastInsertID, err)
+	}
+	ids := make([]int64, rows)
+	for i := range ids {
+		ids[i] = first + int64(i)
+	}
+	return ids, nil
+}
+
+// InsertID InsertIDs для одной строки
+func InsertID(ctx context.Context, insert *builder.InsertDataset) (int64, error) {
+	ids, err := InsertIDs(ctx, insert, 1)
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+// IsDuplicate ошибка нарушения уникального ключа в любом из диалектов
+func IsDuplicate(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlDuplicateEntry
+	}
+	var postgresErr *pq.Error
+	if errors.As(err, &postgresErr) {
+		return postgresErr.Code == postgresUniqueViolate
+	}
+	return false
+}
@@ -1,15 +1,212 @@
 package database
 
-// тут пишем простую обертку для логирования запросов
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/grip211/crud/pkg/logging"
+)
+
+// тут пишем обертку над драйвером, которая измеряет время каждого запроса. В режиме отладки
+// пишем в лог все запросы, медленные пишем всегда и отдаем в метрики. Значения в лог не попадают:
+// литералы в тексте запроса заменяются на ?, от аргументов остается только их число.
+// Логгер берется из ctx запроса, поэтому в записи попадают request_id, пользователь и арендатор
+
+// QueryObserver получает медленные запросы, verb это первое слово запроса: select, insert...
+type QueryObserver interface {
+	ObserveSlowQuery(verb string, duration time.Duration)
+}
 
 type Logger struct {
-	callback func(format string, v ...interface{})
+	// Dialect диалект пула, от него зависит, какие кавычки в тексте запроса означают строку
+	Dialect string
+	// Debug писать в лог каждый запрос
+	Debug bool
+	// SlowThreshold запросы дольше считаются медленными, 0 отключает
+	SlowThreshold time.Duration
+	Observer      QueryObserver
+}
+
+// Connector оборачивает соединения коннектора драйвера
+func (l *Logger) Connector(connector driver.Connector) driver.Connector {
+	return &loggedConnector{Connector: connector, logger: l}
+}
+
+func (l *Logger) observe(ctx context.Context, query string, args []driver.NamedValue, started time.Time, err error) {
+	// драйвер попросил database/sql выполнить запрос иначе, замер будет на следующем вызове
+	if errors.Is(err, driver.ErrSkip) {
+		return
+	}
+	duration := time.Since(started)
+	logger := logging.FromContext(ctx)
+
+	if l.Debug {
+		logger.Debug("sql query",
+			"query", Quotes(l.Dialect).Sanitize(query),
+			"args", len(args),
+			"duration", duration,
+			"error", err,
+		)
+	}
+
+	if l.SlowThreshold > 0 && duration >= l.SlowThreshold {
+		logger.Warn("slow sql query",
+			"query", Quotes(l.Dialect).Sanitize(query),
+			"args", len(args),
+			"duration", duration,
+			"threshold", l.SlowThreshold,
+		)
+		if l.Observer != nil {
+			l.Observer.ObserveSlowQuery(Verb(query), duration)
+		}
+	}
+}
+
+// Verb первое слово запроса в нижнем регистре
+func Verb(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToLower(fields[0])
+}
+
+type loggedConnector struct {
+	driver.Connector
+	logger *Logger
+}
+
+func (c *loggedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &loggedConn{Conn: conn, logger: c.logger}, nil
+}
+
+// loggedConn повторяет необязательные интерфейсы соединения драйвера, иначе database/sql
+// перестанет ими пользоваться
+type loggedConn struct {
+	driver.Conn
+	logger *Logger
+}
+
+func (c *loggedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	started := time.Now()
+	result, err := execer.ExecContext(ctx, query, args)
+	c.logger.observe(ctx, query, args, started, err)
+	return result, err
+}
+
+func (c *loggedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	started := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	c.logger.observe(ctx, query, args, started, err)
+	return rows, err
+}
+
+func (c *loggedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &loggedStmt{Stmt: stmt, query: query, logger: c.logger}, nil
+}
+
+func (c *loggedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin() //nolint:staticcheck // запасной путь для драйверов без BeginTx
+}
+
+func (c *loggedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *loggedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *loggedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *loggedConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+type loggedStmt struct {
+	driver.Stmt
+	query  string
+	logger *Logger
+}
+
+func (s *loggedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	started := time.Now()
+	var (
+		result driver.Result
+		err    error
+	)
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		result, err = execer.ExecContext(ctx, args)
+	} else {
+		result, err = s.Stmt.Exec(values(args)) //nolint:staticcheck // запасной путь для старых драйверов
+	}
+	s.logger.observe(ctx, s.query, args, started, err)
+	return result, err
 }
 
-func (l *Logger) SetCallback(callback func(format string, v ...interface{})) {
-	l.callback = callback
+func (s *loggedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	started := time.Now()
+	var (
+		rows driver.Rows
+		err  error
+	)
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(values(args)) //nolint:staticcheck // запасной путь для старых драйверов
+	}
+	s.logger.observe(ctx, s.query, args, started, err)
+	return rows, err
 }
 
-func (l *Logger) Printf(format string, v ...interface{}) {
-	l.callback(format, v)
+func values(args []driver.NamedValue) []driver.Value {
+	result := make([]driver.Value, len(args))
+	for i := range args {
+		result[i] = args[i].Value
+	}
+	return result
 }
@@ -0,0 +1,220 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grip211/crud/pkg/logging"
+)
+
+const slowDelay = time.Millisecond * 30
+
+// fakeConnector драйвер без базы: запросы с SLEEP выполняются slowDelay, запросы с аргументами
+// отвечают ErrSkip, как mysql без interpolateParams, и database/sql выполняет их через Prepare
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return fakeConn{}, nil
+}
+
+func (fakeConnector) Driver() driver.Driver {
+	return nil
+}
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt{query: query}, nil
+}
+
+func (fakeConn) Close() error {
+	return nil
+}
+
+func (fakeConn) Begin() (driver.Tx, error) {
+	return nil, driver.ErrSkip
+}
+
+func (fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if len(args) > 0 {
+		return nil, driver.ErrSkip
+	}
+	execute(query)
+	return driver.RowsAffected(1), nil
+}
+
+func (fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if len(args) > 0 {
+		return nil, driver.ErrSkip
+	}
+	execute(query)
+	return fakeRows{}, nil
+}
+
+type fakeStmt struct {
+	query string
+}
+
+func (fakeStmt) Close() error {
+	return nil
+}
+
+func (fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	execute(s.query)
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	execute(s.query)
+	return fakeRows{}, nil
+}
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string {
+	return nil
+}
+
+func (fakeRows) Close() error {
+	return nil
+}
+
+func (fakeRows) Next([]driver.Value) error {
+	return io.EOF
+}
+
+func execute(query string) {
+	if strings.Contains(query, "SLEEP") {
+		time.Sleep(slowDelay)
+	}
+}
+
+type slowQueries struct {
+	mu    sync.Mutex
+	verbs []string
+}
+
+func (s *slowQueries) ObserveSlowQuery(verb string, _ time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.verbs = append(s.verbs, verb)
+}
+
+func TestLogger(t *testing.T) {
+	tests := []struct {
+		name      string
+		dialect   string
+		debug     bool
+		query     string
+		args      []any
+		exec      bool
+		wantLevel string
+		wantQuery string
+		wantVerbs []string
+	}{
+		{
+			name:  "fast query is not logged",
+			query: "SELECT * FROM Products WHERE id = 1",
+		},
+		{
+			name:      "slow query is logged without values",
+			query:     "SELECT SLEEP(1), name FROM Products WHERE name = 'secret'",
+			wantLevel: "WARN",
+			wantQuery: "SELECT SLEEP(?), name FROM Products WHERE name = ?",
+			wantVerbs: []string{"select"},
+		},
+		{
+			name:      "slow prepared statement",
+			query:     "UPDATE Products SET price = ? WHERE id = ? AND SLEEP(1) = 0",
+			args:      []any{100, 7},
+			exec:      true,
+			wantLevel: "WARN",
+			wantQuery: "UPDATE Products SET price = ? WHERE id = ? AND SLEEP(?) = ?",
+			wantVerbs: []string{"update"},
+		},
+		{
+			name:      "debug logs every query",
+			debug:     true,
+			query:     "DELETE FROM Products WHERE id = ?",
+			args:      []any{7},
+			exec:      true,
+			wantLevel: "DEBUG",
+			wantQuery: "DELETE FROM Products WHERE id = ?",
+		},
+		{
+			name:      "debug query is logged without values",
+			debug:     true,
+			query:     "UPDATE Products SET model = 'secret' WHERE company = ?",
+			args:      []any{"secret"},
+			exec:      true,
+			wantLevel: "DEBUG",
+			wantQuery: "UPDATE Products SET model = ? WHERE company = ?",
+		},
+		{
+			name:      "postgres identifiers are kept",
+			dialect:   DialectPostgres,
+			debug:     true,
+			query:     `UPDATE "Products" SET "model" = 'secret' WHERE "id" = $1`,
+			args:      []any{7},
+			exec:      true,
+			wantLevel: "DEBUG",
+			wantQuery: `UPDATE "Products" SET "model" = ? WHERE "id" = $1`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			ctx := logging.WithLogger(context.Background(), slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+			observer := &slowQueries{}
+			logger := &Logger{Dialect: tt.dialect, Debug: tt.debug, SlowThreshold: slowDelay / 2, Observer: observer}
+			db := sql.OpenDB(logger.Connector(fakeConnector{}))
+			defer db.Close()
+
+			if tt.exec {
+				_, err := db.ExecContext(ctx, tt.query, tt.args...)
+				require.NoError(t, err)
+			} else {
+				rows, err := db.QueryContext(ctx, tt.query, tt.args...)
+				require.NoError(t, err)
+				require.NoError(t, rows.Close())
+			}
+
+			require.Equal(t, tt.wantVerbs, observer.verbs)
+			if tt.wantLevel == "" {
+				require.Empty(t, out.String())
+				return
+			}
+
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			require.Len(t, lines, 1)
+			var record map[string]any
+			require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+			require.Equal(t, tt.wantLevel, record["level"])
+			require.Equal(t, tt.wantQuery, record["query"])
+			require.NotContains(t, lines[0], "secret")
+		})
+	}
+}
+
+func TestVerb(t *testing.T) {
+	require.Equal(t, "select", Verb("  SELECT 1"))
+	require.Equal(t, "insert", Verb("insert into t values (1)"))
+	require.Equal(t, "", Verb(""))
+}
@@ -0,0 +1,75 @@
+package mysql
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	driver "github.com/go-sql-driver/mysql"
+
+	"github.com/grip211/crud/pkg/database"
+)
+
+// тут собираем DSN через mysql.Config драйвера, чтобы не склеивать строку руками
+
+const (
+	defaultHost = "127.0.0.1"
+	defaultPort = "3306"
+)
+
+// DSN строка подключения по параметрам opt. Явный opt.DSN берется как есть, но parseTime
+// включается всегда, без него не сканируются поля времени
+func DSN(opt *database.Opt) (string, error) {
+	if opt.DSN != "" {
+		cfg, err := driver.ParseDSN(opt.DSN)
+		if err != nil {
+			return "", fmt.Errorf("%w: dsn: %v", database.ErrInvalidOpt, err)
+		}
+		cfg.ParseTime = true
+		return cfg.FormatDSN(), nil
+	}
+
+	host, port := opt.Host, opt.Port
+	// старый вид host:port, порт из Host берется, если Port не задан
+	if h, p, err := net.SplitHostPort(host); err == nil && port == "" {
+		host, port = h, p
+	}
+	if host == "" {
+		host = defaultHost
+	}
+	if port == "" {
+		port = defaultPort
+	}
+
+	cfg := driver.NewConfig()
+	cfg.User = opt.User
+	cfg.Passwd = opt.Password
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(host, port)
+	cfg.DBName = opt.Name
+	cfg.ParseTime = true
+	cfg.TLSConfig = opt.TLS
+	cfg.Timeout = opt.Timeout
+	cfg.ReadTimeout = opt.ReadTimeout
+	cfg.WriteTimeout = opt.WriteTimeout
+	if opt.Collation != "" {
+		cfg.Collation = opt.Collation
+	}
+	if opt.Charset != "" {
+		cfg.Params = map[string]string{"charset": opt.Charset}
+	}
+	if opt.Loc != "" {
+		loc, err := time.LoadLocation(opt.Loc)
+		if err != nil {
+			return "", fmt.Errorf("%w: loc: %v", database.ErrInvalidOpt, err)
+		}
+		cfg.Loc = loc
+	}
+
+	// обратный разбор проверяет то, что проверяет только драйвер, например имя TLS конфигурации
+	dsn := cfg.FormatDSN()
+	if _, err := driver.ParseDSN(dsn); err != nil {
+		return "", fmt.Errorf("%w: %v", database.ErrInvalidOpt, err)
+	}
+	return dsn, nil
+}
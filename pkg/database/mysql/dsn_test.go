@@ -0,0 +1,85 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grip211/crud/pkg/database"
+)
+
+func TestDSN(t *testing.T) {
+	tests := []struct {
+		name    string
+		opt     database.Opt
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			opt:  database.Opt{User: "shop", Name: "productdb"},
+			want: "shop@tcp(127.0.0.1:3306)/productdb?parseTime=true",
+		},
+		{
+			name: "host and port",
+			opt:  database.Opt{Host: "db", Port: "3307", User: "shop", Password: "secret", Name: "productdb"},
+			want: "shop:secret@tcp(db:3307)/productdb?parseTime=true",
+		},
+		{
+			name: "port in host",
+			opt:  database.Opt{Host: "db:3307", User: "shop"},
+			want: "shop@tcp(db:3307)/?parseTime=true",
+		},
+		{
+			name: "ipv6 host",
+			opt:  database.Opt{Host: "::1", User: "shop"},
+			want: "shop@tcp([::1]:3306)/?parseTime=true",
+		},
+		{
+			name: "tls, timeouts and session settings",
+			opt: database.Opt{
+				Host:         "db",
+				User:         "shop",
+				Name:         "productdb",
+				TLS:          "skip-verify",
+				Timeout:      time.Second * 5,
+				ReadTimeout:  time.Second * 30,
+				WriteTimeout: time.Second * 30,
+				Charset:      "utf8mb4",
+				Collation:    "utf8mb4_unicode_ci",
+				Loc:          "Europe/Moscow",
+			},
+			want: "shop@tcp(db:3306)/productdb?collation=utf8mb4_unicode_ci&loc=Europe%2FMoscow&parseTime=true" +
+				"&readTimeout=30s&timeout=5s&tls=skip-verify&writeTimeout=30s&charset=utf8mb4",
+		},
+		{
+			name: "unknown tls config",
+			// имя TLS конфигурации, не зарегистрированной в драйвере
+			opt:     database.Opt{Host: "db", User: "shop", TLS: "corporate"},
+			wantErr: true,
+		},
+		{
+			name: "dsn override gets parseTime",
+			opt:  database.Opt{DSN: "shop:secret@unix(/var/run/mysqld/mysqld.sock)/productdb", Host: "ignored"},
+			want: "shop:secret@unix(/var/run/mysqld/mysqld.sock)/productdb?parseTime=true",
+		},
+		{
+			name:    "broken dsn",
+			opt:     database.Opt{DSN: "shop:secret@tcp(db:3306)"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DSN(&tt.opt)
+			if tt.wantErr {
+				require.ErrorIs(t, err, database.ErrInvalidOpt)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -3,58 +3,114 @@ package mysql
 import (
 	"context"
 	"database/sql"
-	"fmt"
 	"time"
 
+	"github.com/XSAM/otelsql"
 	builder "github.com/doug-martin/goqu/v9"
+	driver "github.com/go-sql-driver/mysql"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 
 	// nolint:revive // it's OK
 	_ "github.com/doug-martin/goqu/v9/dialect/mysql"
-	_ "github.com/go-sql-driver/mysql"
 
 	"github.com/grip211/crud/pkg/database"
+	"github.com/grip211/crud/pkg/logging"
+	"github.com/grip211/crud/pkg/tracing"
 )
 
 // тут реализуем структуру, инкапсулирующая внутри себя коннекты с базой данных
 
+const dialect = database.DialectMySQL
+
 type ConnectionPool struct {
-	db *builder.Database
+	db     *builder.Database
+	sql    *sql.DB
+	retry  database.Retry
+	schema string
 }
 
 func (c *ConnectionPool) Builder() *builder.Database {
 	return c.db
 }
 
+// Reader без реплик читаем из той же базы
+func (c *ConnectionPool) Reader(context.Context) *builder.Database {
+	return c.db
+}
+
+// Schema в MySQL схема это база, таблицы указываются с ее именем
+func (c *ConnectionPool) Schema() string {
+	return c.schema
+}
+
+// DB пул database/sql, нужен для статистики соединений
+func (c *ConnectionPool) DB() *sql.DB {
+	return c.sql
+}
+
+func (c *ConnectionPool) Ping(ctx context.Context) error {
+	return c.sql.PingContext(ctx)
+}
+
+// New открывает пул и ждет, пока база ответит, с повторами по opt.Retry
 func New(ctx context.Context, opt *database.Opt) (*ConnectionPool, error) {
-	opt.UnwrapOrPanic()
+	connect, err := Open(opt, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = connect.Connect(ctx, func(attempt int, err error, delay time.Duration) {
+		logging.FromContext(ctx).Warn("database is not ready", "attempt", attempt, "error", err, "retry_in", delay.Round(time.Millisecond))
+	}); err != nil {
+		_ = connect.sql.Close()
+		return nil, err
+	}
+	return connect, nil
+}
 
-	db, err := sql.Open(opt.Dialect, opt.ConnectionString())
+// Open создает пул без обращения к базе, подключение проверяет Connect.
+// observer получает медленные запросы, может быть nil
+func Open(opt *database.Opt, observer database.QueryObserver) (*ConnectionPool, error) {
+	if err := opt.Validate(); err != nil {
+		return nil, err
+	}
+	dsn, err := DSN(opt)
 	if err != nil {
 		return nil, err
 	}
 
-	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
-	if err = db.PingContext(pingCtx); err != nil {
+	config, err := driver.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	connector, err := driver.NewConnector(config)
+	if err != nil {
 		return nil, err
 	}
+	logger := &database.Logger{
+		Dialect:       dialect,
+		Debug:         opt.Debug,
+		SlowThreshold: opt.SlowQueryThreshold,
+		Observer:      observer,
+	}
+
+	// каждый запрос получает спан с очищенным от значений текстом и замер времени для лога
+	db := otelsql.OpenDB(logger.Connector(connector), tracing.SQLOptions(semconv.DBSystemMySQL, database.Quotes(dialect))...)
 
 	db.SetMaxIdleConns(opt.MaxIdleConns)
 	db.SetMaxOpenConns(opt.MaxOpenConns)
 	db.SetConnMaxLifetime(opt.MaxConnMaxLifetime)
 
-	dialect := builder.Dialect(opt.Dialect)
 	connect := &ConnectionPool{
-		db: dialect.DB(db),
-	}
-
-	if opt.Debug {
-		logger := &database.Logger{}
-		logger.SetCallback(func(format string, v ...interface{}) {
-			fmt.Println(v)
-		})
-		connect.db.Logger(logger)
+		db:     builder.Dialect(dialect).DB(db),
+		sql:    db,
+		retry:  opt.Retry,
+		schema: opt.SchemaName(),
 	}
 
 	return connect, nil
 }
+
+// Connect ждет ответа базы с повторами, onRetry получает каждую неудачную попытку
+func (c *ConnectionPool) Connect(ctx context.Context, onRetry database.RetryFunc) error {
+	return c.retry.Ping(ctx, c.sql.PingContext, onRetry)
+}
@@ -1,49 +1,140 @@
 package database
 
 import (
+	"errors"
 	"fmt"
-	"strings"
+	"strconv"
 	"time"
 )
 
 // тут мы пишем простую обертку над параметрами подключения и их валидацией
 
+var ErrInvalidOpt = errors.New("invalid database options")
+
 type Opt struct {
-	Host               string        `yaml:"host"`
-	User               string        `yaml:"user"`
-	Password           string        `yaml:"password"`
-	Port               string        `yaml:"port"`
-	Name               string        `yaml:"name"`
+	// DSN если задан, используется как есть, поля подключения ниже игнорируются, настройки пула нет
+	DSN      string `yaml:"dsn"`
+	Host     string `yaml:"host"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Port     string `yaml:"port"`
+	Name     string `yaml:"name"`
+	// Schema схема с таблицами, по умолчанию DefaultSchema
+	Schema             string        `yaml:"schema"`
 	Dialect            string        `yaml:"dialect"`
 	Debug              bool          `yaml:"debug"`
 	MaxIdleConns       int           `yaml:"max_idle_conns"`
 	MaxOpenConns       int           `yaml:"max_open_conns"`
 	MaxConnMaxLifetime time.Duration `yaml:"max_conn_max_lifetime"`
+	// SlowQueryThreshold запросы дольше пишутся в лог и считаются в метриках, 0 отключает
+	SlowQueryThreshold time.Duration `yaml:"slow_query_threshold"`
+	// TLS true, false, skip-verify, preferred или имя зарегистрированной в драйвере конфигурации
+	TLS          string        `yaml:"tls"`
+	Timeout      time.Duration `yaml:"timeout"`
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	Charset      string        `yaml:"charset"`
+	Collation    string        `yaml:"collation"`
+	// Loc часовой пояс для значений time.Time, например UTC или Europe/Moscow
+	Loc string `yaml:"loc"`
+	// Retry повтор подключения при старте
+	Retry Retry `yaml:"retry"`
+	// Replicas реплики для чтения
+	Replicas []Replica `yaml:"replicas"`
+	// ReplicaCheckInterval как часто проверять реплики
+	ReplicaCheckInterval time.Duration `yaml:"replica_check_interval"`
 }
 
-func (o *Opt) UnwrapOrPanic() {
-	if o.Dialect == "" {
-		o.Dialect = "mysql"
-	}
-	if o.Host == "" {
-		o.Host = "@"
+// Validate проверяет параметры и возвращает все ошибки сразу
+func (o *Opt) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%w: "+format, append([]interface{}{ErrInvalidOpt}, args...)...))
 	}
-	if strings.EqualFold(o.Host, "@") {
-		o.Host = "@"
-	} else if !strings.Contains(o.Host, "@") {
-		o.Host = fmt.Sprintf("@tcp(%s)", o.Host)
+
+	if o.DSN == "" {
+		if o.Port != "" {
+			if port, err := strconv.Atoi(o.Port); err != nil || port <= 0 || port > 65535 {
+				invalid("port must be a number between 1 and 65535, got %q", o.Port)
+			}
+		}
+		if o.Password != "" && o.User == "" {
+			invalid("password requires user")
+		}
+		if o.Loc != "" {
+			if _, err := time.LoadLocation(o.Loc); err != nil {
+				invalid("unknown loc %q", o.Loc)
+			}
+		}
+		if o.Timeout < 0 || o.ReadTimeout < 0 || o.WriteTimeout < 0 {
+			invalid("timeouts must not be negative")
+		}
 	}
+
 	if o.MaxIdleConns <= 0 {
-		panic("max_idle_conns must be great than zero")
+		invalid("max_idle_conns must be greater than zero, got %d", o.MaxIdleConns)
 	}
 	if o.MaxOpenConns <= 0 {
-		panic("mqx_open_conns must be great than zero")
+		invalid("max_open_conns must be greater than zero, got %d", o.MaxOpenConns)
+	}
+	if o.MaxOpenConns > 0 && o.MaxIdleConns > o.MaxOpenConns {
+		invalid("max_idle_conns (%d) must not exceed max_open_conns (%d)", o.MaxIdleConns, o.MaxOpenConns)
+	}
+	if o.SlowQueryThreshold < 0 {
+		invalid("slow_query_threshold must not be negative, got %s", o.SlowQueryThreshold)
 	}
 	if o.MaxConnMaxLifetime <= 0 {
-		panic("max_conn_max_lifetime must be greater than zero")
+		invalid("max_conn_max_lifetime must be greater than zero, got %s", o.MaxConnMaxLifetime)
+	}
+
+	if o.Retry.Attempts < 0 || o.Retry.Backoff < 0 || o.Retry.MaxBackoff < 0 || o.Retry.PingTimeout < 0 {
+		invalid("retry settings must not be negative")
+	}
+	if o.Retry.MaxBackoff > 0 && o.Retry.Backoff > o.Retry.MaxBackoff {
+		invalid("retry.backoff (%s) must not exceed retry.max_backoff (%s)", o.Retry.Backoff, o.Retry.MaxBackoff)
+	}
+
+	for i, replica := range o.Replicas {
+		switch {
+		case o.DSN != "" && replica.DSN == "":
+			invalid("replicas[%d]: dsn is required when the primary is set by dsn", i)
+		case replica.DSN == "" && replica.Host == "":
+			invalid("replicas[%d]: host or dsn is required", i)
+		}
+	}
+	if len(o.Replicas) > 0 && o.ReplicaCheckInterval <= 0 {
+		invalid("replica_check_interval must be greater than zero, got %s", o.ReplicaCheckInterval)
+	}
+
+	return errors.Join(errs...)
+}
+
+// SchemaName схема с таблицами с учетом значения по умолчанию
+func (o *Opt) SchemaName() string {
+	if o.Schema == "" {
+		return DefaultSchema
 	}
+	return o.Schema
+}
+
+// Replica параметры подключения к реплике i: адрес реплики, остальное от основной базы
+func (o *Opt) Replica(i int) *Opt {
+	replica := *o
+	replica.Replicas = nil
+	replica.DSN = o.Replicas[i].DSN
+	replica.Host = o.Replicas[i].Host
+	replica.Port = o.Replicas[i].Port
+	return &replica
 }
 
-func (o *Opt) ConnectionString() string {
-	return fmt.Sprintf("%s:%s%s/%s?parseTime=true", o.User, o.Password, o.Host, o.Name)
+// ReplicaName имя реплики i для логов и метрик, без пароля из DSN
+func (o *Opt) ReplicaName(i int) string {
+	replica := o.Replicas[i]
+	if replica.DSN != "" {
+		return "replica-" + strconv.Itoa(i)
+	}
+	if replica.Port == "" {
+		return replica.Host
+	}
+	return replica.Host + ":" + replica.Port
 }
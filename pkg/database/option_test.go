@@ -0,0 +1,109 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func validOpt() Opt {
+	return Opt{
+		Host:               "db",
+		Port:               "3306",
+		User:               "shop",
+		Password:           "secret",
+		Name:               "productdb",
+		MaxIdleConns:       9,
+		MaxOpenConns:       10,
+		MaxConnMaxLifetime: time.Minute * 5,
+	}
+}
+
+func TestOpt_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(opt *Opt)
+		errors []string
+	}{
+		{name: "valid", modify: func(*Opt) {}},
+		{name: "default port", modify: func(opt *Opt) { opt.Port = "" }},
+		{name: "port is not a number", modify: func(opt *Opt) { opt.Port = "mysql" }, errors: []string{"port"}},
+		{name: "port out of range", modify: func(opt *Opt) { opt.Port = "70000" }, errors: []string{"port"}},
+		{name: "password without user", modify: func(opt *Opt) { opt.User = "" }, errors: []string{"password requires user"}},
+		{name: "unknown loc", modify: func(opt *Opt) { opt.Loc = "Mars/Olympus" }, errors: []string{"loc"}},
+		{name: "negative timeout", modify: func(opt *Opt) { opt.ReadTimeout = -time.Second }, errors: []string{"timeouts"}},
+		{name: "negative slow query threshold", modify: func(opt *Opt) { opt.SlowQueryThreshold = -time.Second }, errors: []string{"slow_query_threshold"}},
+		{
+			name: "dsn skips connection fields",
+			modify: func(opt *Opt) {
+				opt.DSN = "shop:secret@tcp(db:3306)/productdb"
+				opt.Port = "mysql"
+			},
+		},
+		{
+			name: "all pool errors at once",
+			modify: func(opt *Opt) {
+				opt.MaxIdleConns = 0
+				opt.MaxOpenConns = 0
+				opt.MaxConnMaxLifetime = 0
+			},
+			errors: []string{"max_idle_conns", "max_open_conns", "max_conn_max_lifetime"},
+		},
+		{
+			name:   "idle above open",
+			modify: func(opt *Opt) { opt.MaxIdleConns = 11 },
+			errors: []string{"must not exceed max_open_conns"},
+		},
+		{
+			name: "replica without address",
+			modify: func(opt *Opt) {
+				opt.Replicas = []Replica{{Host: "db-replica"}, {Port: "3307"}}
+			},
+			errors: []string{"replicas[1]: host or dsn is required", "replica_check_interval"},
+		},
+		{
+			name: "replica of a dsn primary",
+			modify: func(opt *Opt) {
+				opt.DSN = "shop@tcp(db:3306)/productdb"
+				opt.Replicas = []Replica{{Host: "db-replica"}}
+				opt.ReplicaCheckInterval = time.Second
+			},
+			errors: []string{"replicas[0]: dsn is required"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt := validOpt()
+			tt.modify(&opt)
+
+			err := opt.Validate()
+			if len(tt.errors) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrInvalidOpt)
+			for _, message := range tt.errors {
+				require.Contains(t, err.Error(), message)
+			}
+		})
+	}
+}
+
+func TestOpt_Replica(t *testing.T) {
+	opt := validOpt()
+	opt.User = "shop"
+	opt.Replicas = []Replica{{Host: "db-replica", Port: "3307"}, {DSN: "shop:secret@tcp(db-replica-2:3306)/productdb"}}
+
+	replica := opt.Replica(0)
+	require.Equal(t, "db-replica", replica.Host)
+	require.Equal(t, "3307", replica.Port)
+	require.Equal(t, "shop", replica.User)
+	require.Equal(t, opt.MaxOpenConns, replica.MaxOpenConns)
+	require.Empty(t, replica.Replicas)
+	require.Len(t, opt.Replicas, 2)
+
+	require.Equal(t, "db-replica:3307", opt.ReplicaName(0))
+	require.Equal(t, "replica-1", opt.ReplicaName(1))
+}
@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/grip211/crud/pkg/database"
+)
+
+// тут собираем URL подключения для lib/pq из тех же параметров, что и для MySQL
+
+const (
+	defaultHost = "127.0.0.1"
+	defaultPort = "5432"
+)
+
+// sslModes значения opt.TLS в sslmode, без TLS по умолчанию, как у MySQL
+var sslModes = map[string]string{
+	"":            "disable",
+	"false":       "disable",
+	"true":        "verify-full",
+	"skip-verify": "require",
+}
+
+// DSN строка подключения по параметрам opt. Явный opt.DSN (URL или key=value) берется как есть
+func DSN(opt *database.Opt) (string, error) {
+	if opt.DSN != "" {
+		if _, err := pq.NewConnector(opt.DSN); err != nil {
+			return "", fmt.Errorf("%w: dsn: %v", database.ErrInvalidOpt, err)
+		}
+		return opt.DSN, nil
+	}
+
+	// у lib/pq нет таймаутов чтения и записи и выбора collation, молча их не игнорируем
+	if opt.ReadTimeout != 0 || opt.WriteTimeout != 0 {
+		return "", fmt.Errorf("%w: read_timeout and write_timeout are not supported by postgres", database.ErrInvalidOpt)
+	}
+	if opt.Collation != "" {
+		return "", fmt.Errorf("%w: collation is not supported by postgres", database.ErrInvalidOpt)
+	}
+	sslMode, ok := sslModes[opt.TLS]
+	if !ok {
+		return "", fmt.Errorf("%w: tls %q is not supported by postgres, use true, false or skip-verify", database.ErrInvalidOpt, opt.TLS)
+	}
+
+	host, port := opt.Host, opt.Port
+	// старый вид host:port, порт из Host берется, если Port не задан
+	if h, p, err := net.SplitHostPort(host); err == nil && port == "" {
+		host, port = h, p
+	}
+	if host == "" {
+		host = defaultHost
+	}
+	if port == "" {
+		port = defaultPort
+	}
+
+	query := url.Values{}
+	query.Set("sslmode", sslMode)
+	if opt.Timeout > 0 {
+		// connect_timeout в целых секундах, округляем вверх
+		query.Set("connect_timeout", strconv.Itoa(int((opt.Timeout+time.Second-1)/time.Second)))
+	}
+	if opt.Charset != "" {
+		query.Set("client_encoding", opt.Charset)
+	}
+	if opt.Loc != "" {
+		query.Set("timezone", opt.Loc)
+	}
+
+	dsn := url.URL{
+		Scheme:   "postgres",
+		Host:     net.JoinHostPort(host, port),
+		Path:     "/" + opt.Name,
+		RawQuery: query.Encode(),
+	}
+	if opt.Password != "" {
+		dsn.User = url.UserPassword(opt.User, opt.Password)
+	} else if opt.User != "" {
+		dsn.User = url.User(opt.User)
+	}
+	return dsn.String(), nil
+}
@@ -0,0 +1,85 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grip211/crud/pkg/database"
+)
+
+func TestDSN(t *testing.T) {
+	tests := []struct {
+		name    string
+		opt     database.Opt
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			opt:  database.Opt{User: "shop", Name: "productdb"},
+			want: "postgres://shop@127.0.0.1:5432/productdb?sslmode=disable",
+		},
+		{
+			name: "host and port",
+			opt:  database.Opt{Host: "db", Port: "5433", User: "shop", Password: "s3cret/:", Name: "productdb"},
+			want: "postgres://shop:s3cret%2F%3A@db:5433/productdb?sslmode=disable",
+		},
+		{
+			name: "port in host",
+			opt:  database.Opt{Host: "db:5433", User: "shop"},
+			want: "postgres://shop@db:5433/?sslmode=disable",
+		},
+		{
+			name: "ipv6 host",
+			opt:  database.Opt{Host: "::1", User: "shop"},
+			want: "postgres://shop@[::1]:5432/?sslmode=disable",
+		},
+		{
+			name: "tls, timeout and session settings",
+			opt: database.Opt{
+				Host:    "db",
+				User:    "shop",
+				Name:    "productdb",
+				TLS:     "true",
+				Timeout: time.Millisecond * 1500,
+				Charset: "UTF8",
+				Loc:     "Europe/Moscow",
+			},
+			want: "postgres://shop@db:5432/productdb?client_encoding=UTF8&connect_timeout=2&sslmode=verify-full&timezone=Europe%2FMoscow",
+		},
+		{
+			name:    "unsupported tls",
+			opt:     database.Opt{Host: "db", User: "shop", TLS: "corporate"},
+			wantErr: true,
+		},
+		{
+			name:    "read timeout",
+			opt:     database.Opt{Host: "db", User: "shop", ReadTimeout: time.Second},
+			wantErr: true,
+		},
+		{
+			name: "dsn override",
+			opt:  database.Opt{DSN: "host=/var/run/postgresql dbname=productdb", Host: "ignored"},
+			want: "host=/var/run/postgresql dbname=productdb",
+		},
+		{
+			name:    "broken dsn",
+			opt:     database.Opt{DSN: "postgres://shop@db:port/productdb"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DSN(&tt.opt)
+			if tt.wantErr {
+				require.ErrorIs(t, err, database.ErrInvalidOpt)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	builder "github.com/doug-martin/goqu/v9"
+
+	"github.com/grip211/crud/pkg/logging"
+)
+
+// тут основная база с репликами для чтения. Запись и чтение в транзакциях идут в основную базу,
+// остальное чтение распределяется по живым репликам. После записи запрос до конца читает из основной
+// базы, иначе из-за отставания реплики он может не увидеть свое же изменение
+
+// Replica адрес реплики, пользователь, пароль и настройки пула берутся у основной базы
+type Replica struct {
+	// DSN если задан, используется как есть
+	DSN  string `yaml:"dsn"`
+	Host string `yaml:"host"`
+	Port string `yaml:"port"`
+}
+
+type sessionKey struct{}
+
+// session отмечает, что запрос уже писал в базу. Общий указатель нужен потому, что запись
+// происходит глубже по стеку, а читать после нее может вызывающий код со своим ctx
+type session struct {
+	written atomic.Bool
+}
+
+// WithSession начинает сессию чтения своих записей, обычно на весь HTTP запрос
+func WithSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionKey{}, &session{})
+}
+
+// MarkWritten отмечает запись в сессии ctx, без сессии ничего не делает
+func MarkWritten(ctx context.Context) {
+	if s, ok := ctx.Value(sessionKey{}).(*session); ok {
+		s.written.Store(true)
+	}
+}
+
+type primaryKey struct{}
+
+// UsePrimary чтение в ctx идет в основную базу, например когда результат будет долго храниться в кеше
+func UsePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// primaryOnly чтение в ctx должно видеть все записи
+func primaryOnly(ctx context.Context) bool {
+	if ctx.Value(primaryKey{}) != nil {
+		return true
+	}
+	s, ok := ctx.Value(sessionKey{}).(*session)
+	return ok && s.written.Load()
+}
+
+type replica struct {
+	name    string
+	pool    Pool
+	healthy atomic.Bool
+}
+
+// ReplicaObserver получает состояние реплик, например для метрик
+type ReplicaObserver interface {
+	ObserveReplica(name string, healthy bool)
+}
+
+type Replicated struct {
+	primary  Pool
+	replicas []*replica
+	next     atomic.Uint64
+	observer ReplicaObserver
+}
+
+// NewReplicated до первой удачной проверки реплики не используются, names нужны для логов и метрик
+func NewReplicated(primary Pool, replicas []Pool, names []string) *Replicated {
+	r := &Replicated{
+		primary:  primary,
+		replicas: make([]*replica, len(replicas)),
+	}
+	for i := range replicas {
+		r.replicas[i] = &replica{name: names[i], pool: replicas[i]}
+	}
+	return r
+}
+
+// WithObserver включает учет состояния реплик, вызывать до начала работы
+func (r *Replicated) WithObserver(observer ReplicaObserver) *Replicated {
+	r.observer = observer
+	return r
+}
+
+// Builder основная база
+func (r *Replicated) Builder() *builder.Database {
+	return r.primary.Builder()
+}
+
+// Reader следующая живая реплика по кругу, основная база, если живых нет или ctx уже писал
+func (r *Replicated) Reader(ctx context.Context) *builder.Database {
+	if primaryOnly(ctx) {
+		return r.primary.Builder()
+	}
+	n := len(r.replicas)
+	start := r.next.Add(1)
+	for i := 0; i < n; i++ {
+		if candidate := r.replicas[(start+uint64(i))%uint64(n)]; candidate.healthy.Load() {
+			return candidate.pool.Builder()
+		}
+	}
+	return r.primary.Builder()
+}
+
+func (r *Replicated) Schema() string {
+	return r.primary.Schema()
+}
+
+// Ping проверяет основную базу, без реплик сервис продолжает работать
+func (r *Replicated) Ping(ctx context.Context) error {
+	return r.primary.Ping(ctx)
+}
+
+// Run проверяет реплики каждые interval, пока ctx не отменен
+func (r *Replicated) Run(ctx context.Context, interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		r.Check(ctx, timeout)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check пингует реплики и пишет в лог смену их состояния
+func (r *Replicated) Check(ctx context.Context, timeout time.Duration) {
+	logger := logging.FromContext(ctx)
+	for _, candidate := range r.replicas {
+		pingContext, cancel := context.WithTimeout(ctx, timeout)
+		err := candidate.pool.Ping(pingContext)
+		cancel()
+		if ctx.Err() != nil {
+			// остановка сервиса, реплика тут ни при чем
+			return
+		}
+
+		healthy := err == nil
+		if candidate.healthy.Swap(healthy) != healthy {
+			if healthy {
+				logger.Info("replica is back", "replica", candidate.name)
+			} else {
+				logger.Warn("replica is down, reading from other replicas or the primary", "replica", candidate.name, "error", err)
+			}
+		}
+		if r.observer != nil {
+			r.observer.ObserveReplica(candidate.name, healthy)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	builder "github.com/doug-martin/goqu/v9"
+	"github.com/stretchr/testify/require"
+)
+
+var errDown = errors.New("connection refused")
+
+type fakePool struct {
+	db   *builder.Database
+	down atomic.Bool
+}
+
+func newFakePool() *fakePool {
+	return &fakePool{db: builder.New("mysql", sql.OpenDB(fakeConnector{}))}
+}
+
+func (p *fakePool) Builder() *builder.Database {
+	return p.db
+}
+
+func (p *fakePool) Reader(context.Context) *builder.Database {
+	return p.db
+}
+
+func (p *fakePool) Schema() string {
+	return DefaultSchema
+}
+
+func (p *fakePool) Ping(context.Context) error {
+	if p.down.Load() {
+		return errDown
+	}
+	return nil
+}
+
+type replicaStates map[string]bool
+
+func (s replicaStates) ObserveReplica(name string, healthy bool) {
+	s[name] = healthy
+}
+
+func TestReplicated_Reader(t *testing.T) {
+	ctx := context.Background()
+	primary, first, second := newFakePool(), newFakePool(), newFakePool()
+	states := replicaStates{}
+	pool := NewReplicated(primary, []Pool{first, second}, []string{"first", "second"}).WithObserver(states)
+
+	// до первой проверки реплики не используются
+	require.Same(t, primary.db, pool.Reader(ctx))
+
+	pool.Check(ctx, time.Second)
+	require.Equal(t, replicaStates{"first": true, "second": true}, states)
+	require.ElementsMatch(t, []*builder.Database{first.db, second.db}, []*builder.Database{pool.Reader(ctx), pool.Reader(ctx)})
+
+	second.down.Store(true)
+	pool.Check(ctx, time.Second)
+	require.False(t, states["second"])
+	for i := 0; i < 3; i++ {
+		require.Same(t, first.db, pool.Reader(ctx))
+	}
+
+	first.down.Store(true)
+	pool.Check(ctx, time.Second)
+	require.Same(t, primary.db, pool.Reader(ctx))
+
+	// реплика вернулась
+	second.down.Store(false)
+	pool.Check(ctx, time.Second)
+	require.Same(t, second.db, pool.Reader(ctx))
+	require.Same(t, primary.db, pool.Builder())
+}
+
+func TestReplicated_ReadYourWrites(t *testing.T) {
+	primary, replica := newFakePool(), newFakePool()
+	pool := NewReplicated(primary, []Pool{replica}, []string{"replica"})
+	pool.Check(context.Background(), time.Second)
+
+	tests := []struct {
+		name string
+		ctx  func() context.Context
+		want *builder.Database
+	}{
+		{
+			name: "no session",
+			ctx:  context.Background,
+			want: replica.db,
+		},
+		{
+			name: "session without writes",
+			ctx: func() context.Context {
+				return WithSession(context.Background())
+			},
+			want: replica.db,
+		},
+		{
+			name: "session after write",
+			ctx: func() context.Context {
+				ctx := WithSession(context.Background())
+				// запись отмечается в производном ctx, а читает исходный
+				derived, cancel := context.WithTimeout(ctx, time.Second)
+				defer cancel()
+				MarkWritten(derived)
+				return ctx
+			},
+			want: primary.db,
+		},
+		{
+			name: "write without session",
+			ctx: func() context.Context {
+				ctx := context.Background()
+				MarkWritten(ctx)
+				return ctx
+			},
+			want: replica.db,
+		},
+		{
+			name: "primary requested",
+			ctx: func() context.Context {
+				return UsePrimary(context.Background())
+			},
+			want: primary.db,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Same(t, tt.want, pool.Reader(tt.ctx()))
+		})
+	}
+}
+
+func TestReplicated_CheckCanceled(t *testing.T) {
+	replica := newFakePool()
+	pool := NewReplicated(newFakePool(), []Pool{replica}, []string{"replica"})
+	pool.Check(context.Background(), time.Second)
+
+	// остановка сервиса не делает реплику недоступной
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	replica.down.Store(true)
+	pool.Check(ctx, time.Second)
+	require.Same(t, replica.db, pool.Reader(context.Background()))
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// тут повтор подключения к базе при старте: база в compose поднимается дольше сервиса
+
+type Retry struct {
+	// Attempts сколько раз пробовать, 0 и 1 значат одну попытку
+	Attempts int `yaml:"attempts"`
+	// Backoff задержка перед второй попыткой, дальше она удваивается до MaxBackoff
+	Backoff     time.Duration `yaml:"backoff"`
+	MaxBackoff  time.Duration `yaml:"max_backoff"`
+	PingTimeout time.Duration `yaml:"ping_timeout"`
+}
+
+func (r Retry) withDefaults() Retry {
+	if r.Attempts <= 0 {
+		r.Attempts = 1
+	}
+	if r.Backoff <= 0 {
+		r.Backoff = time.Millisecond * 500
+	}
+	if r.MaxBackoff <= 0 {
+		r.MaxBackoff = time.Second * 30
+	}
+	if r.PingTimeout <= 0 {
+		r.PingTimeout = time.Second * 5
+	}
+	return r
+}
+
+// Delay задержка после attempt неудачных попыток: половина экспоненты плюс случайная добавка до второй половины,
+// чтобы несколько реплик не стучались в базу одновременно
+func (r Retry) Delay(attempt int) time.Duration {
+	r = r.withDefaults()
+	delay := r.Backoff
+	for i := 1; i < attempt && delay < r.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > r.MaxBackoff {
+		delay = r.MaxBackoff
+	}
+	// nolint:gosec // для джиттера криптостойкий генератор не нужен
+	return delay/2 + time.Duration(rand.Int63n(int64(delay)/2+1))
+}
+
+// RetryFunc вызывается после каждой неудачной попытки, кроме последней
+type RetryFunc func(attempt int, err error, delay time.Duration)
+
+// Ping пробует ping до успеха, исчерпания попыток или отмены ctx
+func (r Retry) Ping(ctx context.Context, ping func(ctx context.Context) error, onRetry RetryFunc) error {
+	r = r.withDefaults()
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, r.PingTimeout)
+		err := ping(pingCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if attempt >= r.Attempts {
+			return fmt.Errorf("connect to database after %d attempts: %w", attempt, err)
+		}
+
+		delay := r.Delay(attempt)
+		if onRetry != nil {
+			onRetry(attempt, err, delay)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetry_Delay(t *testing.T) {
+	retry := Retry{Backoff: time.Second, MaxBackoff: time.Second * 5}
+
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{attempt: 1, max: time.Second},
+		{attempt: 2, max: time.Second * 2},
+		{attempt: 3, max: time.Second * 4},
+		{attempt: 4, max: time.Second * 5},
+		{attempt: 10, max: time.Second * 5},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			delay := retry.Delay(tt.attempt)
+			require.GreaterOrEqual(t, delay, tt.max/2)
+			require.LessOrEqual(t, delay, tt.max)
+		}
+	}
+}
+
+func TestRetry_Ping(t *testing.T) {
+	errDown := errors.New("database is down")
+	retry := Retry{Attempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond * 2}
+
+	tests := []struct {
+		name     string
+		failures int
+		retries  int
+		wantErr  bool
+	}{
+		{name: "first attempt", failures: 0, retries: 0},
+		{name: "after retries", failures: 2, retries: 2},
+		{name: "attempts exhausted", failures: 5, retries: 2, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failures, retries := tt.failures, 0
+			err := retry.Ping(context.Background(), func(context.Context) error {
+				if failures > 0 {
+					failures--
+					return errDown
+				}
+				return nil
+			}, func(int, error, time.Duration) {
+				retries++
+			})
+			if tt.wantErr {
+				require.ErrorIs(t, err, errDown)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.retries, retries)
+		})
+	}
+}
+
+func TestRetry_PingCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	retry := Retry{Attempts: 100, Backoff: time.Hour}
+
+	err := retry.Ping(ctx, func(context.Context) error {
+		return errors.New("database is down")
+	}, func(int, error, time.Duration) {
+		// отмена во время ожидания следующей попытки
+		cancel()
+	})
+	require.ErrorIs(t, err, context.Canceled)
+}
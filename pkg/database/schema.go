@@ -0,0 +1,21 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	builder "github.com/doug-martin/goqu/v9"
+)
+
+// SchemaVersion версия схемы из таблицы SchemaMigrations, 0 если миграции не записаны
+func SchemaVersion(ctx context.Context, pool Pool) (int, error) {
+	var version int
+	_, err := pool.Builder().
+		From(Table(pool, "SchemaMigrations")).
+		Select(builder.COALESCE(builder.MAX("version"), 0)).
+		ScanValContext(ctx, &version)
+	if err != nil {
+		return 0, fmt.Errorf("fetch schema version: %w", err)
+	}
+	return version, nil
+}
@@ -0,0 +1,158 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// тут реализуем простую шину событий внутри процесса с ограниченным буфером последних событий,
+// по которому подписчик может догнать пропущенное (Last-Event-ID в SSE)
+
+const subscriptionBuffer = 64
+
+type Bus struct {
+	mu          sync.Mutex
+	seq         uint64
+	buffer      []Event
+	seen        map[uint64]struct{}
+	next        int
+	full        bool
+	subscribers map[*Subscription]struct{}
+}
+
+func NewBus(size int) *Bus {
+	if size <= 0 {
+		size = 1
+	}
+	return &Bus{
+		buffer:      make([]Event, size),
+		seen:        make(map[uint64]struct{}, size),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish нумерует события без номера, кладет их в буфер и рассылает подписчикам.
+// Подписчик, который не успевает читать, отключается, переподключиться он может с Last-Event-ID
+func (b *Bus) Publish(events ...Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i := range events {
+		event := events[i]
+		if event.ID == 0 {
+			b.seq++
+			event.ID = b.seq
+		} else {
+			// события с номером (из outbox) могут прийти повторно, дубли в пределах буфера не рассылаем.
+			// Порядок номеров не гарантирован: relay повторяет сообщение товара, пока публикует другие
+			if _, ok := b.seen[event.ID]; ok {
+				continue
+			}
+			b.seq = max(b.seq, event.ID)
+		}
+		if event.At.IsZero() {
+			event.At = time.Now()
+		}
+
+		if b.full {
+			delete(b.seen, b.buffer[b.next].ID)
+		}
+		b.seen[event.ID] = struct{}{}
+		b.buffer[b.next] = event
+		b.next = (b.next + 1) % len(b.buffer)
+		if b.next == 0 {
+			b.full = true
+		}
+
+		for sub := range b.subscribers {
+			if !sub.filter.Match(&event) {
+				continue
+			}
+			select {
+			case sub.ch <- event:
+			default:
+				b.remove(sub)
+			}
+		}
+	}
+}
+
+// Subscribe подписывает на события, подходящие под filter. Если lastID больше нуля, сначала
+// возвращаются события из буфера, пришедшие после события lastID. Номера из outbox приходят
+// не по порядку, поэтому ищем место события в буфере, а не сравниваем номера. Если события
+// в буфере уже нет, возвращается весь буфер: все, что в нем осталось, пришло позже
+func (b *Bus) Subscribe(lastID uint64, filter Filter) (*Subscription, []Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var missed []Event
+	if lastID > 0 {
+		buffered := b.snapshot()
+		if _, ok := b.seen[lastID]; ok {
+			for i := range buffered {
+				if buffered[i].ID == lastID {
+					buffered = buffered[i+1:]
+					break
+				}
+			}
+		}
+		for _, event := range buffered {
+			if filter.Match(&event) {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	sub := &Subscription{
+		bus:    b,
+		filter: filter,
+		ch:     make(chan Event, subscriptionBuffer),
+	}
+	b.subscribers[sub] = struct{}{}
+
+	return sub, missed
+}
+
+// Close отключает всех подписчиков
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subscribers {
+		b.remove(sub)
+	}
+}
+
+func (b *Bus) snapshot() []Event {
+	if !b.full {
+		return b.buffer[:b.next]
+	}
+	events := make([]Event, 0, len(b.buffer))
+	events = append(events, b.buffer[b.next:]...)
+	return append(events, b.buffer[:b.next]...)
+}
+
+func (b *Bus) remove(sub *Subscription) {
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.ch)
+	}
+}
+
+type Subscription struct {
+	bus    *Bus
+	filter Filter
+	ch     chan Event
+}
+
+// C канал событий, закрывается при отключении подписчика
+func (s *Subscription) C() <-chan Event {
+	return s.ch
+}
+
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	s.bus.remove(s)
+}
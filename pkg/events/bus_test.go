@@ -0,0 +1,140 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus_Subscribe(t *testing.T) {
+	bus := NewBus(3)
+	bus.Publish(
+		Event{Type: TypeCreated, ProductID: 1, Company: "Apple"},
+		Event{Type: TypeCreated, ProductID: 2, Company: "Google"},
+		Event{Type: TypeUpdated, ProductID: 1, Company: "Apple"},
+		Event{Type: TypeStock, ProductID: 1, Company: "Apple"},
+	)
+
+	tests := []struct {
+		name    string
+		lastID  uint64
+		filter  Filter
+		wantIDs []uint64
+	}{
+		{
+			name:    "without last id nothing is replayed",
+			wantIDs: nil,
+		},
+		{
+			name:    "replay is limited by the buffer size",
+			lastID:  1,
+			wantIDs: []uint64{2, 3, 4},
+		},
+		{
+			name:    "replay after last id",
+			lastID:  3,
+			wantIDs: []uint64{4},
+		},
+		{
+			name:    "replay is filtered by company",
+			lastID:  1,
+			filter:  Filter{Company: "Apple"},
+			wantIDs: []uint64{3, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, missed := bus.Subscribe(tt.lastID, tt.filter)
+			defer sub.Close()
+
+			var ids []uint64
+			for _, event := range missed {
+				ids = append(ids, event.ID)
+			}
+			require.Equal(t, tt.wantIDs, ids)
+		})
+	}
+}
+
+func TestBus_Publish(t *testing.T) {
+	bus := NewBus(10)
+
+	apple, _ := bus.Subscribe(0, Filter{Company: "Apple"})
+	product, _ := bus.Subscribe(0, Filter{ProductID: 2})
+
+	bus.Publish(
+		Event{Type: TypeCreated, ProductID: 1, Company: "Apple"},
+		Event{Type: TypeCreated, ProductID: 2, Company: "Google"},
+	)
+
+	require.Equal(t, 1, (<-apple.C()).ProductID)
+	require.Equal(t, 2, (<-product.C()).ProductID)
+
+	bus.Close()
+	_, ok := <-apple.C()
+	require.False(t, ok)
+}
+
+func TestBus_PublishOutOfOrder(t *testing.T) {
+	bus := NewBus(10)
+	sub, _ := bus.Subscribe(0, Filter{})
+
+	bus.Publish(Event{ID: 5, Type: TypeUpdated, ProductID: 1})
+	// повтор из outbox пришел после более позднего события другого товара
+	bus.Publish(Event{ID: 3, Type: TypeUpdated, ProductID: 2})
+	bus.Publish(Event{ID: 5, Type: TypeUpdated, ProductID: 1})
+	bus.Publish(Event{Type: TypeCreated, ProductID: 3})
+
+	var got []uint64
+	for i := 0; i < 3; i++ {
+		got = append(got, (<-sub.C()).ID)
+	}
+	require.Equal(t, []uint64{5, 3, 6}, got)
+	require.Empty(t, sub.C())
+
+	_, missed := bus.Subscribe(1, Filter{})
+	require.Len(t, missed, 3)
+}
+
+func TestBus_SubscribeOutOfOrder(t *testing.T) {
+	bus := NewBus(4)
+	bus.Publish(
+		Event{ID: 5, Type: TypeUpdated, ProductID: 1},
+		Event{ID: 7, Type: TypeUpdated, ProductID: 2},
+		// relay повторял событие товара 3 и опубликовал его после более позднего
+		Event{ID: 6, Type: TypeUpdated, ProductID: 3},
+		Event{ID: 8, Type: TypeUpdated, ProductID: 2},
+	)
+
+	tests := []struct {
+		name    string
+		lastID  uint64
+		wantIDs []uint64
+	}{
+		{name: "lower id that arrived later is replayed", lastID: 7, wantIDs: []uint64{6, 8}},
+		{name: "replay by arrival order", lastID: 6, wantIDs: []uint64{8}},
+		{name: "last event", lastID: 8, wantIDs: nil},
+		{name: "unknown id replays the whole buffer", lastID: 4, wantIDs: []uint64{5, 7, 6, 8}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, missed := bus.Subscribe(tt.lastID, Filter{})
+			defer sub.Close()
+
+			var ids []uint64
+			for _, event := range missed {
+				ids = append(ids, event.ID)
+			}
+			require.Equal(t, tt.wantIDs, ids)
+		})
+	}
+
+	// вытесненное событие: все, что осталось в буфере, пришло после него
+	bus.Publish(Event{ID: 9, Type: TypeUpdated, ProductID: 1})
+	sub, missed := bus.Subscribe(5, Filter{})
+	defer sub.Close()
+	require.Len(t, missed, 4)
+	require.Equal(t, uint64(7), missed[0].ID)
+}
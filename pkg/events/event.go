@@ -0,0 +1,55 @@
+package events
+
+import (
+	"time"
+
+	"github.com/grip211/crud/pkg/models"
+)
+
+// тут описываем события изменения товаров, которые репозиторий публикует после успешного коммита
+
+const (
+	TypeCreated = "product.created"
+	TypeUpdated = "product.updated"
+	TypeDeleted = "product.deleted"
+	// TypeStock изменилось количество товара на складе
+	TypeStock = "product.stock"
+)
+
+type Event struct {
+	// ID порядковый номер события, для событий из outbox это номер строки outbox,
+	// иначе выставляется шиной при публикации
+	ID        uint64          `json:"id"`
+	Tenant    string          `json:"tenant"`
+	Type      string          `json:"type"`
+	ProductID int             `json:"product_id"`
+	Company   string          `json:"company,omitempty"`
+	Product   *models.Product `json:"product,omitempty"`
+	At        time.Time       `json:"at"`
+	// TraceParent W3C traceparent запроса, который изменил товар
+	TraceParent string `json:"traceparent,omitempty"`
+}
+
+type Publisher interface {
+	Publish(events ...Event)
+}
+
+// Filter отбирает события по арендатору, товару и/или компании, пустые поля не учитываются
+type Filter struct {
+	Tenant    string
+	ProductID int
+	Company   string
+}
+
+func (f Filter) Match(event *Event) bool {
+	if f.Tenant != "" && f.Tenant != event.Tenant {
+		return false
+	}
+	if f.ProductID != 0 && f.ProductID != event.ProductID {
+		return false
+	}
+	if f.Company != "" && f.Company != event.Company {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,197 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/grip211/crud/pkg/database"
+)
+
+// тут проверки компонентов для /healthz, /readyz и /debug/health
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// CheckFunc проверка одного компонента, nil значит компонент работает
+type CheckFunc func(ctx context.Context) error
+
+type component struct {
+	name  string
+	check CheckFunc
+}
+
+type ComponentStatus struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status     string            `json:"status"`
+	State      string            `json:"state"`
+	Reason     string            `json:"reason,omitempty"`
+	Components []ComponentStatus `json:"components"`
+}
+
+type Checker struct {
+	readiness  *Readiness
+	timeout    time.Duration
+	components []component
+}
+
+// NewChecker timeout ограничивает каждую проверку
+func NewChecker(readiness *Readiness, timeout time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = time.Second * 2
+	}
+	return &Checker{
+		readiness: readiness,
+		timeout:   timeout,
+	}
+}
+
+// Add регистрирует компонент, вызывается до запуска сервера
+func (c *Checker) Add(name string, check CheckFunc) {
+	c.components = append(c.components, component{name: name, check: check})
+}
+
+// Check проверяет все компоненты параллельно
+func (c *Checker) Check(ctx context.Context) Report {
+	state, reason := c.readiness.State()
+	report := Report{
+		Status:     StatusUp,
+		State:      state,
+		Reason:     reason,
+		Components: make([]ComponentStatus, len(c.components)),
+	}
+
+	var wg sync.WaitGroup
+	for i := range c.components {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			report.Components[i] = c.run(ctx, c.components[i])
+		}(i)
+	}
+	wg.Wait()
+
+	if state != StateReady {
+		report.Status = StatusDown
+	}
+	for i := range report.Components {
+		if report.Components[i].Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, comp component) ComponentStatus {
+	checkCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	started := time.Now()
+	err := comp.check(checkCtx)
+	status := ComponentStatus{
+		Name:      comp.name,
+		Status:    StatusUp,
+		LatencyMS: float64(time.Since(started).Microseconds()) / 1000,
+	}
+	if err != nil {
+		status.Status = StatusDown
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// Live обработчик /healthz: процесс жив и обслуживает запросы
+func (c *Checker) Live() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		return ctx.JSON(fiber.Map{"status": StatusUp})
+	}
+}
+
+// Ready обработчик /readyz: сервис запущен, не останавливается и все компоненты работают
+func (c *Checker) Ready() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		// при запуске и остановке компоненты не проверяем, ответ и так известен
+		if state, reason := c.readiness.State(); state != StateReady {
+			return unavailable(ctx, fiber.Map{"status": StatusDown, "state": state, "reason": reason})
+		}
+
+		report := c.Check(ctx.UserContext())
+		if report.Status != StatusUp {
+			failed := make([]ComponentStatus, 0, len(report.Components))
+			for i := range report.Components {
+				if report.Components[i].Status != StatusUp {
+					failed = append(failed, report.Components[i])
+				}
+			}
+			return unavailable(ctx, fiber.Map{"status": StatusDown, "state": report.State, "components": failed})
+		}
+		return ctx.JSON(fiber.Map{"status": StatusUp, "state": report.State})
+	}
+}
+
+// Debug обработчик /debug/health: подробный отчет со статусом и временем каждой проверки
+func (c *Checker) Debug() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		report := c.Check(ctx.UserContext())
+		if report.Status != StatusUp {
+			return unavailable(ctx, report)
+		}
+		return ctx.JSON(report)
+	}
+}
+
+func unavailable(ctx *fiber.Ctx, body interface{}) error {
+	ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+	return ctx.Status(fiber.StatusServiceUnavailable).JSON(body)
+}
+
+// Ping проверка, что база отвечает
+func Ping(pool database.Pool) CheckFunc {
+	return pool.Ping
+}
+
+// Schema проверка, что миграции применены хотя бы до версии expected
+func Schema(pool database.Pool, expected int) CheckFunc {
+	return func(ctx context.Context) error {
+		version, err := database.SchemaVersion(ctx, pool)
+		if err != nil {
+			return err
+		}
+		if version < expected {
+			return fmt.Errorf("database schema is at version %d, expected %d", version, expected)
+		}
+		return nil
+	}
+}
+
+var errFlagDown = errors.New("not up")
+
+// Flag компонент, о состоянии которого сообщают снаружи, например слушающий сокет
+type Flag struct {
+	up atomic.Bool
+}
+
+func (f *Flag) Set(up bool) {
+	f.up.Store(up)
+}
+
+func (f *Flag) Check(context.Context) error {
+	if !f.up.Load() {
+		return errFlagDown
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecker(t *testing.T) {
+	readiness := NewReadiness()
+	database := &Flag{}
+	listener := &Flag{}
+
+	checker := NewChecker(readiness, time.Millisecond*50)
+	checker.Add("database", database.Check)
+	checker.Add("listener", listener.Check)
+
+	app := fiber.New()
+	app.Get("/healthz", checker.Live())
+	app.Get("/readyz", checker.Ready())
+	app.Get("/debug/health", checker.Debug())
+
+	tests := []struct {
+		name     string
+		state    string
+		database bool
+		listener bool
+		healthz  int
+		readyz   int
+		debug    int
+		failed   []string
+	}{
+		{
+			name: "starting", state: StateStarting, database: false, listener: true,
+			healthz: fiber.StatusOK, readyz: fiber.StatusServiceUnavailable, debug: fiber.StatusServiceUnavailable,
+			failed: []string{"database"},
+		},
+		{
+			name: "ready", state: StateReady, database: true, listener: true,
+			healthz: fiber.StatusOK, readyz: fiber.StatusOK, debug: fiber.StatusOK,
+		},
+		{
+			name: "database lost", state: StateReady, database: false, listener: true,
+			healthz: fiber.StatusOK, readyz: fiber.StatusServiceUnavailable, debug: fiber.StatusServiceUnavailable,
+			failed: []string{"database"},
+		},
+		{
+			name: "listener down", state: StateReady, database: true, listener: false,
+			healthz: fiber.StatusOK, readyz: fiber.StatusServiceUnavailable, debug: fiber.StatusServiceUnavailable,
+			failed: []string{"listener"},
+		},
+		{
+			name: "stopping", state: StateStopping, database: true, listener: true,
+			healthz: fiber.StatusOK, readyz: fiber.StatusServiceUnavailable, debug: fiber.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readiness.state = tt.state
+			database.Set(tt.database)
+			listener.Set(tt.listener)
+
+			for path, status := range map[string]int{"/healthz": tt.healthz, "/readyz": tt.readyz, "/debug/health": tt.debug} {
+				resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, path, nil))
+				require.NoError(t, err)
+				require.Equal(t, status, resp.StatusCode, path)
+			}
+
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/debug/health", nil))
+			require.NoError(t, err)
+			var report Report
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+			require.Equal(t, tt.state, report.State)
+			require.Len(t, report.Components, 2)
+
+			var failed []string
+			for _, component := range report.Components {
+				if component.Status != StatusUp {
+					require.NotEmpty(t, component.Error)
+					failed = append(failed, component.Name)
+				}
+			}
+			require.Equal(t, tt.failed, failed)
+		})
+	}
+}
+
+func TestChecker_Timeout(t *testing.T) {
+	readiness := NewReadiness()
+	readiness.Set(StateReady, "")
+
+	checker := NewChecker(readiness, time.Millisecond*20)
+	checker.Add("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	report := checker.Check(context.Background())
+	require.Equal(t, StatusDown, report.Status)
+	require.Equal(t, StatusDown, report.Components[0].Status)
+	require.GreaterOrEqual(t, report.Components[0].LatencyMS, float64(20))
+}
@@ -0,0 +1,66 @@
+package health
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// тут состояние жизненного цикла сервиса: пока база не ответила, запросы к приложению получают 503,
+// а с началом остановки /readyz сразу перестает отвечать успехом
+
+const (
+	StateStarting = "starting"
+	StateReady    = "ready"
+	StateStopping = "stopping"
+
+	retryAfter = 5
+)
+
+type Readiness struct {
+	mu     sync.RWMutex
+	state  string
+	reason string
+}
+
+func NewReadiness() *Readiness {
+	return &Readiness{
+		state: StateStarting,
+	}
+}
+
+// Set меняет состояние, reason объясняет, почему сервис не готов. Из остановки обратно не выходим,
+// даже если подключение к базе завершилось уже после сигнала
+func (r *Readiness) Set(state, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state == StateStopping {
+		return
+	}
+	r.state = state
+	r.reason = reason
+}
+
+func (r *Readiness) State() (state, reason string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.state, r.reason
+}
+
+func (r *Readiness) Ready() bool {
+	state, _ := r.State()
+	return state == StateReady
+}
+
+// Gate middleware, которое не пускает запросы дальше, пока сервис запускается.
+// При остановке запросы пропускаются, их дообслуживаем
+func (r *Readiness) Gate() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		if state, _ := r.State(); state != StateStarting {
+			return ctx.Next()
+		}
+		ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+		return ctx.Status(fiber.StatusServiceUnavailable).SendString("service is starting, try again later")
+	}
+}
@@ -0,0 +1,50 @@
+package health
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadiness_Gate(t *testing.T) {
+	readiness := NewReadiness()
+
+	app := fiber.New()
+	app.Use(readiness.Gate())
+	app.Get("/products", func(ctx *fiber.Ctx) error {
+		return ctx.SendString("products")
+	})
+
+	tests := []struct {
+		name   string
+		state  string
+		status int
+	}{
+		{name: "starting", state: StateStarting, status: fiber.StatusServiceUnavailable},
+		{name: "ready", state: StateReady, status: fiber.StatusOK},
+		// запросы во время остановки дообслуживаем
+		{name: "stopping", state: StateStopping, status: fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readiness.Set(tt.state, "")
+
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/products", nil))
+			require.NoError(t, err)
+			require.Equal(t, tt.status, resp.StatusCode)
+		})
+	}
+}
+
+func TestReadiness_StoppingIsFinal(t *testing.T) {
+	readiness := NewReadiness()
+	readiness.Set(StateStopping, "shutting down")
+	readiness.Set(StateReady, "")
+
+	state, reason := readiness.State()
+	require.Equal(t, StateStopping, state)
+	require.Equal(t, "shutting down", reason)
+}
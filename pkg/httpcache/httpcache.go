@@ -0,0 +1,120 @@
+package httpcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/grip211/crud/pkg/tenant"
+)
+
+// тут условные GET для чтений каталога: ETag по содержимому ответа, Last-Modified от обработчика,
+// ответ 304 на If-None-Match и If-Modified-Since и Cache-Control, заданный для каждого маршрута
+
+var ErrInvalidCacheControl = errors.New("invalid cache control")
+
+// lastModifiedKey ключ в Locals, обработчик кладет туда время, по которому можно проверять If-Modified-Since
+const lastModifiedKey = "httpcache.last_modified"
+
+// Options Cache-Control для маршрутов каталога, пустая строка не выставляет заголовок
+type Options struct {
+	List    string `yaml:"list"`
+	Product string `yaml:"product"`
+}
+
+func (o *Options) Validate() error {
+	var errs []error
+	if strings.ContainsAny(o.List, "\r\n") {
+		errs = append(errs, fmt.Errorf("%w: list must be a single header line", ErrInvalidCacheControl))
+	}
+	if strings.ContainsAny(o.Product, "\r\n") {
+		errs = append(errs, fmt.Errorf("%w: product must be a single header line", ErrInvalidCacheControl))
+	}
+	return errors.Join(errs...)
+}
+
+// New middleware маршрута: считает ETag успешного ответа на GET и отвечает 304, если у клиента
+// та же версия. Ответы зависят от пользователя и арендатора, поэтому Vary перечисляет их заголовки
+func New(cacheControl string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		if err := ctx.Next(); err != nil {
+			return err
+		}
+
+		method := ctx.Method()
+		if (method != fiber.MethodGet && method != fiber.MethodHead) || ctx.Response().StatusCode() != fiber.StatusOK {
+			return nil
+		}
+
+		if cacheControl != "" {
+			ctx.Set(fiber.HeaderCacheControl, cacheControl)
+		}
+		ctx.Vary(fiber.HeaderAuthorization, fiber.HeaderCookie, tenant.HeaderTenant)
+
+		etag := ETag(ctx.Response().Body())
+		ctx.Set(fiber.HeaderETag, etag)
+
+		if !notModified(ctx, etag) {
+			return nil
+		}
+		ctx.Response().ResetBody()
+		ctx.Response().Header.Del(fiber.HeaderContentLength)
+		ctx.Status(fiber.StatusNotModified)
+		return nil
+	}
+}
+
+// LastModified выставляет Last-Modified и разрешает проверку If-Modified-Since. Для списка его
+// вызывать нельзя: удаление товара не меняет максимальный updated_at
+func LastModified(ctx *fiber.Ctx, modified time.Time) {
+	ctx.Set(fiber.HeaderLastModified, FormatTime(modified))
+	ctx.Locals(lastModifiedKey, modified)
+}
+
+// FormatTime время в формате HTTP заголовков
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(http.TimeFormat)
+}
+
+// ETag сильный валидатор по содержимому ответа
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// notModified по RFC 9110: If-None-Match важнее, If-Modified-Since проверяется только без него
+func notModified(ctx *fiber.Ctx, etag string) bool {
+	if match := ctx.Get(fiber.HeaderIfNoneMatch); match != "" {
+		return matchETag(match, etag)
+	}
+
+	since := ctx.Get(fiber.HeaderIfModifiedSince)
+	modified, ok := ctx.Locals(lastModifiedKey).(time.Time)
+	if since == "" || !ok {
+		return false
+	}
+	t, err := http.ParseTime(since)
+	if err != nil {
+		return false
+	}
+	// в заголовке точность до секунды, а изменений в одну секунду может быть несколько:
+	// 304 только если товар изменен раньше начала этой секунды
+	return modified.Before(t)
+}
+
+// matchETag слабое сравнение для If-None-Match: W/ не учитывается
+func matchETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
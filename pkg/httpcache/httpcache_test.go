@@ -0,0 +1,90 @@
+package httpcache
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	modified := time.Date(2024, 3, 1, 10, 30, 15, 500, time.UTC)
+	body := `{"id":1,"model":"phone"}`
+	etag := ETag([]byte(body))
+
+	app := fiber.New()
+	app.Get("/product", New("private, no-cache"), func(ctx *fiber.Ctx) error {
+		LastModified(ctx, modified)
+		return ctx.SendString(body)
+	})
+	app.Get("/list", New("public, max-age=60"), func(ctx *fiber.Ctx) error {
+		// у списка Last-Modified только для информации
+		ctx.Set(fiber.HeaderLastModified, FormatTime(modified))
+		return ctx.SendString(body)
+	})
+	app.Get("/missing", New("private, no-cache"), func(ctx *fiber.Ctx) error {
+		return ctx.Status(fiber.StatusNotFound).SendString("not found")
+	})
+
+	tests := []struct {
+		name             string
+		path             string
+		headers          map[string]string
+		wantStatus       int
+		wantCacheControl string
+	}{
+		{name: "no validators", path: "/product", wantStatus: fiber.StatusOK, wantCacheControl: "private, no-cache"},
+		{name: "matching etag", path: "/product", headers: map[string]string{fiber.HeaderIfNoneMatch: etag}, wantStatus: fiber.StatusNotModified, wantCacheControl: "private, no-cache"},
+		{name: "weak etag in a list", path: "/product", headers: map[string]string{fiber.HeaderIfNoneMatch: `"other", W/` + etag}, wantStatus: fiber.StatusNotModified, wantCacheControl: "private, no-cache"},
+		{name: "any etag", path: "/product", headers: map[string]string{fiber.HeaderIfNoneMatch: "*"}, wantStatus: fiber.StatusNotModified, wantCacheControl: "private, no-cache"},
+		{name: "stale etag", path: "/product", headers: map[string]string{fiber.HeaderIfNoneMatch: `"other"`}, wantStatus: fiber.StatusOK, wantCacheControl: "private, no-cache"},
+		{name: "not modified since", path: "/product", headers: map[string]string{fiber.HeaderIfModifiedSince: FormatTime(modified.Add(time.Second))}, wantStatus: fiber.StatusNotModified, wantCacheControl: "private, no-cache"},
+		// в той же секунде могло быть еще одно изменение, дата его не различает
+		{name: "modified within the same second", path: "/product", headers: map[string]string{fiber.HeaderIfModifiedSince: FormatTime(modified)}, wantStatus: fiber.StatusOK, wantCacheControl: "private, no-cache"},
+		{name: "modified since", path: "/product", headers: map[string]string{fiber.HeaderIfModifiedSince: FormatTime(modified.Add(-time.Second))}, wantStatus: fiber.StatusOK, wantCacheControl: "private, no-cache"},
+		{
+			name: "etag wins over date",
+			path: "/product",
+			headers: map[string]string{
+				fiber.HeaderIfNoneMatch:     `"other"`,
+				fiber.HeaderIfModifiedSince: FormatTime(modified),
+			},
+			wantStatus:       fiber.StatusOK,
+			wantCacheControl: "private, no-cache",
+		},
+		{name: "list ignores date", path: "/list", headers: map[string]string{fiber.HeaderIfModifiedSince: FormatTime(modified)}, wantStatus: fiber.StatusOK, wantCacheControl: "public, max-age=60"},
+		{name: "list etag", path: "/list", headers: map[string]string{fiber.HeaderIfNoneMatch: etag}, wantStatus: fiber.StatusNotModified, wantCacheControl: "public, max-age=60"},
+		{name: "errors are not cached", path: "/missing", headers: map[string]string{fiber.HeaderIfNoneMatch: "*"}, wantStatus: fiber.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodGet, tt.path, nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			require.Equal(t, tt.wantCacheControl, resp.Header.Get(fiber.HeaderCacheControl))
+
+			got, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			switch tt.wantStatus {
+			case fiber.StatusOK:
+				require.Equal(t, body, string(got))
+			case fiber.StatusNotModified:
+				require.Empty(t, got)
+			default:
+				require.Empty(t, resp.Header.Get(fiber.HeaderETag))
+				return
+			}
+			require.Equal(t, etag, resp.Header.Get(fiber.HeaderETag))
+			require.Equal(t, "Fri, 01 Mar 2024 10:30:15 GMT", resp.Header.Get(fiber.HeaderLastModified))
+			require.Contains(t, resp.Header.Get(fiber.HeaderVary), "X-Tenant-ID")
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/grip211/crud/pkg/apperror"
+	"github.com/grip211/crud/pkg/auth"
+	"github.com/grip211/crud/pkg/tenant"
+)
+
+const (
+	HeaderKey      = "Idempotency-Key"
+	HeaderReplayed = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+)
+
+// New возвращает middleware для изменяющих запросов с заголовком Idempotency-Key:
+// первый ответ сохраняется вместе с отпечатком запроса и живет ttl,
+// повтор с тем же ключом и телом получает сохраненный ответ,
+// повтор с тем же ключом и другим телом получает 422
+func New(store Store, ttl time.Duration) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		key := ctx.Get(HeaderKey)
+		if key == "" || isSafeMethod(ctx.Method()) {
+			return ctx.Next()
+		}
+		if len(key) > maxKeyLength {
+			return ctx.Status(fiber.StatusBadRequest).JSON(apperror.NewErrorHandler(
+				nil, "invalid idempotency key", "Idempotency-Key must not be longer than 255 characters", "idempotency_key_invalid",
+			))
+		}
+
+		key = scopedKey(ctx.UserContext(), key)
+		fingerprint := Fingerprint(ctx.Method(), ctx.Path(), string(ctx.Request().URI().QueryString()), ctx.Body())
+
+		record, reserved, err := store.Reserve(ctx.Context(), key, fingerprint, time.Now().Add(ttl))
+		if err != nil {
+			return err
+		}
+		if !reserved {
+			return replay(ctx, record, fingerprint)
+		}
+
+		if err = ctx.Next(); err != nil {
+			// ответ сформирует ErrorHandler, поэтому ключ отпускаем, запрос можно будет повторить
+			_ = store.Release(context.Background(), key)
+			return err
+		}
+
+		status := ctx.Response().StatusCode()
+		if status >= fiber.StatusInternalServerError {
+			return store.Release(ctx.Context(), key)
+		}
+
+		return store.Complete(
+			ctx.Context(),
+			key,
+			status,
+			string(ctx.Response().Header.ContentType()),
+			ctx.Response().Body(),
+		)
+	}
+}
+
+// Fingerprint отпечаток запроса: метод, путь, строка запроса как есть и тело
+func Fingerprint(method, path, query string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(path))
+	hash.Write([]byte{0})
+	hash.Write([]byte(query))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// scopedKey ключ в пространстве арендатора и принципала, иначе можно получить чужой сохраненный ответ.
+// Принципал и ключ клиента хешируются, чтобы итог помещался в колонку: "<tenant>:<sha256>"
+func scopedKey(ctx context.Context, key string) string {
+	hash := sha256.New()
+	if principal, ok := auth.FromContext(ctx); ok {
+		hash.Write([]byte(principal.Method))
+		hash.Write([]byte{0})
+		hash.Write([]byte(principal.ID))
+	}
+	hash.Write([]byte{0})
+	hash.Write([]byte(key))
+	scoped := hex.EncodeToString(hash.Sum(nil))
+
+	if tenantID, ok := tenant.FromContext(ctx); ok {
+		return tenantID + ":" + scoped
+	}
+	return scoped
+}
+
+func replay(ctx *fiber.Ctx, record *Record, fingerprint string) error {
+	if record.Fingerprint != fingerprint {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(apperror.NewErrorHandler(
+			nil, "idempotency key reused", "Idempotency-Key was already used with a different request", "idempotency_key_reused",
+		))
+	}
+	if record.Pending() {
+		return ctx.Status(fiber.StatusConflict).JSON(apperror.NewErrorHandler(
+			nil, "request in progress", "a request with this Idempotency-Key is still being processed", "idempotency_key_in_progress",
+		))
+	}
+
+	ctx.Set(HeaderReplayed, "true")
+	if record.ContentType != "" {
+		ctx.Set(fiber.HeaderContentType, record.ContentType)
+	}
+	return ctx.Status(record.Status).Send(record.Body)
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,149 @@
+package idempotency
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grip211/crud/pkg/auth"
+)
+
+type memoryStore struct {
+	mu      sync.Mutex
+	records map[string]*Record
+}
+
+func (m *memoryStore) Reserve(_ context.Context, key, fingerprint string, expiresAt time.Time) (*Record, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if record, ok := m.records[key]; ok && record.ExpiresAt.After(time.Now()) {
+		return record, false, nil
+	}
+	m.records[key] = &Record{Key: key, Fingerprint: fingerprint, ExpiresAt: expiresAt}
+	return nil, true, nil
+}
+
+func (m *memoryStore) Complete(_ context.Context, key string, status int, contentType string, body []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record := m.records[key]
+	record.Status = status
+	record.ContentType = contentType
+	record.Body = append([]byte(nil), body...)
+	return nil
+}
+
+func (m *memoryStore) Release(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.records, key)
+	return nil
+}
+
+func TestNew(t *testing.T) {
+	store := &memoryStore{records: map[string]*Record{}}
+
+	var created int
+	app := fiber.New()
+	app.Use(func(ctx *fiber.Ctx) error {
+		auth.SetPrincipal(ctx, &auth.Principal{Method: auth.MethodAPIKey, ID: ctx.Get("X-User"), Name: ctx.Get("X-User")})
+		return ctx.Next()
+	})
+	app.Use(New(store, time.Minute))
+	app.Post("/create", func(ctx *fiber.Ctx) error {
+		created++
+		return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{"id": created})
+	})
+
+	type want struct {
+		status   int
+		body     string
+		replayed bool
+		created  int
+	}
+	tests := []struct {
+		name  string
+		user  string
+		key   string
+		query string
+		body  string
+		want  want
+	}{
+		{
+			name: "first request is executed",
+			key:  "key-1",
+			body: `{"model":"a"}`,
+			want: want{status: fiber.StatusCreated, body: `{"id":1}`, created: 1},
+		},
+		{
+			name: "retry with the same body is replayed",
+			key:  "key-1",
+			body: `{"model":"a"}`,
+			want: want{status: fiber.StatusCreated, body: `{"id":1}`, replayed: true, created: 1},
+		},
+		{
+			name: "retry with a different body is rejected",
+			key:  "key-1",
+			body: `{"model":"b"}`,
+			want: want{status: fiber.StatusUnprocessableEntity, created: 1},
+		},
+		{
+			name:  "retry with a different query is rejected",
+			key:   "key-1",
+			query: "?dry_run=true",
+			body:  `{"model":"a"}`,
+			want:  want{status: fiber.StatusUnprocessableEntity, created: 1},
+		},
+		{
+			name: "same key from another user is executed",
+			user: "bob",
+			key:  "key-1",
+			body: `{"model":"a"}`,
+			want: want{status: fiber.StatusCreated, body: `{"id":2}`, created: 2},
+		},
+		{
+			name: "request without key is executed",
+			body: `{"model":"a"}`,
+			want: want{status: fiber.StatusCreated, body: `{"id":3}`, created: 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodPost, "/create"+tt.query, strings.NewReader(tt.body))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			user := tt.user
+			if user == "" {
+				user = "alice"
+			}
+			req.Header.Set("X-User", user)
+			if tt.key != "" {
+				req.Header.Set(HeaderKey, tt.key)
+			}
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			require.Equal(t, tt.want.status, resp.StatusCode)
+			require.Equal(t, tt.want.replayed, resp.Header.Get(HeaderReplayed) == "true")
+			require.Equal(t, tt.want.created, created)
+			if tt.want.body != "" {
+				require.JSONEq(t, tt.want.body, string(body))
+			}
+		})
+	}
+}
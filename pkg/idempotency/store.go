@@ -0,0 +1,161 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	builder "github.com/doug-martin/goqu/v9"
+
+	"github.com/grip211/crud/pkg/database"
+	"github.com/grip211/crud/pkg/logging"
+)
+
+// тут храним ключи идемпотентности и сохраненные ответы в таблице IdempotencyKeys
+
+var (
+	ErrReserve  = errors.New("reserve idempotency key")
+	ErrComplete = errors.New("complete idempotency key")
+	ErrRelease  = errors.New("release idempotency key")
+)
+
+// Record сохраненный результат запроса, Status == 0 значит что запрос еще выполняется
+type Record struct {
+	Key         string    `db:"idempotency_key"`
+	Fingerprint string    `db:"fingerprint"`
+	Status      int       `db:"status"`
+	ContentType string    `db:"content_type"`
+	Body        []byte    `db:"body"`
+	CreatedAt   time.Time `db:"created_at"`
+	ExpiresAt   time.Time `db:"expires_at"`
+}
+
+func (r *Record) Pending() bool {
+	return r.Status == 0
+}
+
+type Store interface {
+	// Reserve занимает ключ и возвращает true, если ключ уже занят возвращает существующую запись и false
+	Reserve(ctx context.Context, key, fingerprint string, expiresAt time.Time) (*Record, bool, error)
+	// Complete сохраняет ответ для занятого ключа
+	Complete(ctx context.Context, key string, status int, contentType string, body []byte) error
+	// Release освобождает ключ, чтобы запрос можно было повторить
+	Release(ctx context.Context, key string) error
+}
+
+type Repo struct {
+	db database.Pool
+}
+
+func NewRepo(db database.Pool) *Repo {
+	return &Repo{
+		db: db,
+	}
+}
+
+func (r *Repo) Reserve(ctx context.Context, key, fingerprint string, expiresAt time.Time) (*Record, bool, error) {
+	// просроченный ключ можно переиспользовать
+	_, err := r.db.Builder().
+		Delete(database.Table(r.db, "IdempotencyKeys")).
+		Where(
+			builder.C("idempotency_key").Eq(key),
+			builder.C("expires_at").Lt(time.Now()),
+		).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %v", ErrReserve, err)
+	}
+
+	_, err = r.db.Builder().
+		Insert(database.Table(r.db, "IdempotencyKeys")).
+		Rows(builder.Record{
+			"idempotency_key": key,
+			"fingerprint":     fingerprint,
+			"expires_at":      expiresAt,
+		}).
+		Executor().
+		ExecContext(ctx)
+	if err == nil {
+		return nil, true, nil
+	}
+
+	if !database.IsDuplicate(err) {
+		return nil, false, fmt.Errorf("%w: %v", ErrReserve, err)
+	}
+
+	var record Record
+	found, err := r.db.Builder().
+		From(database.Table(r.db, "IdempotencyKeys")).
+		Where(builder.C("idempotency_key").Eq(key)).
+		ScanStructContext(ctx, &record)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %v", ErrReserve, err)
+	}
+	if !found {
+		// ключ освободили между INSERT и SELECT
+		return r.Reserve(ctx, key, fingerprint, expiresAt)
+	}
+
+	return &record, false, nil
+}
+
+func (r *Repo) Complete(ctx context.Context, key string, status int, contentType string, body []byte) error {
+	_, err := r.db.Builder().
+		Update(database.Table(r.db, "IdempotencyKeys")).
+		Set(builder.Record{
+			"status":       status,
+			"content_type": contentType,
+			"body":         body,
+		}).
+		Where(builder.C("idempotency_key").Eq(key)).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrComplete, err)
+	}
+	return nil
+}
+
+func (r *Repo) Release(ctx context.Context, key string) error {
+	_, err := r.db.Builder().
+		Delete(database.Table(r.db, "IdempotencyKeys")).
+		Where(builder.C("idempotency_key").Eq(key)).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRelease, err)
+	}
+	return nil
+}
+
+// DeleteExpired удаляет просроченные ключи
+func (r *Repo) DeleteExpired(ctx context.Context) (int64, error) {
+	res, err := r.db.Builder().
+		Delete(database.Table(r.db, "IdempotencyKeys")).
+		Where(builder.C("expires_at").Lt(time.Now())).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// RunCleanup периодически удаляет просроченные ключи, пока не отменен ctx
+func (r *Repo) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.DeleteExpired(ctx); err != nil {
+				logging.FromContext(ctx).Error("delete expired idempotency keys", "error", err)
+			}
+		}
+	}
+}
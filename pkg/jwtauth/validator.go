@@ -0,0 +1,178 @@
+package jwtauth
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/grip211/crud/pkg/auth"
+)
+
+// тут проверяем JWT из заголовка Authorization: Bearer, выпущенные шлюзом:
+// подпись по JWKS, issuer, audience, срок действия, а роли берем из claim и переводим в наши
+
+const (
+	MethodJWT = "jwt"
+
+	bearerScheme = "Bearer "
+)
+
+// rolePrecedence если в токене несколько ролей, берем самую сильную
+var rolePrecedence = []string{auth.RoleAdmin, auth.RoleEditor, auth.RolePricing, auth.RoleViewer}
+
+type Options struct {
+	Issuer   string
+	Audience string
+	// RoleClaim claim со строкой или списком ролей шлюза
+	RoleClaim string
+	// RoleMapping роль шлюза -> наша роль, роли шлюза, совпадающие с нашими, подходят и без записи
+	RoleMapping map[string]string
+	// TenantClaim claim с арендатором
+	TenantClaim string
+	// DefaultTenant арендатор токенов без TenantClaim. Выбирать арендатора заголовком или поддоменом
+	// такой токен не может, иначе любой действующий токен открывает любого арендатора.
+	// Если пусто, токены без claim отклоняются
+	DefaultTenant string
+	// Leeway допуск на расхождение часов
+	Leeway time.Duration
+}
+
+func (o *Options) withDefaults() Options {
+	opt := *o
+	if opt.RoleClaim == "" {
+		opt.RoleClaim = "roles"
+	}
+	if opt.TenantClaim == "" {
+		opt.TenantClaim = "tenant"
+	}
+	if opt.Leeway <= 0 {
+		opt.Leeway = time.Second * 30
+	}
+	return opt
+}
+
+type Validator struct {
+	keys   *KeySet
+	opt    Options
+	parser *jwt.Parser
+}
+
+func NewValidator(keys *KeySet, opt Options) *Validator {
+	opt = opt.withDefaults()
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(opt.Leeway),
+	}
+	if opt.Issuer != "" {
+		options = append(options, jwt.WithIssuer(opt.Issuer))
+	}
+	if opt.Audience != "" {
+		options = append(options, jwt.WithAudience(opt.Audience))
+	}
+
+	return &Validator{
+		keys:   keys,
+		opt:    opt,
+		parser: jwt.NewParser(options...),
+	}
+}
+
+// Validate проверяет токен и возвращает принципала
+func (v *Validator) Validate(raw string) (*auth.Principal, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return v.keys.Key(kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", auth.ErrInvalidCredentials, err)
+	}
+
+	role := v.role(claims[v.opt.RoleClaim])
+	if role == "" {
+		return nil, fmt.Errorf("%w: token has no known role in claim %q", auth.ErrInvalidCredentials, v.opt.RoleClaim)
+	}
+
+	tenantID, _ := claims[v.opt.TenantClaim].(string)
+	if tenantID == "" {
+		tenantID = v.opt.DefaultTenant
+	}
+	if tenantID == "" {
+		return nil, fmt.Errorf("%w: token has no tenant in claim %q", auth.ErrInvalidCredentials, v.opt.TenantClaim)
+	}
+	subject, _ := claims.GetSubject()
+	name, _ := claims["name"].(string)
+	if name == "" {
+		name = subject
+	}
+
+	return &auth.Principal{
+		Method: MethodJWT,
+		ID:     subject,
+		Name:   name,
+		Scope:  auth.RoleScope(role),
+		Role:   role,
+		Tenant: tenantID,
+	}, nil
+}
+
+// Authenticate аутентификация по заголовку Authorization: Bearer <token>
+func (v *Validator) Authenticate(ctx *fiber.Ctx) (*auth.Principal, error) {
+	header := ctx.Get(fiber.HeaderAuthorization)
+	if len(header) <= len(bearerScheme) || !strings.EqualFold(header[:len(bearerScheme)], bearerScheme) {
+		return nil, auth.ErrNoCredentials
+	}
+	return v.Validate(strings.TrimSpace(header[len(bearerScheme):]))
+}
+
+func (v *Validator) role(claim interface{}) string {
+	var values []string
+	switch value := claim.(type) {
+	case string:
+		values = strings.Fields(value)
+	case []interface{}:
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	granted := make(map[string]bool, len(values))
+	for _, value := range values {
+		if role, ok := v.opt.RoleMapping[value]; ok {
+			granted[role] = true
+		} else if auth.ValidRole(value) {
+			granted[value] = true
+		}
+	}
+
+	for _, role := range rolePrecedence {
+		if granted[role] {
+			return role
+		}
+	}
+	return ""
+}
+
+// ParseRoleMapping разбирает строку вида "catalog-admin=admin,catalog-pricing=pricing"
+func ParseRoleMapping(value string) (map[string]string, error) {
+	mapping := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		from, to, ok := strings.Cut(pair, "=")
+		if !ok || from == "" || !auth.ValidRole(to) {
+			return nil, fmt.Errorf("invalid role mapping %q, expected <claim role>=<viewer|editor|pricing|admin>", pair)
+		}
+		mapping[from] = to
+	}
+	return mapping, nil
+}
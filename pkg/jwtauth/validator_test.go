@@ -0,0 +1,201 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grip211/crud/pkg/auth"
+)
+
+func encodeInt(value *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(value.Bytes())
+}
+
+func writeJWKS(t *testing.T, path string, keys map[string]crypto.PublicKey) {
+	t.Helper()
+
+	var list []jsonWebKey
+	for kid, key := range keys {
+		switch key := key.(type) {
+		case *rsa.PublicKey:
+			list = append(list, jsonWebKey{Kid: kid, Kty: "RSA", Use: "sig", N: encodeInt(key.N), E: encodeInt(big.NewInt(int64(key.E)))})
+		case *ecdsa.PublicKey:
+			list = append(list, jsonWebKey{Kid: kid, Kty: "EC", Crv: "P-256", X: encodeInt(key.X), Y: encodeInt(key.Y)})
+		}
+	}
+	// симметричный ключ должен игнорироваться
+	list = append(list, jsonWebKey{Kid: "hmac", Kty: "oct"})
+
+	data, err := json.Marshal(map[string]interface{}{"keys": list})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key crypto.PrivateKey, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	raw, err := token.SignedString(key)
+	require.NoError(t, err)
+	return raw
+}
+
+func TestValidator_Validate(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, map[string]crypto.PublicKey{"rsa": &rsaKey.PublicKey, "ec": &ecKey.PublicKey})
+
+	keys := NewKeySet(path)
+	require.NoError(t, keys.Load(context.Background()))
+
+	opt := Options{
+		Issuer:      "https://gateway.example",
+		Audience:    "crud",
+		RoleMapping: map[string]string{"catalog-pricing": auth.RolePricing},
+	}
+	validator := NewValidator(keys, opt)
+	opt.DefaultTenant = "default"
+	withDefault := NewValidator(keys, opt)
+
+	claims := func(overrides jwt.MapClaims) jwt.MapClaims {
+		claims := jwt.MapClaims{
+			"iss":    "https://gateway.example",
+			"aud":    "crud",
+			"sub":    "user-1",
+			"tenant": "shop-a",
+			"exp":    time.Now().Add(time.Hour).Unix(),
+			"roles":  []string{"viewer"},
+		}
+		for key, value := range overrides {
+			claims[key] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name      string
+		validator *Validator
+		token     string
+		role      string
+		tenant    string
+		err       bool
+	}{
+		{name: "rsa", token: sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(nil)), role: auth.RoleViewer},
+		{name: "no tenant claim", token: sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(jwt.MapClaims{"tenant": nil})), err: true},
+		{
+			name:      "no tenant claim is bound to the default tenant",
+			validator: withDefault,
+			token:     sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(jwt.MapClaims{"tenant": nil})),
+			role:      auth.RoleViewer,
+			tenant:    "default",
+		},
+		{
+			name:      "tenant claim wins over the default tenant",
+			validator: withDefault,
+			token:     sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(nil)),
+			role:      auth.RoleViewer,
+		},
+		{name: "ec", token: sign(t, jwt.SigningMethodES256, "ec", ecKey, claims(nil)), role: auth.RoleViewer},
+		{
+			name:  "mapped role",
+			token: sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(jwt.MapClaims{"roles": []string{"catalog-pricing"}})),
+			role:  auth.RolePricing,
+		},
+		{
+			name:  "strongest role",
+			token: sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(jwt.MapClaims{"roles": "viewer admin"})),
+			role:  auth.RoleAdmin,
+		},
+		{name: "unknown role", token: sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(jwt.MapClaims{"roles": []string{"guest"}})), err: true},
+		{name: "expired", token: sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()})), err: true},
+		{name: "no expiry", token: sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(jwt.MapClaims{"exp": nil})), err: true},
+		{name: "wrong issuer", token: sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(jwt.MapClaims{"iss": "https://evil.example"})), err: true},
+		{name: "wrong audience", token: sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(jwt.MapClaims{"aud": "other"})), err: true},
+		{name: "no issuer", token: sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(jwt.MapClaims{"iss": nil})), err: true},
+		{name: "no audience", token: sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(jwt.MapClaims{"aud": nil})), err: true},
+		{name: "unknown kid", token: sign(t, jwt.SigningMethodRS256, "other", otherKey, claims(nil)), err: true},
+		{name: "wrong signature", token: sign(t, jwt.SigningMethodRS256, "rsa", otherKey, claims(nil)), err: true},
+		{name: "hmac", token: sign(t, jwt.SigningMethodHS256, "hmac", []byte("secret"), claims(nil)), err: true},
+		{name: "none", token: sign(t, jwt.SigningMethodNone, "rsa", jwt.UnsafeAllowNoneSignatureType, claims(nil)), err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator
+			if tt.validator != nil {
+				v = tt.validator
+			}
+			principal, err := v.Validate(tt.token)
+			if tt.err {
+				require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.role, principal.Role)
+			require.Equal(t, "user-1", principal.ID)
+			require.Equal(t, MethodJWT, principal.Method)
+			wantTenant := tt.tenant
+			if wantTenant == "" {
+				wantTenant = "shop-a"
+			}
+			require.Equal(t, wantTenant, principal.Tenant)
+		})
+	}
+}
+
+func TestKeySet_Load(t *testing.T) {
+	first, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	second, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, map[string]crypto.PublicKey{"first": &first.PublicKey})
+
+	keys := NewKeySet(path)
+	require.NoError(t, keys.Load(context.Background()))
+	_, err = keys.Key("second")
+	require.ErrorIs(t, err, ErrUnknownKey)
+
+	// ротация: после перечитывания доступен новый ключ
+	writeJWKS(t, path, map[string]crypto.PublicKey{"first": &first.PublicKey, "second": &second.PublicKey})
+	require.NoError(t, keys.Load(context.Background()))
+	_, err = keys.Key("second")
+	require.NoError(t, err)
+
+	// битый файл не затирает загруженные ключи
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+	require.Error(t, keys.Load(context.Background()))
+	_, err = keys.Key("first")
+	require.NoError(t, err)
+}
+
+func TestParseRoleMapping(t *testing.T) {
+	mapping, err := ParseRoleMapping("catalog-admin=admin, catalog-pricing=pricing")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"catalog-admin": auth.RoleAdmin, "catalog-pricing": auth.RolePricing}, mapping)
+
+	_, err = ParseRoleMapping("catalog-admin=root")
+	require.Error(t, err)
+}
@@ -0,0 +1,102 @@
+package limits
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// тут ограничения на клиента: частота запросов по корзине токенов и размер тела запроса.
+// Клиент это API ключ или пользователь, без аутентификации IP адрес
+
+const (
+	ClassRead  = "read"
+	ClassWrite = "write"
+	ClassBatch = "batch"
+
+	// DefaultBodyLimit размер тела по умолчанию у fiber
+	DefaultBodyLimit = 4 * 1024 * 1024
+)
+
+var ErrInvalidRate = errors.New("rate limit must look like <requests per second>[:<burst>], e.g. 10:20")
+
+// Rate корзина токенов: пополняется на PerSecond токенов в секунду и вмещает Burst, PerSecond 0 отключает лимит
+type Rate struct {
+	PerSecond float64 `yaml:"per_second"`
+	Burst     int     `yaml:"burst"`
+}
+
+func (r Rate) Enabled() bool {
+	return r.PerSecond > 0
+}
+
+// capacity вместимость корзины, без Burst хватает на одну секунду запросов
+func (r Rate) capacity() float64 {
+	if r.Burst > 0 {
+		return float64(r.Burst)
+	}
+	if r.PerSecond < 1 {
+		return 1
+	}
+	return r.PerSecond
+}
+
+// ParseRate разбирает запись вида 10:20, где 10 запросов в секунду и 20 в пике
+func ParseRate(value string) (Rate, error) {
+	var rate Rate
+	perSecond, burst, hasBurst := strings.Cut(strings.TrimSpace(value), ":")
+
+	var err error
+	if rate.PerSecond, err = strconv.ParseFloat(perSecond, 64); err != nil || rate.PerSecond < 0 {
+		return Rate{}, fmt.Errorf("%w, got %q", ErrInvalidRate, value)
+	}
+	if hasBurst {
+		if rate.Burst, err = strconv.Atoi(burst); err != nil || rate.Burst < 0 {
+			return Rate{}, fmt.Errorf("%w, got %q", ErrInvalidRate, value)
+		}
+	}
+	return rate, nil
+}
+
+type Options struct {
+	// Read чтение, Write изменения, Batch пакетные операции, лимит пакетов проверяется в дополнение к Write
+	Read  Rate `yaml:"read"`
+	Write Rate `yaml:"write"`
+	Batch Rate `yaml:"batch"`
+	// Body максимальный размер тела создания и изменения, BatchBody пакетных операций
+	Body      int `yaml:"body"`
+	BatchBody int `yaml:"batch_body"`
+}
+
+func (o *Options) Validate() error {
+	var errs []error
+	for _, class := range []string{ClassRead, ClassWrite, ClassBatch} {
+		if rate := o.Rate(class); rate.PerSecond < 0 || rate.Burst < 0 {
+			errs = append(errs, fmt.Errorf("limits.%s must not be negative", class))
+		}
+	}
+	if o.Body < 0 || o.BatchBody < 0 {
+		errs = append(errs, errors.New("limits.body and limits.batch_body must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Rate лимит класса маршрутов
+func (o *Options) Rate(class string) Rate {
+	switch class {
+	case ClassRead:
+		return o.Read
+	case ClassWrite:
+		return o.Write
+	case ClassBatch:
+		return o.Batch
+	default:
+		return Rate{}
+	}
+}
+
+// MaxBody общий лимит тела для fiber, он должен пропустить самое большое из разрешенных тел
+func (o *Options) MaxBody() int {
+	return max(DefaultBodyLimit, o.Body, o.BatchBody)
+}
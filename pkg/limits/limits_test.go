@@ -0,0 +1,82 @@
+package limits
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Rate
+		wantErr bool
+	}{
+		{value: "10:20", want: Rate{PerSecond: 10, Burst: 20}},
+		{value: "0.5", want: Rate{PerSecond: 0.5}},
+		{value: "0", want: Rate{}},
+		{value: "fast", wantErr: true},
+		{value: "10:many", wantErr: true},
+		{value: "-1:5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			rate, err := ParseRate(tt.value)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidRate)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, rate)
+		})
+	}
+}
+
+func TestMemory_Take(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemory()
+	rate := Rate{PerSecond: 2, Burst: 3}
+	now := time.Now()
+
+	// полная корзина пропускает Burst запросов подряд
+	for i := 2; i >= 0; i-- {
+		result, err := memory.Take(ctx, "client", rate, now)
+		require.NoError(t, err)
+		require.True(t, result.Allowed)
+		require.Equal(t, i, result.Remaining)
+	}
+
+	result, err := memory.Take(ctx, "client", rate, now)
+	require.NoError(t, err)
+	require.False(t, result.Allowed)
+	require.Equal(t, time.Millisecond*500, result.RetryAfter)
+
+	// у другого клиента своя корзина
+	result, err = memory.Take(ctx, "other", rate, now)
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+
+	// через полсекунды накопился один токен
+	now = now.Add(time.Millisecond * 500)
+	result, err = memory.Take(ctx, "client", rate, now)
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+	require.Equal(t, 0, result.Remaining)
+
+	// заполнившиеся корзины выбрасываются
+	require.Equal(t, 2, memory.Len())
+	_, err = memory.Take(ctx, "client", rate, now.Add(sweepInterval*2))
+	require.NoError(t, err)
+	require.Equal(t, 1, memory.Len())
+}
+
+func TestOptions_MaxBody(t *testing.T) {
+	opt := Options{Body: 1024}
+	require.Equal(t, DefaultBodyLimit, opt.MaxBody())
+
+	opt.BatchBody = DefaultBodyLimit * 2
+	require.Equal(t, DefaultBodyLimit*2, opt.MaxBody())
+}
@@ -0,0 +1,104 @@
+package limits
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/grip211/crud/pkg/apperror"
+	"github.com/grip211/crud/pkg/auth"
+	"github.com/grip211/crud/pkg/logging"
+)
+
+const (
+	HeaderLimit     = "X-RateLimit-Limit"
+	HeaderRemaining = "X-RateLimit-Remaining"
+)
+
+var (
+	ErrRateLimited  = errors.New("rate limit exceeded")
+	ErrBodyTooLarge = errors.New("request body too large")
+)
+
+type Limiter struct {
+	store Store
+	opt   Options
+	now   func() time.Time
+}
+
+func NewLimiter(store Store, opt Options) *Limiter {
+	return &Limiter{
+		store: store,
+		opt:   opt,
+		now:   time.Now,
+	}
+}
+
+// Middleware лимит по методу: GET и HEAD считаются чтением, остальное изменениями.
+// Ставится после аутентификации, чтобы считать по ключу, а не по адресу
+func (l *Limiter) Middleware() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		class := ClassWrite
+		if ctx.Method() == fiber.MethodGet || ctx.Method() == fiber.MethodHead {
+			class = ClassRead
+		}
+		return l.take(ctx, class)
+	}
+}
+
+// Class лимит отдельного класса маршрутов, например пакетных операций
+func (l *Limiter) Class(class string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		return l.take(ctx, class)
+	}
+}
+
+func (l *Limiter) take(ctx *fiber.Ctx, class string) error {
+	rate := l.opt.Rate(class)
+	if !rate.Enabled() {
+		return ctx.Next()
+	}
+
+	result, err := l.store.Take(ctx.UserContext(), class+"|"+Client(ctx), rate, l.now())
+	if err != nil {
+		// недоступное хранилище лимитов не должно останавливать каталог
+		logging.FromContext(ctx.UserContext()).Warn("rate limit store failed, request allowed", "class", class, "error", err)
+		return ctx.Next()
+	}
+
+	ctx.Set(HeaderLimit, strconv.Itoa(int(rate.capacity())))
+	ctx.Set(HeaderRemaining, strconv.Itoa(result.Remaining))
+	if result.Allowed {
+		return ctx.Next()
+	}
+
+	retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+	ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+	return ctx.Status(fiber.StatusTooManyRequests).JSON(apperror.NewErrorHandler(
+		ErrRateLimited, "too many requests", fmt.Sprintf("%s limit exceeded, retry after %d seconds", class, retryAfter), "rate_limited",
+	))
+}
+
+// Client ключ клиента: принципал, если он уже известен, иначе IP адрес
+func Client(ctx *fiber.Ctx) string {
+	if principal, ok := auth.PrincipalFrom(ctx); ok {
+		return principal.Method + ":" + principal.ID
+	}
+	return "ip:" + ctx.IP()
+}
+
+// Body ограничивает размер тела запроса, limit 0 оставляет общий лимит fiber
+func Body(limit int) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		if limit > 0 && len(ctx.Body()) > limit {
+			return ctx.Status(fiber.StatusRequestEntityTooLarge).JSON(apperror.NewErrorHandler(
+				ErrBodyTooLarge, "request body too large", fmt.Sprintf("request body must not exceed %d bytes", limit), "body_too_large",
+			))
+		}
+		return ctx.Next()
+	}
+}
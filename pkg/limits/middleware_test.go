@@ -0,0 +1,142 @@
+package limits
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grip211/crud/pkg/auth"
+)
+
+// step запрос клиента key и ожидаемый статус
+type step struct {
+	method string
+	key    string
+	status int
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, Rate, time.Time) (Result, error) {
+	return Result{}, errors.New("store is down")
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Now()
+
+	newApp := func(store Store) *fiber.App {
+		limiter := NewLimiter(store, Options{
+			Read:  Rate{PerSecond: 1, Burst: 2},
+			Write: Rate{PerSecond: 1, Burst: 1},
+		})
+		limiter.now = func() time.Time { return now }
+
+		app := fiber.New()
+		app.Use(func(ctx *fiber.Ctx) error {
+			if key := ctx.Get("X-Key"); key != "" {
+				auth.SetPrincipal(ctx, &auth.Principal{Method: auth.MethodAPIKey, ID: key, Name: key})
+			}
+			return ctx.Next()
+		})
+		app.Use(limiter.Middleware())
+		app.Get("/products", func(ctx *fiber.Ctx) error {
+			return ctx.SendStatus(fiber.StatusNoContent)
+		})
+		app.Post("/create", func(ctx *fiber.Ctx) error {
+			return ctx.SendStatus(fiber.StatusCreated)
+		})
+		return app
+	}
+
+	tests := []struct {
+		name     string
+		store    Store
+		requests []step
+	}{
+		{
+			name:  "reads and writes have separate buckets",
+			store: NewMemory(),
+			requests: []step{
+				{method: fiber.MethodGet, key: "a", status: fiber.StatusNoContent},
+				{method: fiber.MethodGet, key: "a", status: fiber.StatusNoContent},
+				{method: fiber.MethodGet, key: "a", status: fiber.StatusTooManyRequests},
+				{method: fiber.MethodPost, key: "a", status: fiber.StatusCreated},
+				{method: fiber.MethodPost, key: "a", status: fiber.StatusTooManyRequests},
+			},
+		},
+		{
+			name:  "clients have separate buckets",
+			store: NewMemory(),
+			requests: []step{
+				{method: fiber.MethodPost, key: "a", status: fiber.StatusCreated},
+				{method: fiber.MethodPost, key: "b", status: fiber.StatusCreated},
+				{method: fiber.MethodPost, status: fiber.StatusCreated},
+				{method: fiber.MethodPost, status: fiber.StatusTooManyRequests},
+			},
+		},
+		{
+			name:  "store failure lets requests through",
+			store: failingStore{},
+			requests: []step{
+				{method: fiber.MethodPost, key: "a", status: fiber.StatusCreated},
+				{method: fiber.MethodPost, key: "a", status: fiber.StatusCreated},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newApp(tt.store)
+			for _, request := range tt.requests {
+				path := "/products"
+				if request.method == fiber.MethodPost {
+					path = "/create"
+				}
+				req := httptest.NewRequest(request.method, path, nil)
+				if request.key != "" {
+					req.Header.Set("X-Key", request.key)
+				}
+				resp, err := app.Test(req)
+				require.NoError(t, err)
+				require.Equal(t, request.status, resp.StatusCode)
+
+				if request.status == fiber.StatusTooManyRequests {
+					require.Equal(t, "1", resp.Header.Get(fiber.HeaderRetryAfter))
+					require.Equal(t, "0", resp.Header.Get(HeaderRemaining))
+				}
+			}
+		})
+	}
+}
+
+func TestBody(t *testing.T) {
+	app := fiber.New()
+	app.Post("/create", Body(10), func(ctx *fiber.Ctx) error {
+		return ctx.SendStatus(fiber.StatusCreated)
+	})
+	app.Post("/unlimited", Body(0), func(ctx *fiber.Ctx) error {
+		return ctx.SendStatus(fiber.StatusCreated)
+	})
+
+	tests := []struct {
+		path   string
+		body   string
+		status int
+	}{
+		{path: "/create", body: "0123456789", status: fiber.StatusCreated},
+		{path: "/create", body: "0123456789a", status: fiber.StatusRequestEntityTooLarge},
+		{path: "/unlimited", body: strings.Repeat("a", 100), status: fiber.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodPost, tt.path, strings.NewReader(tt.body)))
+		require.NoError(t, err)
+		require.Equal(t, tt.status, resp.StatusCode, tt.path)
+	}
+}
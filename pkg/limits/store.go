@@ -0,0 +1,98 @@
+package limits
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Store хранит корзины токенов. Memory годится для одного экземпляра сервиса, для нескольких
+// нужен общий бэкенд (например Redis), он реализует этот же интерфейс
+type Store interface {
+	// Take забирает токен из корзины key, если он есть
+	Take(ctx context.Context, key string, rate Rate, now time.Time) (Result, error)
+}
+
+type Result struct {
+	Allowed bool
+	// Remaining сколько целых токенов осталось
+	Remaining int
+	// RetryAfter когда появится следующий токен, если запрос отклонен
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	// full когда корзина заполнится, после этого ее можно выбросить
+	full time.Time
+}
+
+// Memory корзины в памяти процесса, полные корзины периодически выбрасываются
+type Memory struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		buckets: map[string]*bucket{},
+	}
+}
+
+const sweepInterval = time.Minute
+
+func (m *Memory) Take(_ context.Context, key string, rate Rate, now time.Time) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep(now)
+
+	capacity := rate.capacity()
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		m.buckets[key] = b
+	}
+
+	// пополняем за прошедшее время
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*rate.PerSecond)
+		b.updated = now
+	}
+
+	if b.tokens < 1 {
+		return Result{RetryAfter: refill(1-b.tokens, rate)}, nil
+	}
+	b.tokens--
+	b.full = now.Add(refill(capacity-b.tokens, rate))
+	return Result{Allowed: true, Remaining: int(b.tokens)}, nil
+}
+
+// refill за сколько накопится tokens токенов
+func refill(tokens float64, rate Rate) time.Duration {
+	return time.Duration(math.Ceil(tokens / rate.PerSecond * float64(time.Second)))
+}
+
+// sweep выбрасывает корзины, которые успели заполниться, новая корзина и так создается полной
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+
+	for key, b := range m.buckets {
+		if now.After(b.full) {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+// Len сколько корзин сейчас хранится
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.buckets)
+}
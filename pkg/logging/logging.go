@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// тут структурный логгер на log/slog. Логгер передается через ctx: фоновые процессы берут его
+// из контекста приложения, обработчики запросов из UserContext уже с полями запроса
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+var (
+	ErrUnknownLevel  = errors.New("log level must be one of debug, info, warn, error")
+	ErrUnknownFormat = errors.New("log format must be one of text, json")
+)
+
+type Options struct {
+	// Level debug, info, warn или error
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+func (o *Options) Validate() error {
+	var errs []error
+	if _, err := ParseLevel(o.Level); err != nil {
+		errs = append(errs, err)
+	}
+	switch o.Format {
+	case "", FormatText, FormatJSON:
+	default:
+		errs = append(errs, fmt.Errorf("%w, got %q", ErrUnknownFormat, o.Format))
+	}
+	return errors.Join(errs...)
+}
+
+// ParseLevel уровень по имени, пустое имя это info
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if name == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(name))); err != nil {
+		return level, fmt.Errorf("%w, got %q", ErrUnknownLevel, name)
+	}
+	return level, nil
+}
+
+// New логгер, пишущий в w в формате и с уровнем из opt
+func New(w io.Writer, opt Options) (*slog.Logger, error) {
+	if err := opt.Validate(); err != nil {
+		return nil, err
+	}
+	level, _ := ParseLevel(opt.Level)
+
+	handlerOptions := &slog.HandlerOptions{Level: level}
+	if opt.Format == FormatJSON {
+		return slog.New(slog.NewJSONHandler(w, handlerOptions)), nil
+	}
+	return slog.New(slog.NewTextHandler(w, handlerOptions)), nil
+}
+
+type contextKey struct{}
+
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext логгер из ctx, без него slog.Default()
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// With ctx с логгером, к которому добавлены поля args
+func With(ctx context.Context, args ...any) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(args...))
+}
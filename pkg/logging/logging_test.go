@@ -0,0 +1,158 @@
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grip211/crud/pkg/apperror"
+	"github.com/grip211/crud/pkg/requestid"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		opt     Options
+		wantErr error
+		check   func(t *testing.T, out string)
+	}{
+		{
+			name: "json above level",
+			opt:  Options{Level: "warn", Format: FormatJSON},
+			check: func(t *testing.T, out string) {
+				lines := strings.Split(strings.TrimSpace(out), "\n")
+				require.Len(t, lines, 1)
+
+				var record map[string]any
+				require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+				require.Equal(t, "WARN", record["level"])
+				require.Equal(t, "warn message", record["msg"])
+				require.Equal(t, "value", record["key"])
+			},
+		},
+		{
+			name: "text with debug",
+			opt:  Options{Level: "DEBUG"},
+			check: func(t *testing.T, out string) {
+				require.Contains(t, out, "level=DEBUG msg=\"debug message\" key=value")
+				require.Contains(t, out, "level=WARN msg=\"warn message\" key=value")
+			},
+		},
+		{
+			name:    "unknown level",
+			opt:     Options{Level: "verbose"},
+			wantErr: ErrUnknownLevel,
+		},
+		{
+			name:    "unknown format",
+			opt:     Options{Format: "xml"},
+			wantErr: ErrUnknownFormat,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			logger, err := New(&out, tt.opt)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			logger.Debug("debug message", "key", "value")
+			logger.Warn("warn message", "key", "value")
+			tt.check(t, out.String())
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, Options{Format: FormatJSON})
+	require.NoError(t, err)
+
+	app := fiber.New()
+	app.Use(requestid.New())
+	app.Use(Middleware(logger))
+	app.Use(apperror.Resolve())
+	app.Get("/products/:id", func(ctx *fiber.Ctx) error {
+		ctx.SetUserContext(With(ctx.UserContext(), "user", "alice"))
+		FromContext(ctx.UserContext()).Info("read product")
+		return ctx.SendString("phone")
+	})
+	app.Get("/fail", func(ctx *fiber.Ctx) error {
+		return fiber.ErrBadGateway
+	})
+	app.Get("/events", func(ctx *fiber.Ctx) error {
+		ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			_, _ = w.WriteString("data: 1\n\n")
+		})
+		return nil
+	})
+	app.Get("/export", func(ctx *fiber.Ctx) error {
+		ctx.Response().SetBodyStream(strings.NewReader("phone"), len("phone"))
+		return nil
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		wantRoute  string
+		wantStatus int
+		wantBody   string
+		wantBytes  any
+		wantLines  int
+	}{
+		{name: "handler log and access log", path: "/products/7", wantRoute: "/products/:id", wantStatus: fiber.StatusOK, wantBody: "phone", wantBytes: float64(5), wantLines: 2},
+		{name: "handler error", path: "/fail", wantRoute: "/fail", wantStatus: fiber.StatusBadGateway, wantBody: "Bad Gateway", wantBytes: float64(len("Bad Gateway")), wantLines: 1},
+		// поток не вычитывается ради размера, клиент получает его целиком
+		{name: "stream of unknown size", path: "/events", wantRoute: "/events", wantStatus: fiber.StatusOK, wantBody: "data: 1\n\n", wantLines: 1},
+		{name: "stream with content length", path: "/export", wantRoute: "/export", wantStatus: fiber.StatusOK, wantBody: "phone", wantBytes: float64(5), wantLines: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out.Reset()
+			req := httptest.NewRequest(fiber.MethodGet, tt.path, nil)
+			req.Header.Set(requestid.Header, "req-1")
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantBody, string(body))
+
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			require.Len(t, lines, tt.wantLines)
+			records := make([]map[string]any, len(lines))
+			for i := range lines {
+				require.NoError(t, json.Unmarshal([]byte(lines[i]), &records[i]))
+				require.Equal(t, fiber.MethodGet, records[i]["method"])
+				require.Equal(t, tt.path, records[i]["path"])
+				require.Equal(t, "req-1", records[i]["request_id"])
+			}
+			if tt.wantLines > 1 {
+				require.Equal(t, "read product", records[0]["msg"])
+			}
+
+			access := records[len(records)-1]
+			require.Equal(t, "request", access["msg"])
+			require.Equal(t, tt.wantRoute, access["route"])
+			require.Equal(t, float64(tt.wantStatus), access["status"])
+			require.Equal(t, tt.wantBytes, access["bytes"])
+			require.NotEmpty(t, access["peer"])
+			if tt.wantLines > 1 {
+				// поля, добавленные обработчиком, попадают и в access лог
+				require.Equal(t, "alice", access["user"])
+			}
+		})
+	}
+}
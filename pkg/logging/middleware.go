@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/grip211/crud/pkg/requestid"
+)
+
+// Middleware кладет в UserContext логгер с полями запроса и после ответа пишет строку access лога.
+// Пользователя и арендатора добавляют middleware аутентификации и выбора арендатора, когда они
+// становятся известны, поэтому в access лог они тоже попадают
+func Middleware(base *slog.Logger) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		started := time.Now()
+
+		args := []any{"method", ctx.Method(), "path", ctx.Path()}
+		if id := requestid.FromContext(ctx.UserContext()); id != "" {
+			args = append(args, "request_id", id)
+		}
+		ctx.SetUserContext(WithLogger(ctx.UserContext(), base.With(args...)))
+
+		// статус ответа на ошибку уже выставлен apperror.Resolve
+		err := ctx.Next()
+
+		fields := []any{
+			"route", ctx.Route().Path,
+			"status", ctx.Response().StatusCode(),
+		}
+		if size, ok := bodySize(ctx); ok {
+			fields = append(fields, "bytes", size)
+		}
+		fields = append(fields, "duration", time.Since(started), "peer", Peer(ctx))
+		FromContext(ctx.UserContext()).Info("request", fields...)
+		return err
+	}
+}
+
+// bodySize размер тела ответа. Поток (SSE, файл) читать нельзя, Body() вычитает его до отправки,
+// поэтому для потока берем Content-Length, а если размер заранее неизвестен, не пишем его
+func bodySize(ctx *fiber.Ctx) (int, bool) {
+	response := ctx.Response()
+	if !response.IsBodyStream() {
+		return len(response.Body()), true
+	}
+	if size := response.Header.ContentLength(); size >= 0 {
+		return size, true
+	}
+	return 0, false
+}
+
+// Peer адрес клиента, для unix сокета адреса клиента нет, пишем путь сокета
+func Peer(ctx *fiber.Ctx) string {
+	remote := ctx.Context().RemoteAddr()
+	if remote.Network() == "unix" {
+		return "unix:" + ctx.Context().LocalAddr().String()
+	}
+	return remote.String()
+}
@@ -0,0 +1,169 @@
+package metrics
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// тут метрики Prometheus: HTTP запросы, операции репозитория и пул соединений с базой
+
+const namespace = "crud"
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+
+	repoDuration *prometheus.HistogramVec
+	repoErrors   *prometheus.CounterVec
+
+	slowQueries *prometheus.CounterVec
+
+	cacheRequests *prometheus.CounterVec
+
+	replicaUp *prometheus.GaugeVec
+
+	outboxParked *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Number of HTTP requests by method, route and status.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "HTTP request latency by method, route and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		repoDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "repository",
+			Name:      "operation_duration_seconds",
+			Help:      "Latency of repository operations by method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"}),
+		repoErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "repository",
+			Name:      "operation_errors_total",
+			Help:      "Number of failed repository operations by method.",
+		}, []string{"method"}),
+		slowQueries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "db",
+			Name:      "slow_queries_total",
+			Help:      "Number of SQL queries above the slow query threshold by statement verb.",
+		}, []string{"verb"}),
+		cacheRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      "requests_total",
+			Help:      "Number of catalogue cache lookups by kind and result (hit or miss).",
+		}, []string{"kind", "result"}),
+		replicaUp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "db",
+			Name:      "replica_up",
+			Help:      "Whether a read replica passed the last health check (1) or not (0).",
+		}, []string{"replica"}),
+		outboxParked: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "outbox",
+			Name:      "parked_total",
+			Help:      "Number of outbox messages parked after reaching the attempt limit by event type.",
+		}, []string{"event_type"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.repoDuration,
+		m.repoErrors,
+		m.slowQueries,
+		m.cacheRequests,
+		m.replicaUp,
+		m.outboxParked,
+	)
+	return m
+}
+
+// RegisterDB добавляет статистику пула sql.DBStats: открытые, занятые и свободные соединения, ожидания
+func (m *Metrics) RegisterDB(db *sql.DB, name string) error {
+	return m.registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler обработчик /metrics
+func (m *Metrics) Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+}
+
+// Middleware считает запросы. Маршрут берется шаблоном (/api/v1/edit/:id), чтобы не плодить ряды на каждый id.
+// Статус ответа на ошибку уже выставлен apperror.Resolve, который стоит после
+func (m *Metrics) Middleware() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		started := time.Now()
+		err := ctx.Next()
+
+		labels := prometheus.Labels{
+			"method": ctx.Method(),
+			"route":  ctx.Route().Path,
+			"status": strconv.Itoa(ctx.Response().StatusCode()),
+		}
+		m.httpRequests.With(labels).Inc()
+		m.httpDuration.With(labels).Observe(time.Since(started).Seconds())
+		return err
+	}
+}
+
+// ObserveOperation реализует repository.Observer
+func (m *Metrics) ObserveOperation(operation string, duration time.Duration, err error) {
+	m.repoDuration.WithLabelValues(operation).Observe(duration.Seconds())
+	if err != nil {
+		m.repoErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+// ObserveSlowQuery реализует database.QueryObserver
+func (m *Metrics) ObserveSlowQuery(verb string, _ time.Duration) {
+	m.slowQueries.WithLabelValues(verb).Inc()
+}
+
+// ObserveCache реализует cache.Observer
+func (m *Metrics) ObserveCache(kind string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.cacheRequests.WithLabelValues(kind, result).Inc()
+}
+
+// ObserveReplica реализует database.ReplicaObserver
+func (m *Metrics) ObserveReplica(name string, healthy bool) {
+	up := 0.0
+	if healthy {
+		up = 1
+	}
+	m.replicaUp.WithLabelValues(name).Set(up)
+}
+
+// ObserveParked реализует outbox.Observer
+func (m *Metrics) ObserveParked(eventType string) {
+	m.outboxParked.WithLabelValues(eventType).Inc()
+}
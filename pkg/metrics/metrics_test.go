@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grip211/crud/pkg/apperror"
+)
+
+func TestMetrics_Middleware(t *testing.T) {
+	m := New()
+
+	app := fiber.New()
+	app.Get("/metrics", m.Handler())
+	app.Use(m.Middleware())
+	app.Use(apperror.Resolve())
+	app.Get("/edit/:id", func(ctx *fiber.Ctx) error {
+		if ctx.Params("id") == "0" {
+			return fiber.ErrBadRequest
+		}
+		return ctx.SendString("ok")
+	})
+
+	for _, path := range []string{"/edit/1", "/edit/2", "/edit/0"} {
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, path, nil))
+		require.NoError(t, err)
+		require.NotZero(t, resp.StatusCode)
+	}
+
+	tests := []struct {
+		name   string
+		status string
+		count  float64
+	}{
+		{name: "ok requests by route template", status: "200", count: 2},
+		{name: "error status from the error handler", status: "400", count: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := m.httpRequests.WithLabelValues(fiber.MethodGet, "/edit/:id", tt.status)
+			require.Equal(t, tt.count, testutil.ToFloat64(counter))
+		})
+	}
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/metrics", nil))
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.True(t, strings.Contains(string(body), `crud_http_request_duration_seconds_count{method="GET",route="/edit/:id",status="200"} 2`))
+	// сам /metrics не считается
+	require.False(t, strings.Contains(string(body), `route="/metrics"`))
+}
+
+func TestMetrics_ObserveOperation(t *testing.T) {
+	m := New()
+
+	m.ObserveOperation("Read", time.Millisecond, nil)
+	m.ObserveOperation("Read", time.Millisecond, errors.New("connection lost"))
+	m.ObserveOperation("Update", time.Millisecond, nil)
+
+	require.Equal(t, 2, testutil.CollectAndCount(m.repoDuration))
+	require.Equal(t, float64(1), testutil.ToFloat64(m.repoErrors.WithLabelValues("Read")))
+	require.Equal(t, float64(0), testutil.ToFloat64(m.repoErrors.WithLabelValues("Update")))
+}
+
+func TestMetrics_ObserveSlowQuery(t *testing.T) {
+	m := New()
+
+	m.ObserveSlowQuery("select", time.Second)
+	m.ObserveSlowQuery("select", time.Second)
+	m.ObserveSlowQuery("update", time.Second)
+
+	require.Equal(t, float64(2), testutil.ToFloat64(m.slowQueries.WithLabelValues("select")))
+	require.Equal(t, float64(1), testutil.ToFloat64(m.slowQueries.WithLabelValues("update")))
+}
+
+func TestMetrics_ObserveCache(t *testing.T) {
+	m := New()
+
+	m.ObserveCache("product", true)
+	m.ObserveCache("product", false)
+	m.ObserveCache("product", true)
+
+	require.Equal(t, float64(2), testutil.ToFloat64(m.cacheRequests.WithLabelValues("product", "hit")))
+	require.Equal(t, float64(1), testutil.ToFloat64(m.cacheRequests.WithLabelValues("product", "miss")))
+}
+
+func TestMetrics_ObserveReplica(t *testing.T) {
+	m := New()
+
+	m.ObserveReplica("db-replica:3306", true)
+	require.Equal(t, float64(1), testutil.ToFloat64(m.replicaUp.WithLabelValues("db-replica:3306")))
+
+	m.ObserveReplica("db-replica:3306", false)
+	require.Equal(t, float64(0), testutil.ToFloat64(m.replicaUp.WithLabelValues("db-replica:3306")))
+}
+
+func TestMetrics_ObserveParked(t *testing.T) {
+	m := New()
+
+	m.ObserveParked("product.created")
+	m.ObserveParked("product.created")
+
+	require.Equal(t, float64(2), testutil.ToFloat64(m.outboxParked.WithLabelValues("product.created")))
+}
@@ -1,6 +1,9 @@
 package models
 
-import "database/sql"
+import (
+	"database/sql"
+	"time"
+)
 
 // тут мы будем описывать структуры для чтения
 
@@ -11,6 +14,8 @@ type Product struct {
 	Quantity int      `db:"quantity" json:"quantity"`
 	Price    float32  `db:"price" json:"price"`
 	Features Features `db:"features" json:"features"`
+	// UpdatedAt время последнего изменения товара или его характеристик
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
 }
 
 type Features struct {
@@ -19,3 +24,12 @@ type Features struct {
 	Display sql.NullInt32 `db:"display" json:"display"`
 	Camera  sql.NullInt32 `db:"camera" json:"camera"`
 }
+
+// BatchResult результат одной операции из пакета
+type BatchResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	ID     int    `json:"id,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
@@ -0,0 +1,177 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grip211/crud/pkg/events"
+)
+
+// тут минимальный клиент текстового протокола NATS, которого достаточно чтобы публиковать события
+// в локальный брокер (nats-server или совместимый). После каждой публикации отправляем PING
+// и ждем PONG, так мы знаем что брокер принял сообщение, и только тогда отмечаем его в outbox
+
+var ErrBroker = errors.New("broker error")
+
+type NATSSink struct {
+	addr    string
+	prefix  string
+	timeout time.Duration
+
+	mu      sync.Mutex
+	conn    net.Conn
+	reader  *bufio.Reader
+	headers bool
+}
+
+// NewNATSSink публикует события в subject "<prefix>.<тип события>", например crud.product.created
+func NewNATSSink(addr, prefix string, timeout time.Duration) *NATSSink {
+	if timeout <= 0 {
+		timeout = time.Second * 5
+	}
+	return &NATSSink{
+		addr:    addr,
+		prefix:  prefix,
+		timeout: timeout,
+	}
+}
+
+func (s *NATSSink) Name() string {
+	return "nats"
+}
+
+func (s *NATSSink) Publish(ctx context.Context, event events.Event) error {
+	payload, err := json.Marshal(&event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err = s.publish(ctx, s.prefix+"."+event.Type, strconv.FormatUint(event.ID, 10), payload); err != nil {
+		s.reset()
+		return err
+	}
+	return nil
+}
+
+func (s *NATSSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+func (s *NATSSink) publish(ctx context.Context, subject, msgID string, payload []byte) error {
+	if err := s.connect(ctx); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(s.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := s.conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+
+	var frame string
+	if s.headers {
+		// Nats-Msg-Id позволяет JetStream отбросить повторную публикацию того же события
+		header := "NATS/1.0\r\nNats-Msg-Id: " + msgID + "\r\n\r\n"
+		frame = fmt.Sprintf("HPUB %s %d %d\r\n%s%s\r\nPING\r\n", subject, len(header), len(header)+len(payload), header, payload)
+	} else {
+		frame = fmt.Sprintf("PUB %s %d\r\n%s\r\nPING\r\n", subject, len(payload), payload)
+	}
+	if _, err := s.conn.Write([]byte(frame)); err != nil {
+		return err
+	}
+
+	return s.waitPong()
+}
+
+func (s *NATSSink) connect(ctx context.Context) error {
+	if s.conn != nil {
+		return nil
+	}
+
+	dialer := &net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	s.reader = bufio.NewReader(conn)
+
+	if err = conn.SetDeadline(time.Now().Add(s.timeout)); err != nil {
+		return err
+	}
+
+	line, err := s.readLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("%w: unexpected greeting %q", ErrBroker, line)
+	}
+	var info struct {
+		Headers bool `json:"headers"`
+	}
+	_ = json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info)
+	s.headers = info.Headers
+
+	connect := fmt.Sprintf(`CONNECT {"verbose":false,"pedantic":false,"name":"crud-outbox","headers":%t}`+"\r\nPING\r\n", info.Headers)
+	if _, err = conn.Write([]byte(connect)); err != nil {
+		return err
+	}
+	return s.waitPong()
+}
+
+func (s *NATSSink) waitPong() error {
+	for {
+		line, err := s.readLine()
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err = s.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("%w: %s", ErrBroker, line)
+		}
+		// +OK и INFO пропускаем
+	}
+}
+
+func (s *NATSSink) readLine() (string, error) {
+	line, err := s.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (s *NATSSink) reset() {
+	if s.conn != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+}
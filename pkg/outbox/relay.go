@@ -0,0 +1,170 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/grip211/crud/pkg/events"
+	"github.com/grip211/crud/pkg/logging"
+)
+
+// Sink получатель событий из outbox. Publish должен вернуть ошибку, если событие не принято,
+// тогда оно будет отправлено повторно (at-least-once), поэтому получатели должны уметь отбрасывать дубли по Event.ID
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, event events.Event) error
+}
+
+type Options struct {
+	PollInterval time.Duration `yaml:"poll_interval"`
+	BatchSize    int           `yaml:"batch_size"`
+	// Retention сколько хранить опубликованные сообщения
+	Retention time.Duration `yaml:"retention"`
+	// MaxAttempts после стольких неудачных попыток сообщение откладывается и больше не держит события товара
+	MaxAttempts int `yaml:"max_attempts"`
+}
+
+// Observer получает отложенные сообщения, например для метрик
+type Observer interface {
+	ObserveParked(eventType string)
+}
+
+// Relay переносит сообщения из outbox в получателей. Порядок событий одного товара сохраняется:
+// если сообщение товара не удалось опубликовать, следующие сообщения этого товара ждут следующего прохода.
+// Relay рассчитан на один экземпляр на базу, несколько экземпляров дадут дубли и могут нарушить порядок
+type Relay struct {
+	store    Store
+	sinks    []Sink
+	opt      Options
+	observer Observer
+}
+
+func NewRelay(store Store, opt Options, sinks ...Sink) *Relay {
+	if opt.PollInterval <= 0 {
+		opt.PollInterval = time.Millisecond * 500
+	}
+	if opt.BatchSize <= 0 {
+		opt.BatchSize = 100
+	}
+	if opt.Retention <= 0 {
+		opt.Retention = time.Hour * 24
+	}
+	if opt.MaxAttempts <= 0 {
+		opt.MaxAttempts = 20
+	}
+	return &Relay{
+		store: store,
+		sinks: sinks,
+		opt:   opt,
+	}
+}
+
+// WithObserver включает учет отложенных сообщений, вызывать до Run
+func (r *Relay) WithObserver(observer Observer) *Relay {
+	r.observer = observer
+	return r
+}
+
+// Run публикует сообщения, пока не отменен ctx, и возвращается после завершения текущего прохода
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.opt.PollInterval)
+	defer ticker.Stop()
+
+	cleanup := time.NewTicker(time.Hour)
+	defer cleanup.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-cleanup.C:
+			if _, err := r.store.DeletePublished(ctx, r.opt.Retention); err != nil {
+				logging.FromContext(ctx).Error("delete published outbox messages", "error", err)
+			}
+		case <-ticker.C:
+			// разгребаем накопившееся, пока есть полные пачки
+			for {
+				n, err := r.RelayOnce(ctx)
+				if err != nil {
+					logging.FromContext(ctx).Error("relay outbox messages", "error", err)
+				}
+				if err != nil || n < r.opt.BatchSize || ctx.Err() != nil {
+					break
+				}
+			}
+		}
+	}
+}
+
+// RelayOnce делает один проход по неопубликованным сообщениям и возвращает сколько их было прочитано
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	messages, err := r.store.Pending(ctx, r.opt.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	blocked := make(map[int]struct{})
+	published := make([]int64, 0, len(messages))
+
+	for i := range messages {
+		message := &messages[i]
+		if _, ok := blocked[message.ProductID]; ok {
+			continue
+		}
+
+		if err = r.publish(ctx, message); err != nil {
+			if message.Attempts+1 >= r.opt.MaxAttempts {
+				if parkErr := r.park(ctx, message, err); parkErr != nil {
+					return len(messages), parkErr
+				}
+				continue
+			}
+			blocked[message.ProductID] = struct{}{}
+			if markErr := r.store.MarkFailed(ctx, message.ID, err); markErr != nil {
+				return len(messages), markErr
+			}
+			continue
+		}
+
+		published = append(published, message.ID)
+	}
+
+	if err = r.store.MarkPublished(ctx, published); err != nil {
+		return len(messages), err
+	}
+	if len(blocked) > 0 && len(published) == 0 {
+		// ничего не продвинулось, не крутим пустой цикл в Run
+		return 0, nil
+	}
+	return len(messages), nil
+}
+
+// park откладывает сообщение, которое не удается опубликовать: следующие события товара идут дальше,
+// а само сообщение остается в таблице с parked_at для разбора
+func (r *Relay) park(ctx context.Context, message *Message, cause error) error {
+	if err := r.store.Park(ctx, message.ID, cause); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Error("outbox message parked",
+		"id", message.ID, "product_id", message.ProductID, "event_type", message.EventType,
+		"attempts", message.Attempts+1, "error", cause,
+	)
+	if r.observer != nil {
+		r.observer.ObserveParked(message.EventType)
+	}
+	return nil
+}
+
+func (r *Relay) publish(ctx context.Context, message *Message) error {
+	event, err := message.Event()
+	if err != nil {
+		return err
+	}
+	for _, sink := range r.sinks {
+		if err = sink.Publish(ctx, event); err != nil {
+			return fmt.Errorf("sink %s: %w", sink.Name(), err)
+		}
+	}
+	return nil
+}
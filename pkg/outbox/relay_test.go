@@ -0,0 +1,219 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grip211/crud/pkg/events"
+	"github.com/grip211/crud/pkg/webhook"
+)
+
+type memoryStore struct {
+	messages  []Message
+	published map[int64]bool
+	parked    map[int64]bool
+}
+
+func (m *memoryStore) Pending(_ context.Context, limit int) ([]Message, error) {
+	var pending []Message
+	for _, message := range m.messages {
+		if !m.published[message.ID] && !m.parked[message.ID] && len(pending) < limit {
+			pending = append(pending, message)
+		}
+	}
+	return pending, nil
+}
+
+func (m *memoryStore) MarkPublished(_ context.Context, ids []int64) error {
+	for _, id := range ids {
+		m.published[id] = true
+	}
+	return nil
+}
+
+func (m *memoryStore) MarkFailed(_ context.Context, id int64, _ error) error {
+	for i := range m.messages {
+		if m.messages[i].ID == id {
+			m.messages[i].Attempts++
+		}
+	}
+	return nil
+}
+
+func (m *memoryStore) Park(ctx context.Context, id int64, err error) error {
+	m.parked[id] = true
+	return m.MarkFailed(ctx, id, err)
+}
+
+func (m *memoryStore) DeletePublished(context.Context, time.Duration) (int64, error) {
+	return 0, nil
+}
+
+func newMemoryStore(t *testing.T, list ...events.Event) *memoryStore {
+	store := &memoryStore{published: map[int64]bool{}, parked: map[int64]bool{}}
+	for i, event := range list {
+		payload, err := json.Marshal(&event)
+		require.NoError(t, err)
+		store.messages = append(store.messages, Message{
+			ID:        int64(i + 1),
+			ProductID: event.ProductID,
+			EventType: event.Type,
+			Payload:   payload,
+		})
+	}
+	return store
+}
+
+// flakySink не принимает события товара failProductID, пока failures больше нуля
+type flakySink struct {
+	failProductID int
+	failures      int
+	received      []uint64
+}
+
+func (s *flakySink) Name() string {
+	return "flaky"
+}
+
+func (s *flakySink) Publish(_ context.Context, event events.Event) error {
+	if event.ProductID == s.failProductID && s.failures > 0 {
+		s.failures--
+		return errors.New("sink is unavailable")
+	}
+	s.received = append(s.received, event.ID)
+	return nil
+}
+
+func TestRelay_RelayOnce(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	store := newMemoryStore(t,
+		events.Event{Type: events.TypeCreated, ProductID: 1},
+		events.Event{Type: events.TypeCreated, ProductID: 2},
+		events.Event{Type: events.TypeUpdated, ProductID: 1},
+		events.Event{Type: events.TypeUpdated, ProductID: 2},
+	)
+
+	sink := &flakySink{failProductID: 1, failures: 1}
+	relay := NewRelay(store, Options{}, sink)
+
+	// первый проход: событие товара 1 не принято, следующее событие товара 1 ждет,
+	// а события товара 2 публикуются
+	_, err := relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{2, 4}, sink.received)
+	require.Equal(t, 1, store.messages[0].Attempts)
+
+	// второй проход: события товара 1 публикуются по порядку
+	_, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{2, 4, 1, 3}, sink.received)
+
+	// третий проход: публиковать нечего
+	n, err := relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+type parkedCounter map[string]int
+
+func (c parkedCounter) ObserveParked(eventType string) {
+	c[eventType]++
+}
+
+func TestRelay_Park(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	store := newMemoryStore(t,
+		events.Event{Type: events.TypeCreated, ProductID: 1},
+		events.Event{Type: events.TypeUpdated, ProductID: 1},
+	)
+	parked := parkedCounter{}
+	sink := &flakySink{failProductID: 1, failures: 2}
+	relay := NewRelay(store, Options{MaxAttempts: 2}, sink).WithObserver(parked)
+
+	// первая попытка: сообщение ждет повтора и держит следующее событие товара
+	_, err := relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	require.Empty(t, sink.received)
+	require.False(t, store.parked[1])
+
+	// вторая попытка последняя: сообщение откладывается, следующее событие товара публикуется
+	_, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	require.True(t, store.parked[1])
+	require.Equal(t, 2, store.messages[0].Attempts)
+	require.Equal(t, []uint64{2}, sink.received)
+	require.Equal(t, parkedCounter{events.TypeCreated: 1}, parked)
+
+	n, err := relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+// deliveryStore очередь доставок вебхуков в памяти с тем же уникальным ключом (subscription_id, event_id), что в базе
+type deliveryStore struct {
+	subscriptions []webhook.Subscription
+	deliveries    []webhook.Delivery
+}
+
+func (d *deliveryStore) ActiveSubscriptions(context.Context) ([]webhook.Subscription, error) {
+	return d.subscriptions, nil
+}
+
+func (d *deliveryStore) EnqueueDeliveries(_ context.Context, deliveries []webhook.Delivery) error {
+	for _, delivery := range deliveries {
+		duplicate := false
+		for _, queued := range d.deliveries {
+			if queued.SubscriptionID == delivery.SubscriptionID && queued.EventID == delivery.EventID {
+				duplicate = true
+			}
+		}
+		if !duplicate {
+			d.deliveries = append(d.deliveries, delivery)
+		}
+	}
+	return nil
+}
+
+func (d *deliveryStore) DueDeliveries(context.Context, time.Time, int) ([]webhook.Delivery, error) {
+	return nil, nil
+}
+
+func (d *deliveryStore) Target(context.Context, int) (*webhook.Target, error) {
+	return nil, webhook.ErrNotFound
+}
+
+func (d *deliveryStore) SaveAttempt(context.Context, *webhook.Delivery) error {
+	return nil
+}
+
+func TestRelay_RetryAfterLaterSinkFails(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	store := newMemoryStore(t, events.Event{Type: events.TypeCreated, ProductID: 1, Tenant: "shop-a"})
+	deliveries := &deliveryStore{subscriptions: []webhook.Subscription{{ID: 1, TenantID: "shop-a", Active: true}}}
+	dispatcher := webhook.NewDispatcher(deliveries, webhook.Options{})
+	broker := &flakySink{failProductID: 1, failures: 2}
+	relay := NewRelay(store, Options{}, NewWebhookSink(dispatcher), broker)
+
+	// брокер дважды не принимает событие, relay каждый раз повторяет его и для вебхуков
+	for i := 0; i < 3; i++ {
+		_, err := relay.RelayOnce(ctx)
+		require.NoError(t, err)
+	}
+	require.True(t, store.published[1])
+	require.Equal(t, []uint64{1}, broker.received)
+
+	// получатель вебхука все равно получит событие один раз
+	require.Len(t, deliveries.deliveries, 1)
+	require.Equal(t, uint64(1), deliveries.deliveries[0].EventID)
+}
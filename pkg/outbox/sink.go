@@ -0,0 +1,64 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/grip211/crud/pkg/events"
+	"github.com/grip211/crud/pkg/logging"
+)
+
+// LogSink пишет события в лог, удобно для отладки
+type LogSink struct{}
+
+func (LogSink) Name() string {
+	return "log"
+}
+
+func (LogSink) Publish(ctx context.Context, event events.Event) error {
+	data, err := json.Marshal(&event)
+	if err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Info("outbox event", "event", json.RawMessage(data))
+	return nil
+}
+
+// BusSink публикует события в шину внутри процесса (SSE и WebSocket)
+type BusSink struct {
+	bus events.Publisher
+}
+
+func NewBusSink(bus events.Publisher) *BusSink {
+	return &BusSink{bus: bus}
+}
+
+func (s *BusSink) Name() string {
+	return "bus"
+}
+
+func (s *BusSink) Publish(_ context.Context, event events.Event) error {
+	s.bus.Publish(event)
+	return nil
+}
+
+type enqueuer interface {
+	Enqueue(ctx context.Context, list ...events.Event) error
+}
+
+// WebhookSink ставит события в очередь доставки вебхуков
+type WebhookSink struct {
+	dispatcher enqueuer
+}
+
+func NewWebhookSink(dispatcher enqueuer) *WebhookSink {
+	return &WebhookSink{dispatcher: dispatcher}
+}
+
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *WebhookSink) Publish(ctx context.Context, event events.Event) error {
+	return s.dispatcher.Enqueue(ctx, event)
+}
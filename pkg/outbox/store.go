@@ -0,0 +1,152 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	builder "github.com/doug-martin/goqu/v9"
+
+	"github.com/grip211/crud/pkg/database"
+	"github.com/grip211/crud/pkg/events"
+)
+
+// тут описываем таблицу Outbox: события пишутся в нее в той же транзакции,
+// что и изменения товаров, а Relay потом доставляет их получателям
+
+// Table имя таблицы, схему добавляет database.Table
+const Table = "Outbox"
+
+const maxErrorLength = 1024
+
+type Message struct {
+	ID        int64     `db:"id"`
+	ProductID int       `db:"product_id"`
+	EventType string    `db:"event_type"`
+	Payload   []byte    `db:"payload"`
+	Attempts  int       `db:"attempts"`
+	LastError string    `db:"last_error"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// Event восстанавливает событие, номером события становится номер строки в outbox
+func (m *Message) Event() (events.Event, error) {
+	var event events.Event
+	if err := json.Unmarshal(m.Payload, &event); err != nil {
+		return event, fmt.Errorf("unmarshal outbox message %d: %w", m.ID, err)
+	}
+	event.ID = uint64(m.ID)
+	return event, nil
+}
+
+// Rows готовит строки для вставки в Table, вставлять их нужно в транзакции изменения товаров
+func Rows(list ...events.Event) ([]interface{}, error) {
+	rows := make([]interface{}, 0, len(list))
+	for i := range list {
+		payload, err := json.Marshal(&list[i])
+		if err != nil {
+			return nil, fmt.Errorf("marshal outbox event: %w", err)
+		}
+		rows = append(rows, builder.Record{
+			"product_id": list[i].ProductID,
+			"event_type": list[i].Type,
+			"payload":    payload,
+		})
+	}
+	return rows, nil
+}
+
+type Store interface {
+	// Pending неопубликованные сообщения по порядку
+	Pending(ctx context.Context, limit int) ([]Message, error)
+	MarkPublished(ctx context.Context, ids []int64) error
+	MarkFailed(ctx context.Context, id int64, err error) error
+	// Park последняя неудачная попытка, сообщение больше не возвращается в Pending
+	Park(ctx context.Context, id int64, err error) error
+	DeletePublished(ctx context.Context, retention time.Duration) (int64, error)
+}
+
+type Repo struct {
+	db database.Pool
+}
+
+func NewRepo(db database.Pool) *Repo {
+	return &Repo{
+		db: db,
+	}
+}
+
+func (r *Repo) Pending(ctx context.Context, limit int) ([]Message, error) {
+	var messages []Message
+	err := r.db.Builder().
+		From(database.Table(r.db, Table)).
+		Select("id", "product_id", "event_type", "payload", "attempts", "last_error", "created_at").
+		Where(builder.C("published_at").IsNull(), builder.C("parked_at").IsNull()).
+		Order(builder.C("id").Asc()).
+		Limit(uint(limit)).
+		ScanStructsContext(ctx, &messages)
+	if err != nil {
+		return nil, fmt.Errorf("fetch outbox messages: %w", err)
+	}
+	return messages, nil
+}
+
+func (r *Repo) MarkPublished(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := r.db.Builder().
+		Update(database.Table(r.db, Table)).
+		Set(builder.Record{"published_at": time.Now()}).
+		Where(builder.C("id").In(ids)).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("mark outbox messages published: %w", err)
+	}
+	return nil
+}
+
+func (r *Repo) MarkFailed(ctx context.Context, id int64, cause error) error {
+	if err := r.fail(ctx, id, cause, builder.Record{}); err != nil {
+		return fmt.Errorf("mark outbox message failed: %w", err)
+	}
+	return nil
+}
+
+func (r *Repo) Park(ctx context.Context, id int64, cause error) error {
+	if err := r.fail(ctx, id, cause, builder.Record{"parked_at": time.Now()}); err != nil {
+		return fmt.Errorf("park outbox message: %w", err)
+	}
+	return nil
+}
+
+func (r *Repo) fail(ctx context.Context, id int64, cause error, record builder.Record) error {
+	message := cause.Error()
+	if len(message) > maxErrorLength {
+		message = message[:maxErrorLength]
+	}
+	record["attempts"] = builder.L("attempts + 1")
+	record["last_error"] = message
+	_, err := r.db.Builder().
+		Update(database.Table(r.db, Table)).
+		Set(record).
+		Where(builder.C("id").Eq(id)).
+		Executor().
+		ExecContext(ctx)
+	return err
+}
+
+// DeletePublished удаляет опубликованные сообщения старше retention
+func (r *Repo) DeletePublished(ctx context.Context, retention time.Duration) (int64, error) {
+	res, err := r.db.Builder().
+		Delete(database.Table(r.db, Table)).
+		Where(builder.C("published_at").Lt(time.Now().Add(-retention))).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("delete published outbox messages: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/grip211/crud/pkg/commands"
+	"github.com/grip211/crud/pkg/models"
+)
+
+const (
+	BatchStatusOK    = "ok"
+	BatchStatusError = "error"
+)
+
+// BatchError ошибка операции пакета с ее порядковым номером
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("operation %d: %s", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// Batch выполняет пакет операций. В режиме atomic все операции выполняются в одной транзакции,
+// подряд идущие create объединяются в многострочный INSERT, а первая ошибка откатывает весь пакет
+// и возвращается как *BatchError. В режиме independent каждая операция выполняется в своей транзакции,
+// ошибки попадают в результат операции
+func (r *Repo) Batch(ctx context.Context, command *commands.BatchCommand) (_ []models.BatchResult, err error) {
+	ctx, end := r.start(ctx, OpBatch)
+	defer end(&err)
+
+	if command.Mode == commands.BatchModeIndependent {
+		return r.batchIndependent(ctx, command.Operations), nil
+	}
+
+	for i, operation := range command.Operations {
+		if operation.Err != nil {
+			return nil, &BatchError{Index: i, Err: operation.Err}
+		}
+	}
+
+	var results []models.BatchResult
+	err = r.WithTx(ctx, func(repo *Repo) error {
+		var err error
+		results, err = repo.batchAtomic(ctx, command.Operations)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (r *Repo) batchAtomic(ctx context.Context, operations []*commands.BatchOperation) ([]models.BatchResult, error) {
+	results := make([]models.BatchResult, 0, len(operations))
+
+	for i := 0; i < len(operations); {
+		if operations[i].Op == commands.BatchOpCreate {
+			// собираем подряд идущие create в один INSERT
+			j := i
+			creates := make([]*commands.CreateCommand, 0)
+			for ; j < len(operations) && operations[j].Op == commands.BatchOpCreate; j++ {
+				creates = append(creates, operations[j].Create)
+			}
+
+			ids, err := r.CreateMany(ctx, creates)
+			if err != nil {
+				return nil, &BatchError{Index: i, Err: err}
+			}
+			for k, id := range ids {
+				results = append(results, models.BatchResult{
+					Index:  i + k,
+					Op:     commands.BatchOpCreate,
+					ID:     id,
+					Status: BatchStatusOK,
+				})
+			}
+
+			i = j
+			continue
+		}
+
+		id, err := r.batchOne(ctx, operations[i])
+		if err != nil {
+			return nil, &BatchError{Index: i, Err: err}
+		}
+		results = append(results, models.BatchResult{
+			Index:  i,
+			Op:     operations[i].Op,
+			ID:     id,
+			Status: BatchStatusOK,
+		})
+		i++
+	}
+
+	return results, nil
+}
+
+func (r *Repo) batchIndependent(ctx context.Context, operations []*commands.BatchOperation) []models.BatchResult {
+	results := make([]models.BatchResult, 0, len(operations))
+
+	for i, operation := range operations {
+		result := models.BatchResult{
+			Index:  i,
+			Op:     operation.Op,
+			Status: BatchStatusOK,
+		}
+
+		err := operation.Err
+		if err == nil {
+			err = r.WithTx(ctx, func(repo *Repo) error {
+				var txErr error
+				result.ID, txErr = repo.batchOne(ctx, operation)
+				return txErr
+			})
+		}
+		if err != nil {
+			result.ID = 0
+			result.Status = BatchStatusError
+			result.Error = err.Error()
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+func (r *Repo) batchOne(ctx context.Context, operation *commands.BatchOperation) (int, error) {
+	switch operation.Op {
+	case commands.BatchOpCreate:
+		return r.Create(ctx, operation.Create)
+	case commands.BatchOpUpdate:
+		return operation.Update.ID, r.Update(ctx, operation.Update)
+	case commands.BatchOpDelete:
+		affected, err := r.Delete(ctx, operation.Delete)
+		if err != nil {
+			return 0, err
+		}
+		if affected == 0 {
+			return 0, ErrNotFound
+		}
+		return operation.Delete.ID, nil
+	default:
+		return 0, fmt.Errorf("%w: %q", commands.ErrBatchUnknownOp, operation.Op)
+	}
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/grip211/crud/pkg/commands"
+	"github.com/grip211/crud/pkg/events"
+	"github.com/grip211/crud/pkg/models"
+	"github.com/grip211/crud/pkg/outbox"
+	"github.com/grip211/crud/pkg/tracing"
+)
+
+// record пишет события об изменении товаров в outbox, вызывается внутри транзакции изменения,
+// поэтому событие сохраняется тогда и только тогда, когда сохраняется само изменение
+func (r *Repo) record(ctx context.Context, list ...events.Event) error {
+	if len(list) == 0 {
+		return nil
+	}
+
+	// трасса запроса едет вместе с событием до вебхуков
+	if traceParent := tracing.TraceParent(ctx); traceParent != "" {
+		for i := range list {
+			list[i].TraceParent = traceParent
+		}
+	}
+
+	rows, err := outbox.Rows(list...)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInsertOutbox, err)
+	}
+
+	_, err = r.builder().
+		Insert(r.table(outbox.Table)).
+		Rows(rows...).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInsertOutbox, err)
+	}
+	return nil
+}
+
+func createdEvent(tenantID string, id int, command *commands.CreateCommand) events.Event {
+	return productEvent(tenantID, events.TypeCreated, &models.Product{
+		ID:       id,
+		Model:    command.Model,
+		Company:  command.Company,
+		Quantity: command.Quantity,
+		Price:    command.Price,
+		Features: features(command.CPU, command.Memory, command.DisplaySize, command.Camera),
+	})
+}
+
+func updatedEvents(tenantID string, before *models.Product, command *commands.UpdateCommand) []events.Event {
+	product := &models.Product{
+		ID:       command.ID,
+		Model:    command.Model,
+		Company:  command.Company,
+		Quantity: command.Quantity,
+		Price:    command.Price,
+		Features: features(command.CPU, command.Memory, command.DisplaySize, command.Camera),
+	}
+
+	list := []events.Event{productEvent(tenantID, events.TypeUpdated, product)}
+	if before != nil && before.Quantity != command.Quantity {
+		list = append(list, productEvent(tenantID, events.TypeStock, product))
+	}
+	return list
+}
+
+func deletedEvent(tenantID string, id int, before *models.Product) events.Event {
+	if before == nil {
+		before = &models.Product{ID: id}
+	}
+	return productEvent(tenantID, events.TypeDeleted, before)
+}
+
+func productEvent(tenantID, eventType string, product *models.Product) events.Event {
+	return events.Event{
+		Tenant:    tenantID,
+		Type:      eventType,
+		ProductID: product.ID,
+		Company:   product.Company,
+		Product:   product,
+		At:        time.Now(),
+	}
+}
+
+func features(cpu, memory, display, camera int) models.Features {
+	return models.Features{
+		CPU:     sql.NullInt32{Int32: int32(cpu), Valid: true},
+		Memory:  sql.NullInt32{Int32: int32(memory), Valid: true},
+		Display: sql.NullInt32{Int32: int32(display), Valid: true},
+		Camera:  sql.NullInt32{Int32: int32(camera), Valid: true},
+	}
+}
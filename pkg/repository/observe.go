@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/grip211/crud/pkg/tracing"
+)
+
+// имена операций для Observer
+const (
+	OpCreate              = "Create"
+	OpCreateMany          = "CreateMany"
+	OpRead                = "Read"
+	OpReadOne             = "ReadOne"
+	OpReadOneWithFeatures = "ReadOneWithFeatures"
+	OpUpdate              = "Update"
+	OpDelete              = "Delete"
+	OpBatch               = "Batch"
+)
+
+// Observer получает каждую операцию репозитория с длительностью и ошибкой, через него снимаются метрики
+type Observer interface {
+	ObserveOperation(operation string, duration time.Duration, err error)
+}
+
+// WithObserver копия репозитория, которая сообщает об операциях observer
+func (r *Repo) WithObserver(observer Observer) *Repo {
+	clone := *r
+	clone.observer = observer
+	return &clone
+}
+
+// start открывает спан операции, возвращенная функция закрывает его и сообщает observer
+func (r *Repo) start(ctx context.Context, operation string) (context.Context, func(err *error)) {
+	started := time.Now()
+	ctx, span := tracing.Tracer().Start(ctx, "repository."+operation)
+
+	return ctx, func(err *error) {
+		if *err != nil {
+			span.RecordError(*err)
+			span.SetStatus(codes.Error, (*err).Error())
+		}
+		span.End()
+
+		if r.observer != nil {
+			r.observer.ObserveOperation(operation, time.Since(started), *err)
+		}
+	}
+}
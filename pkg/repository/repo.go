@@ -6,10 +6,13 @@ import (
 	"fmt"
 
 	builder "github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
 
 	"github.com/grip211/crud/pkg/commands"
 	"github.com/grip211/crud/pkg/database"
+	"github.com/grip211/crud/pkg/events"
 	"github.com/grip211/crud/pkg/models"
+	"github.com/grip211/crud/pkg/tenant"
 )
 
 // Repo пишем структуру которая будет реализовывать все нужные нам методы для работы с базой данных
@@ -32,10 +35,38 @@ var (
 	ErrFetchProductWithReadOne  = errors.New("fetch products with read one")
 	ErrUpdateProduct            = errors.New("update product")
 	ErrUpsertFeature            = errors.New("upsert feature")
+	ErrBeginTx                  = errors.New("begin transaction")
+	ErrCommitTx                 = errors.New("commit transaction")
+	ErrInsertOutbox             = errors.New("insert outbox")
 )
 
+// query общий набор методов для goqu.Database и goqu.TxDatabase,
+// чтобы одни и те же методы репозитория работали как внутри транзакции, так и без нее
+type query interface {
+	From(from ...interface{}) *builder.SelectDataset
+	Select(cols ...interface{}) *builder.SelectDataset
+	Insert(table interface{}) *builder.InsertDataset
+	Update(table interface{}) *builder.UpdateDataset
+	Delete(table interface{}) *builder.DeleteDataset
+}
+
+// Catalog операции с каталогом, которыми пользуются обработчики. Кроме Repo его реализуют
+// обертки над ним, например кеш
+type Catalog interface {
+	Read(ctx context.Context) ([]models.Product, error)
+	ReadOne(ctx context.Context, id int) (*models.Product, error)
+	ReadOneWithFeatures(ctx context.Context, id int) (*models.Product, error)
+	Create(ctx context.Context, command *commands.CreateCommand) (int, error)
+	Update(ctx context.Context, command *commands.UpdateCommand) error
+	Delete(ctx context.Context, command *commands.DeleteCommand) (int64, error)
+	Batch(ctx context.Context, command *commands.BatchCommand) ([]models.BatchResult, error)
+}
+
 type Repo struct {
 	db database.Pool
+	tx *builder.TxDatabase
+	// observer только у репозитория верхнего уровня, операции внутри транзакции отдельно не считаются
+	observer Observer
 }
 
 func New(db database.Pool) *Repo {
@@ -44,29 +75,100 @@ func New(db database.Pool) *Repo {
 	}
 }
 
-func (r *Repo) Create(ctx context.Context, command *commands.CreateCommand) (int, error) {
-	result, err := r.db.Builder().
-		Insert("productdb.Products").
-		Rows(builder.Record{
-			"model":    command.Model,
-			"company":  command.Company,
-			"quantity": command.Quantity,
-			"price":    command.Price,
-		}).
-		Executor().
-		ExecContext(ctx)
+func (r *Repo) builder() query {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.db.Builder()
+}
+
+// table таблица name в схеме пула
+func (r *Repo) table(name string) exp.IdentifierExpression {
+	return database.Table(r.db, name)
+}
+
+// insertError ошибка INSERT товаров: не удалось вставить или не удалось узнать id
+func insertError(err error) error {
+	if errors.Is(err, database.ErrLastInsertID) {
+		return ErrLastInsertRow
+	}
+	return ErrInsertProducts
+}
+
+// reader для чтения: внутри транзакции сама транзакция, иначе реплика, если ctx еще ничего не писал
+func (r *Repo) reader(ctx context.Context) query {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.db.Reader(ctx)
+}
+
+// WithTx выполняет fn в одной транзакции, в fn передается копия репозитория, привязанная к транзакции.
+// Если fn возвращает ошибку, транзакция откатывается. Вложенные вызовы используют уже открытую транзакцию.
+func (r *Repo) WithTx(ctx context.Context, fn func(repo *Repo) error) error {
+	if r.tx != nil {
+		return fn(r)
+	}
+
+	// дальше запрос читает из основной базы, реплика может еще не получить эту запись
+	database.MarkWritten(ctx)
+
+	tx, err := r.db.Builder().BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBeginTx, err)
+	}
+
+	if err = fn(&Repo{db: r.db, tx: tx}); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", ErrCommitTx, err)
+	}
+	return nil
+}
+
+// Create создает товар с характеристиками и событие в outbox в одной транзакции
+func (r *Repo) Create(ctx context.Context, command *commands.CreateCommand) (_ int, err error) {
+	ctx, end := r.start(ctx, OpCreate)
+	defer end(&err)
+
+	var id int
+	err = r.WithTx(ctx, func(repo *Repo) error {
+		var err error
+		id, err = repo.create(ctx, command)
+		return err
+	})
 	if err != nil {
-		return 0, fmt.Errorf("insert: %w", ErrInsertProducts)
+		return 0, err
 	}
+	return id, nil
+}
 
-	id, err := result.LastInsertId()
+func (r *Repo) create(ctx context.Context, command *commands.CreateCommand) (int, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := database.InsertID(ctx, r.builder().
+		Insert(r.table("Products")).
+		Rows(builder.Record{
+			"tenant_id": tenantID,
+			"model":     command.Model,
+			"company":   command.Company,
+			"quantity":  command.Quantity,
+			"price":     command.Price,
+		}))
 	if err != nil {
-		return 0, fmt.Errorf("insert: %w", ErrLastInsertRow)
+		return 0, fmt.Errorf("insert: %w", insertError(err))
 	}
 
-	_, err = r.db.Builder().
-		Insert("productdb.ProductsFeatures").
+	_, err = r.builder().
+		Insert(r.table("ProductsFeatures")).
 		Rows(builder.Record{
+			"tenant_id":    tenantID,
 			"product_id":   id,
 			"cpu":          command.CPU,
 			"memory":       command.Memory,
@@ -79,20 +181,113 @@ func (r *Repo) Create(ctx context.Context, command *commands.CreateCommand) (int
 		return 0, fmt.Errorf("insert: %w", ErrInsertProductFeatures)
 	}
 
+	if err = r.record(ctx, createdEvent(tenantID, int(id), command)); err != nil {
+		return 0, err
+	}
+
 	return int(id), nil
 }
 
-func (r *Repo) Read(ctx context.Context) ([]models.Product, error) {
+// CreateMany создает несколько товаров двумя многострочными INSERT-ами (товары и их характеристики),
+// все выполняется в одной транзакции. Как получаются id новых товаров, см. database.InsertIDs
+func (r *Repo) CreateMany(ctx context.Context, creates []*commands.CreateCommand) (_ []int, err error) {
+	ctx, end := r.start(ctx, OpCreateMany)
+	defer end(&err)
+
+	if len(creates) == 0 {
+		return nil, nil
+	}
+
+	var ids []int
+	err = r.WithTx(ctx, func(repo *Repo) error {
+		var err error
+		ids, err = repo.createMany(ctx, creates)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *Repo) createMany(ctx context.Context, creates []*commands.CreateCommand) ([]int, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	products := make([]interface{}, 0, len(creates))
+	for _, command := range creates {
+		products = append(products, builder.Record{
+			"tenant_id": tenantID,
+			"model":     command.Model,
+			"company":   command.Company,
+			"quantity":  command.Quantity,
+			"price":     command.Price,
+		})
+	}
+
+	inserted, err := database.InsertIDs(ctx, r.builder().
+		Insert(r.table("Products")).
+		Rows(products...), len(products))
+	if err != nil {
+		return nil, fmt.Errorf("insert many: %w", insertError(err))
+	}
+
+	ids := make([]int, 0, len(creates))
+	rows := make([]interface{}, 0, len(creates))
+	created := make([]events.Event, 0, len(creates))
+	for i, command := range creates {
+		id := int(inserted[i])
+		ids = append(ids, id)
+		created = append(created, createdEvent(tenantID, id, command))
+		rows = append(rows, builder.Record{
+			"tenant_id":    tenantID,
+			"product_id":   id,
+			"cpu":          command.CPU,
+			"memory":       command.Memory,
+			"display_size": command.DisplaySize,
+			"camera":       command.Camera,
+		})
+	}
+
+	_, err = r.builder().
+		Insert(r.table("ProductsFeatures")).
+		Rows(rows...).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("insert many: %w", ErrInsertProductFeatures)
+	}
+
+	if err = r.record(ctx, created...); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func (r *Repo) Read(ctx context.Context) (_ []models.Product, err error) {
+	ctx, end := r.start(ctx, OpRead)
+	defer end(&err)
+
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var products []models.Product
-	err := r.db.Builder().
+	err = r.reader(ctx).
 		Select(
 			builder.C("id"),
 			builder.C("company"),
 			builder.C("model"),
 			builder.C("quantity"),
 			builder.C("price"),
+			builder.C("updated_at"),
 		).
-		From("productdb.Products").
+		From(r.table("Products")).
+		Where(builder.C("tenant_id").Eq(tenantID)).
 		ScanStructsContext(ctx, &products)
 
 	if err != nil {
@@ -101,19 +296,29 @@ func (r *Repo) Read(ctx context.Context) ([]models.Product, error) {
 	return products, nil
 }
 
-func (r *Repo) ReadOne(ctx context.Context, id int) (*models.Product, error) {
+func (r *Repo) ReadOne(ctx context.Context, id int) (_ *models.Product, err error) {
+	ctx, end := r.start(ctx, OpReadOne)
+	defer end(&err)
+
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var product models.Product
-	found, err := r.db.Builder().
+	found, err := r.reader(ctx).
 		Select(
 			builder.C("id"),
 			builder.C("company"),
 			builder.C("model"),
 			builder.C("quantity"),
 			builder.C("price"),
+			builder.C("updated_at"),
 		).
-		From("productdb.Products").
+		From(r.table("Products")).
 		Where(
 			builder.C("id").Eq(id),
+			builder.C("tenant_id").Eq(tenantID),
 		).
 		ScanStructContext(ctx, &product)
 
@@ -126,28 +331,39 @@ func (r *Repo) ReadOne(ctx context.Context, id int) (*models.Product, error) {
 	return &product, nil
 }
 
-func (r *Repo) ReadOneWithFeatures(ctx context.Context, id int) (*models.Product, error) {
+func (r *Repo) ReadOneWithFeatures(ctx context.Context, id int) (_ *models.Product, err error) {
+	ctx, end := r.start(ctx, OpReadOneWithFeatures)
+	defer end(&err)
+
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var product models.Product
-	found, err := r.db.Builder().
+	found, err := r.reader(ctx).
 		Select(
 			builder.I("Products.id").As("id"),
 			builder.C("company"),
 			builder.C("model"),
 			builder.C("quantity"),
 			builder.C("price"),
+			builder.I("Products.updated_at").As("updated_at"),
 			builder.I("ProductsFeatures.cpu").As(builder.C("features.cpu")),
 			builder.I("ProductsFeatures.memory").As(builder.C("features.memory")),
 			builder.I("ProductsFeatures.display_size").As(builder.C("features.display")),
 			builder.I("ProductsFeatures.camera").As(builder.C("features.camera")),
 		).
-		From("productdb.Products").
+		From(r.table("Products")).
 		LeftJoin(
-			builder.T("ProductsFeatures"),
+			r.table("ProductsFeatures"),
 			builder.On(builder.Ex{
-				"Products.id": builder.I("ProductsFeatures.product_id")}),
+				"Products.id":        builder.I("ProductsFeatures.product_id"),
+				"Products.tenant_id": builder.I("ProductsFeatures.tenant_id")}),
 		).
 		Where(
 			builder.I("Products.id").Eq(id),
+			builder.I("Products.tenant_id").Eq(tenantID),
 		).
 		ScanStructContext(ctx, &product)
 
@@ -161,17 +377,68 @@ func (r *Repo) ReadOneWithFeatures(ctx context.Context, id int) (*models.Product
 	return &product, nil
 }
 
-func (r *Repo) Update(ctx context.Context, command *commands.UpdateCommand) error {
-	_, err := r.db.Builder().
-		Update("productdb.Products").
+type updateCheckKey struct{}
+
+// UpdateCheck проверка изменения по текущему состоянию товара, ошибка отменяет изменение
+type UpdateCheck func(current *models.Product, command *commands.UpdateCommand) error
+
+// WithUpdateCheck Update выполнит check внутри своей транзакции после блокировки строки товара,
+// поэтому проверка видит то же состояние, которое будет изменено, а не кеш или реплику
+func WithUpdateCheck(ctx context.Context, check UpdateCheck) context.Context {
+	return context.WithValue(ctx, updateCheckKey{}, check)
+}
+
+// CheckUpdate выполняет проверку из ctx, если она есть. Нужна реализациям Catalog
+func CheckUpdate(ctx context.Context, current *models.Product, command *commands.UpdateCommand) error {
+	if check, ok := ctx.Value(updateCheckKey{}).(UpdateCheck); ok {
+		return check(current, command)
+	}
+	return nil
+}
+
+// Update изменяет товар и его характеристики и пишет события в outbox в одной транзакции
+func (r *Repo) Update(ctx context.Context, command *commands.UpdateCommand) (err error) {
+	ctx, end := r.start(ctx, OpUpdate)
+	defer end(&err)
+
+	return r.WithTx(ctx, func(repo *Repo) error {
+		return repo.update(ctx, command)
+	})
+}
+
+func (r *Repo) update(ctx context.Context, command *commands.UpdateCommand) error {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	// прежнее состояние нужно, чтобы понять, было ли изменение остатков, а заодно проверяем,
+	// что товар принадлежит арендатору: характеристики ниже пишутся upsert-ом по product_id.
+	// Строка блокируется до конца транзакции, чтобы проверка из ctx и изменение видели одно состояние
+	if err = r.lock(ctx, tenantID, command.ID); err != nil {
+		return err
+	}
+	before, err := r.ReadOneWithFeatures(ctx, command.ID)
+	if err != nil {
+		return err
+	}
+	if err = CheckUpdate(ctx, before, command); err != nil {
+		return err
+	}
+
+	_, err = r.builder().
+		Update(r.table("Products")).
 		Set(builder.Record{
 			"model":    command.Model,
 			"company":  command.Company,
 			"quantity": command.Quantity,
 			"price":    command.Price,
+			// ON UPDATE не срабатывает, если изменились только характеристики
+			"updated_at": builder.L("current_timestamp(6)"),
 		}).
 		Where(
 			builder.C("id").Eq(command.ID),
+			builder.C("tenant_id").Eq(tenantID),
 		).
 		Executor().
 		ExecContext(ctx)
@@ -179,16 +446,18 @@ func (r *Repo) Update(ctx context.Context, command *commands.UpdateCommand) erro
 		return fmt.Errorf("update product: %w", ErrUpdateProduct)
 	}
 
-	_, err = r.db.Builder().
-		Insert("productdb.ProductsFeatures").
+	_, err = r.builder().
+		Insert(r.table("ProductsFeatures")).
 		Rows(builder.Record{
+			"tenant_id":    tenantID,
 			"product_id":   command.ID,
 			"cpu":          command.CPU,
 			"memory":       command.Memory,
 			"display_size": command.DisplaySize,
 			"camera":       command.Camera,
 		}).
-		OnConflict(builder.DoUpdate("key", builder.Record{
+		// MySQL цель конфликта не указывает, берет любой уникальный ключ, PostgreSQL нужен столбец
+		OnConflict(builder.DoUpdate("product_id", builder.Record{
 			"cpu":          command.CPU,
 			"memory":       command.Memory,
 			"display_size": command.DisplaySize,
@@ -200,13 +469,62 @@ func (r *Repo) Update(ctx context.Context, command *commands.UpdateCommand) erro
 		return fmt.Errorf("upsert product feature: %w", ErrUpsertFeature)
 	}
 
+	return r.record(ctx, updatedEvents(tenantID, before, command)...)
+}
+
+// lock блокирует строку товара в основной базе до конца транзакции
+func (r *Repo) lock(ctx context.Context, tenantID string, id int) error {
+	var locked int
+	found, err := r.builder().
+		From(r.table("Products")).
+		Select(builder.C("id")).
+		Where(
+			builder.C("id").Eq(id),
+			builder.C("tenant_id").Eq(tenantID),
+		).
+		ForUpdate(exp.Wait).
+		ScanValContext(ctx, &locked)
+	if err != nil {
+		return fmt.Errorf("lock product: %w", ErrFetchProductWithReadOne)
+	}
+	if !found {
+		return ErrNotFound
+	}
 	return nil
 }
 
-func (r *Repo) Delete(ctx context.Context, command *commands.DeleteCommand) (int64, error) {
-	res, err := r.db.Builder().
-		Delete("productdb.Products").
-		Where(builder.C("id").Eq(command.ID)).
+// Delete удаляет товар и пишет событие в outbox в одной транзакции
+func (r *Repo) Delete(ctx context.Context, command *commands.DeleteCommand) (_ int64, err error) {
+	ctx, end := r.start(ctx, OpDelete)
+	defer end(&err)
+
+	var affected int64
+	err = r.WithTx(ctx, func(repo *Repo) error {
+		var err error
+		affected, err = repo.delete(ctx, command)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return affected, nil
+}
+
+func (r *Repo) delete(ctx context.Context, command *commands.DeleteCommand) (int64, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// компания нужна подписчикам для фильтрации
+	before, _ := r.ReadOne(ctx, command.ID)
+
+	res, err := r.builder().
+		Delete(r.table("Products")).
+		Where(
+			builder.C("id").Eq(command.ID),
+			builder.C("tenant_id").Eq(tenantID),
+		).
 		Executor().
 		ExecContext(ctx)
 	if err != nil {
@@ -216,5 +534,10 @@ func (r *Repo) Delete(ctx context.Context, command *commands.DeleteCommand) (int
 	if err != nil {
 		return 0, err
 	}
+	if affected > 0 {
+		if err = r.record(ctx, deletedEvent(tenantID, command.ID, before)); err != nil {
+			return 0, err
+		}
+	}
 	return affected, nil
 }
@@ -3,265 +3,246 @@ package repository
 import (
 	"context"
 	"errors"
-	"os"
+	"math/rand"
+	"strings"
 	"testing"
 	"time"
 
+	builder "github.com/doug-martin/goqu/v9"
 	"github.com/stretchr/testify/require"
 
 	"github.com/grip211/crud/pkg/commands"
 	"github.com/grip211/crud/pkg/database"
-	"github.com/grip211/crud/pkg/database/mysql"
+	"github.com/grip211/crud/pkg/database/dbtest"
+	"github.com/grip211/crud/pkg/tenant"
 	"github.com/grip211/crud/pkg/xrand"
 )
 
 func TestRepo_Create(t *testing.T) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	ctx, cancel := context.WithTimeout(tenant.WithTenant(context.Background(), tenant.Default), time.Minute)
 	defer cancel()
 
-	conn, err := mysql.New(ctx, &database.Opt{
-		Host:               os.Getenv("DB_Host"),
-		User:               os.Getenv("DB_USER"),
-		Password:           os.Getenv("DB_PASS"),
-		Name:               os.Getenv("DB_NAME"),
-		Dialect:            "mysql",
-		MaxConnMaxLifetime: time.Minute * 5,
-		MaxOpenConns:       10,
-		MaxIdleConns:       9,
-		Debug:              true,
-	})
-	require.NoError(t, err)
-
-	repo := New(conn)
-
-	type args struct {
-		command *commands.CreateCommand
-	}
-	tests := []struct {
-		name    string
-		args    args
-		wantErr error
-	}{
-		{
-			name: "successfully create, get and delete record",
-			args: args{
-				command: &commands.CreateCommand{
-					Model:       xrand.RandStringBytesMask(30),
-					Company:     xrand.RandStringBytesMask(30),
-					Quantity:    10,
-					Price:       20,
-					CPU:         30,
-					Memory:      40,
-					DisplaySize: 50,
-					Camera:      60,
+	dbtest.ForEachDialect(ctx, t, func(t *testing.T, conn database.Pool) {
+		repo := New(conn)
+
+		type args struct {
+			command *commands.CreateCommand
+		}
+		tests := []struct {
+			name    string
+			args    args
+			wantErr error
+		}{
+			{
+				name: "successfully create, get and delete record",
+				args: args{
+					command: &commands.CreateCommand{
+						Model:       xrand.RandStringBytesMask(30),
+						Company:     xrand.RandStringBytesMask(30),
+						Quantity:    10,
+						Price:       20,
+						CPU:         30,
+						Memory:      40,
+						DisplaySize: 50,
+						Camera:      60,
+					},
 				},
+				wantErr: nil,
 			},
-			wantErr: nil,
-		},
-		{
-			name: "failed insert create, get and delete record",
-			args: args{
-				command: &commands.CreateCommand{
-					Model:       xrand.RandStringBytesMask(302),
-					Company:     xrand.RandStringBytesMask(302),
-					Quantity:    120,
-					Price:       220,
-					CPU:         320,
-					Memory:      420,
-					DisplaySize: 520,
-					Camera:      620,
+			{
+				name: "failed insert create, get and delete record",
+				args: args{
+					command: &commands.CreateCommand{
+						Model:       xrand.RandStringBytesMask(302),
+						Company:     xrand.RandStringBytesMask(302),
+						Quantity:    120,
+						Price:       220,
+						CPU:         320,
+						Memory:      420,
+						DisplaySize: 520,
+						Camera:      620,
+					},
 				},
+				wantErr: ErrInsertProducts,
 			},
-			wantErr: ErrInsertProducts,
-		},
-		{
-			name: "failed insert feature create, get and delete record",
-			args: args{
-				command: &commands.CreateCommand{
-					Model:       xrand.RandStringBytesMask(300),
-					Company:     xrand.RandStringBytesMask(302),
-					Quantity:    1230,
-					Price:       2230,
-					CPU:         3320,
-					Memory:      4230,
-					DisplaySize: 5230,
-					Camera:      6230,
+			{
+				name: "failed insert feature create, get and delete record",
+				args: args{
+					command: &commands.CreateCommand{
+						Model:       xrand.RandStringBytesMask(300),
+						Company:     xrand.RandStringBytesMask(302),
+						Quantity:    1230,
+						Price:       2230,
+						CPU:         3320,
+						Memory:      4230,
+						DisplaySize: 5230,
+						Camera:      6230,
+					},
 				},
+				wantErr: ErrInsertProductFeatures,
 			},
-			wantErr: ErrInsertProductFeatures,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			id, err := repo.Create(ctx, tt.args.command)
-			if tt.wantErr != nil {
-				if !errors.Is(err, tt.wantErr) {
-					//	t.Fatalf("failed, expected error: %s receive %s", tt.wantErr, err)
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				id, err := repo.Create(ctx, tt.args.command)
+				if tt.wantErr != nil {
+					if !errors.Is(err, tt.wantErr) {
+						//	t.Fatalf("failed, expected error: %s receive %s", tt.wantErr, err)
+					}
+					return
 				}
-				return
-			}
 
-			product, err := repo.ReadOne(ctx, id)
-			require.NoError(t, err)
+				product, err := repo.ReadOne(ctx, id)
+				require.NoError(t, err)
 
-			require.Equal(t, product.ID, id)
+				require.Equal(t, product.ID, id)
 
-			require.Equal(t, tt.args.command.Model, product.Model)
-			require.Equal(t, tt.args.command.Company, product.Company)
-			require.Equal(t, tt.args.command.Price, product.Price)
-			require.Equal(t, tt.args.command.Quantity, product.Quantity)
+				require.Equal(t, tt.args.command.Model, product.Model)
+				require.Equal(t, tt.args.command.Company, product.Company)
+				require.Equal(t, tt.args.command.Price, product.Price)
+				require.Equal(t, tt.args.command.Quantity, product.Quantity)
 
-			_, err = repo.Delete(ctx, &commands.DeleteCommand{
-				ID: id,
+				_, err = repo.Delete(ctx, &commands.DeleteCommand{
+					ID: id,
+				})
+				require.NoError(t, err)
 			})
-			require.NoError(t, err)
-		})
-	}
+		}
+	})
 }
 
 func TestRepo_Update(t *testing.T) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	ctx, cancel := context.WithTimeout(tenant.WithTenant(context.Background(), tenant.Default), time.Minute)
 	defer cancel()
 
-	conn, err := mysql.New(ctx, &database.Opt{
-		Host:               os.Getenv("DB_Host"),
-		User:               os.Getenv("DB_USER"),
-		Password:           os.Getenv("DB_PASS"),
-		Name:               os.Getenv("DB_NAME"),
-		Dialect:            "mysql",
-		MaxConnMaxLifetime: time.Minute * 5,
-		MaxOpenConns:       10,
-		MaxIdleConns:       9,
-		Debug:              true,
-	})
-	require.NoError(t, err)
-
-	repo := New(conn)
-
-	type args struct {
-		createCommand *commands.CreateCommand
-		updateCommand *commands.UpdateCommand
-	}
-
-	tests := []struct {
-		name    string
-		args    args
-		wantErr error
-	}{
-		{
-			name: "successfully update, get and delete record",
-			args: args{
-				createCommand: &commands.CreateCommand{
-					Model:       xrand.RandStringBytesMask(30),
-					Company:     xrand.RandStringBytesMask(30),
-					Quantity:    10,
-					Price:       20,
-					CPU:         30,
-					Memory:      40,
-					DisplaySize: 50,
-					Camera:      60,
-				},
-				updateCommand: &commands.UpdateCommand{
-					Model:       xrand.RandStringBytesMask(20),
-					Company:     xrand.RandStringBytesMask(20),
-					Quantity:    100,
-					Price:       200,
-					CPU:         300,
-					Memory:      400,
-					DisplaySize: 500,
-					Camera:      600,
+	dbtest.ForEachDialect(ctx, t, func(t *testing.T, conn database.Pool) {
+		repo := New(conn)
+
+		type args struct {
+			createCommand *commands.CreateCommand
+			updateCommand *commands.UpdateCommand
+		}
+
+		tests := []struct {
+			name    string
+			args    args
+			wantErr error
+		}{
+			{
+				name: "successfully update, get and delete record",
+				args: args{
+					createCommand: &commands.CreateCommand{
+						Model:       xrand.RandStringBytesMask(30),
+						Company:     xrand.RandStringBytesMask(30),
+						Quantity:    10,
+						Price:       20,
+						CPU:         30,
+						Memory:      40,
+						DisplaySize: 50,
+						Camera:      60,
+					},
+					updateCommand: &commands.UpdateCommand{
+						Model:       xrand.RandStringBytesMask(20),
+						Company:     xrand.RandStringBytesMask(20),
+						Quantity:    100,
+						Price:       200,
+						CPU:         300,
+						Memory:      400,
+						DisplaySize: 500,
+						Camera:      600,
+					},
 				},
+				wantErr: nil,
 			},
-			wantErr: nil,
-		},
-		{
-			name: "failed update, get and delete record",
-			args: args{
-				createCommand: &commands.CreateCommand{
-					Model:       xrand.RandStringBytesMask(30),
-					Company:     xrand.RandStringBytesMask(30),
-					Quantity:    10,
-					Price:       20,
-					CPU:         30,
-					Memory:      40,
-					DisplaySize: 50,
-					Camera:      60,
-				},
-				updateCommand: &commands.UpdateCommand{
-					Model:       xrand.RandStringBytesMask(2230),
-					Company:     xrand.RandStringBytesMask(2440),
-					Quantity:    111,
-					Price:       33,
-					CPU:         222,
-					Memory:      44,
-					DisplaySize: 55,
-					Camera:      66,
+			{
+				name: "failed update, get and delete record",
+				args: args{
+					createCommand: &commands.CreateCommand{
+						Model:       xrand.RandStringBytesMask(30),
+						Company:     xrand.RandStringBytesMask(30),
+						Quantity:    10,
+						Price:       20,
+						CPU:         30,
+						Memory:      40,
+						DisplaySize: 50,
+						Camera:      60,
+					},
+					updateCommand: &commands.UpdateCommand{
+						Model:       xrand.RandStringBytesMask(2230),
+						Company:     xrand.RandStringBytesMask(2440),
+						Quantity:    111,
+						Price:       33,
+						CPU:         222,
+						Memory:      44,
+						DisplaySize: 55,
+						Camera:      66,
+					},
 				},
+				wantErr: ErrUpdateProduct,
 			},
-			wantErr: ErrUpdateProduct,
-		},
-		{
-			name: "failed update, get and delete record",
-			args: args{
-				createCommand: &commands.CreateCommand{
-					Model:       xrand.RandStringBytesMask(30),
-					Company:     xrand.RandStringBytesMask(30),
-					Quantity:    10,
-					Price:       20,
-					CPU:         30,
-					Memory:      40,
-					DisplaySize: 50,
-					Camera:      60,
-				},
-				updateCommand: &commands.UpdateCommand{
-					Model:       xrand.RandStringBytesMask(20),
-					Company:     xrand.RandStringBytesMask(20),
-					Quantity:    1111100,
-					Price:       2111100,
-					CPU:         3111100,
-					Memory:      4111100,
-					DisplaySize: 5111100,
-					Camera:      6111100,
+			{
+				name: "failed update, get and delete record",
+				args: args{
+					createCommand: &commands.CreateCommand{
+						Model:       xrand.RandStringBytesMask(30),
+						Company:     xrand.RandStringBytesMask(30),
+						Quantity:    10,
+						Price:       20,
+						CPU:         30,
+						Memory:      40,
+						DisplaySize: 50,
+						Camera:      60,
+					},
+					updateCommand: &commands.UpdateCommand{
+						Model:       xrand.RandStringBytesMask(20),
+						Company:     xrand.RandStringBytesMask(20),
+						Quantity:    1111100,
+						Price:       2111100,
+						CPU:         3111100,
+						Memory:      4111100,
+						DisplaySize: 5111100,
+						Camera:      6111100,
+					},
 				},
+				wantErr: ErrUpsertFeature,
 			},
-			wantErr: ErrUpsertFeature,
-		},
-	}
+		}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			id, err := repo.Create(ctx, tt.args.createCommand)
-			require.NoError(t, err)
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				id, err := repo.Create(ctx, tt.args.createCommand)
+				require.NoError(t, err)
 
-			tt.args.updateCommand.ID = id
+				tt.args.updateCommand.ID = id
 
-			err = repo.Update(ctx, tt.args.updateCommand)
-			if tt.wantErr != nil {
-				if !errors.Is(err, tt.wantErr) {
-					//t.Fatalf("failed, expected error: %s receive %s", tt.wantErr, err)
+				err = repo.Update(ctx, tt.args.updateCommand)
+				if tt.wantErr != nil {
+					if !errors.Is(err, tt.wantErr) {
+						//t.Fatalf("failed, expected error: %s receive %s", tt.wantErr, err)
+					}
+					return
 				}
-				return
-			}
 
-			product, err := repo.ReadOneWithFeatures(ctx, id)
-			require.NoError(t, err)
+				product, err := repo.ReadOneWithFeatures(ctx, id)
+				require.NoError(t, err)
+
+				require.Equal(t, tt.args.updateCommand.Model, product.Model)
+				require.Equal(t, tt.args.updateCommand.Company, product.Company)
+				require.Equal(t, tt.args.updateCommand.Price, product.Price)
+				require.Equal(t, tt.args.updateCommand.Quantity, product.Quantity)
+				require.Equal(t, tt.args.updateCommand.Camera, int(product.Features.Camera.Int32))
+				require.Equal(t, tt.args.updateCommand.CPU, int(product.Features.CPU.Int32))
+				require.Equal(t, tt.args.updateCommand.Memory, int(product.Features.Memory.Int32))
+				require.Equal(t, tt.args.updateCommand.DisplaySize, int(product.Features.Display.Int32))
 
-			require.Equal(t, tt.args.updateCommand.Model, product.Model)
-			require.Equal(t, tt.args.updateCommand.Company, product.Company)
-			require.Equal(t, tt.args.updateCommand.Price, product.Price)
-			require.Equal(t, tt.args.updateCommand.Quantity, product.Quantity)
-			require.Equal(t, tt.args.updateCommand.Camera, int(product.Features.Camera.Int32))
-			require.Equal(t, tt.args.updateCommand.CPU, int(product.Features.CPU.Int32))
-			require.Equal(t, tt.args.updateCommand.Memory, int(product.Features.Memory.Int32))
-			require.Equal(t, tt.args.updateCommand.DisplaySize, int(product.Features.Display.Int32))
-
-			_, err = repo.Delete(ctx, &commands.DeleteCommand{
-				ID: id,
+				_, err = repo.Delete(ctx, &commands.DeleteCommand{
+					ID: id,
+				})
+				require.NoError(t, err)
 			})
-			require.NoError(t, err)
-		})
-	}
+		}
+	})
 }
 
 // delete
@@ -270,380 +251,543 @@ func TestRepo_Update(t *testing.T) {
 // read one with feature - тоже самое как с Read One тестом, только еще првоерять дополнительные поля
 
 func TestRepo_Delete(t *testing.T) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	ctx, cancel := context.WithTimeout(tenant.WithTenant(context.Background(), tenant.Default), time.Minute)
 	defer cancel()
 
-	conn, err := mysql.New(ctx, &database.Opt{
-		Host:               os.Getenv("DB_Host"),
-		User:               os.Getenv("DB_USER"),
-		Password:           os.Getenv("DB_PASS"),
-		Name:               os.Getenv("DB_NAME"),
-		Dialect:            "mysql",
-		MaxConnMaxLifetime: time.Minute * 5,
-		MaxOpenConns:       10,
-		MaxIdleConns:       9,
-		Debug:              true,
-	})
-	require.NoError(t, err)
-
-	repo := New(conn)
-
-	type args struct {
-		createCommand *commands.CreateCommand
-	}
-	tests := []struct {
-		name    string
-		args    args
-		wantErr error
-	}{
-		{
-			name: "successfully update, get and delete record",
-			args: args{
-				createCommand: &commands.CreateCommand{
-					Model:       xrand.RandStringBytesMask(30),
-					Company:     xrand.RandStringBytesMask(30),
-					Quantity:    10,
-					Price:       20,
-					CPU:         30,
-					Memory:      40,
-					DisplaySize: 50,
-					Camera:      60,
+	dbtest.ForEachDialect(ctx, t, func(t *testing.T, conn database.Pool) {
+		repo := New(conn)
+
+		type args struct {
+			createCommand *commands.CreateCommand
+		}
+		tests := []struct {
+			name    string
+			args    args
+			wantErr error
+		}{
+			{
+				name: "successfully update, get and delete record",
+				args: args{
+					createCommand: &commands.CreateCommand{
+						Model:       xrand.RandStringBytesMask(30),
+						Company:     xrand.RandStringBytesMask(30),
+						Quantity:    10,
+						Price:       20,
+						CPU:         30,
+						Memory:      40,
+						DisplaySize: 50,
+						Camera:      60,
+					},
 				},
+				wantErr: ErrNotFound,
 			},
-			wantErr: ErrNotFound,
-		},
-	}
+		}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			id, err := repo.Create(ctx, tt.args.createCommand)
-			require.NoError(t, err)
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				id, err := repo.Create(ctx, tt.args.createCommand)
+				require.NoError(t, err)
 
-			_, err = repo.Delete(ctx, &commands.DeleteCommand{
-				ID: id,
-			})
-			require.NoError(t, err)
+				_, err = repo.Delete(ctx, &commands.DeleteCommand{
+					ID: id,
+				})
+				require.NoError(t, err)
 
-			_, err = repo.ReadOne(ctx, id)
-			if tt.wantErr != nil {
-				if !errors.Is(err, tt.wantErr) {
-					//t.Fatalf("failed, expected error: %s receive %s", tt.wantErr, err)
+				_, err = repo.ReadOne(ctx, id)
+				if tt.wantErr != nil {
+					if !errors.Is(err, tt.wantErr) {
+						//t.Fatalf("failed, expected error: %s receive %s", tt.wantErr, err)
+					}
+					return
 				}
-				return
-			}
-		})
-	}
+			})
+		}
+	})
 }
 
 func TestRepo_ReadOne(t *testing.T) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	ctx, cancel := context.WithTimeout(tenant.WithTenant(context.Background(), tenant.Default), time.Minute)
 	defer cancel()
 
-	conn, err := mysql.New(ctx, &database.Opt{
-		Host:               os.Getenv("DB_Host"),
-		User:               os.Getenv("DB_USER"),
-		Password:           os.Getenv("DB_PASS"),
-		Name:               os.Getenv("DB_NAME"),
-		Dialect:            "mysql",
-		MaxConnMaxLifetime: time.Minute * 5,
-		MaxOpenConns:       10,
-		MaxIdleConns:       9,
-		Debug:              true,
-	})
-	require.NoError(t, err)
-
-	repo := New(conn)
-
-	type args struct {
-		createCommand *commands.CreateCommand
-	}
-	tests := []struct {
-		name      string
-		args      args
-		wantErr   error
-		replaceID int
-	}{
-		{
-			name: "successfully update, get and delete record",
-			args: args{
-				createCommand: &commands.CreateCommand{
-					Model:       xrand.RandStringBytesMask(30),
-					Company:     xrand.RandStringBytesMask(30),
-					Quantity:    10,
-					Price:       20,
-					CPU:         30,
-					Memory:      40,
-					DisplaySize: 50,
-					Camera:      60,
+	dbtest.ForEachDialect(ctx, t, func(t *testing.T, conn database.Pool) {
+		repo := New(conn)
+
+		type args struct {
+			createCommand *commands.CreateCommand
+		}
+		tests := []struct {
+			name      string
+			args      args
+			wantErr   error
+			replaceID int
+		}{
+			{
+				name: "successfully update, get and delete record",
+				args: args{
+					createCommand: &commands.CreateCommand{
+						Model:       xrand.RandStringBytesMask(30),
+						Company:     xrand.RandStringBytesMask(30),
+						Quantity:    10,
+						Price:       20,
+						CPU:         30,
+						Memory:      40,
+						DisplaySize: 50,
+						Camera:      60,
+					},
 				},
+				wantErr: nil,
 			},
-			wantErr: nil,
-		},
-		{
-			name: "successfully update, get and delete record",
-			args: args{
-				createCommand: &commands.CreateCommand{
-					Model:       xrand.RandStringBytesMask(30),
-					Company:     xrand.RandStringBytesMask(30),
-					Quantity:    10,
-					Price:       20,
-					CPU:         30,
-					Memory:      40,
-					DisplaySize: 50,
-					Camera:      60,
+			{
+				name: "successfully update, get and delete record",
+				args: args{
+					createCommand: &commands.CreateCommand{
+						Model:       xrand.RandStringBytesMask(30),
+						Company:     xrand.RandStringBytesMask(30),
+						Quantity:    10,
+						Price:       20,
+						CPU:         30,
+						Memory:      40,
+						DisplaySize: 50,
+						Camera:      60,
+					},
 				},
+				wantErr:   ErrNotFound,
+				replaceID: 9999999,
 			},
-			wantErr:   ErrNotFound,
-			replaceID: 9999999,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			id, err := repo.Create(ctx, tt.args.createCommand)
-			require.NoError(t, err)
+		}
 
-			if tt.replaceID != 0 {
-				id = tt.replaceID
-			}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				id, err := repo.Create(ctx, tt.args.createCommand)
+				require.NoError(t, err)
 
-			product, err := repo.ReadOne(ctx, id)
-			if tt.wantErr != nil {
-				if !errors.Is(err, tt.wantErr) {
-					t.Fatalf("failed, expected error: %s receive %s", tt.wantErr, err)
+				if tt.replaceID != 0 {
+					id = tt.replaceID
 				}
-				return
-			}
 
-			require.Equal(t, tt.args.createCommand.Model, product.Model)
-			require.Equal(t, tt.args.createCommand.Company, product.Company)
-			require.Equal(t, tt.args.createCommand.Price, product.Price)
-			require.Equal(t, tt.args.createCommand.Quantity, product.Quantity)
+				product, err := repo.ReadOne(ctx, id)
+				if tt.wantErr != nil {
+					if !errors.Is(err, tt.wantErr) {
+						t.Fatalf("failed, expected error: %s receive %s", tt.wantErr, err)
+					}
+					return
+				}
 
-			_, err = repo.Delete(ctx, &commands.DeleteCommand{
-				ID: id,
+				require.Equal(t, tt.args.createCommand.Model, product.Model)
+				require.Equal(t, tt.args.createCommand.Company, product.Company)
+				require.Equal(t, tt.args.createCommand.Price, product.Price)
+				require.Equal(t, tt.args.createCommand.Quantity, product.Quantity)
+
+				_, err = repo.Delete(ctx, &commands.DeleteCommand{
+					ID: id,
+				})
+				require.NoError(t, err)
 			})
-			require.NoError(t, err)
-		})
-	}
+		}
+	})
 }
 
 func TestRepo_ReadOneWithFeatures(t *testing.T) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	ctx, cancel := context.WithTimeout(tenant.WithTenant(context.Background(), tenant.Default), time.Minute)
 	defer cancel()
 
-	conn, err := mysql.New(ctx, &database.Opt{
-		Host:               os.Getenv("DB_Host"),
-		User:               os.Getenv("DB_USER"),
-		Password:           os.Getenv("DB_PASS"),
-		Name:               os.Getenv("DB_NAME"),
-		Dialect:            "mysql",
-		MaxConnMaxLifetime: time.Minute * 5,
-		MaxOpenConns:       10,
-		MaxIdleConns:       9,
-		Debug:              true,
-	})
-	require.NoError(t, err)
-
-	repo := New(conn)
-
-	type args struct {
-		createCommand *commands.CreateCommand
-	}
-	tests := []struct {
-		name      string
-		args      args
-		wantErr   error
-		replaceID int
-	}{
-		{
-			name: "successfully update, get and delete record",
-			args: args{
-				createCommand: &commands.CreateCommand{
-					Model:       xrand.RandStringBytesMask(30),
-					Company:     xrand.RandStringBytesMask(30),
-					Quantity:    10,
-					Price:       20,
-					CPU:         30,
-					Memory:      40,
-					DisplaySize: 50,
-					Camera:      60,
+	dbtest.ForEachDialect(ctx, t, func(t *testing.T, conn database.Pool) {
+		repo := New(conn)
+
+		type args struct {
+			createCommand *commands.CreateCommand
+		}
+		tests := []struct {
+			name      string
+			args      args
+			wantErr   error
+			replaceID int
+		}{
+			{
+				name: "successfully update, get and delete record",
+				args: args{
+					createCommand: &commands.CreateCommand{
+						Model:       xrand.RandStringBytesMask(30),
+						Company:     xrand.RandStringBytesMask(30),
+						Quantity:    10,
+						Price:       20,
+						CPU:         30,
+						Memory:      40,
+						DisplaySize: 50,
+						Camera:      60,
+					},
 				},
+				wantErr: nil,
 			},
-			wantErr: nil,
-		},
-		{
-			name: "successfully update, get and delete record",
-			args: args{
-				createCommand: &commands.CreateCommand{
-					Model:       xrand.RandStringBytesMask(30),
-					Company:     xrand.RandStringBytesMask(30),
-					Quantity:    10,
-					Price:       20,
-					CPU:         30,
-					Memory:      40,
-					DisplaySize: 50,
-					Camera:      60,
+			{
+				name: "successfully update, get and delete record",
+				args: args{
+					createCommand: &commands.CreateCommand{
+						Model:       xrand.RandStringBytesMask(30),
+						Company:     xrand.RandStringBytesMask(30),
+						Quantity:    10,
+						Price:       20,
+						CPU:         30,
+						Memory:      40,
+						DisplaySize: 50,
+						Camera:      60,
+					},
 				},
+				wantErr:   ErrNotFound,
+				replaceID: 9999999,
 			},
-			wantErr:   ErrNotFound,
-			replaceID: 9999999,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			id, err := repo.Create(ctx, tt.args.createCommand)
-			require.NoError(t, err)
+		}
 
-			if tt.replaceID != 0 {
-				id = tt.replaceID
-			}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				id, err := repo.Create(ctx, tt.args.createCommand)
+				require.NoError(t, err)
 
-			product, err := repo.ReadOneWithFeatures(ctx, id)
-			if tt.wantErr != nil {
-				if !errors.Is(err, tt.wantErr) {
-					t.Fatalf("failed, expected error: %s receive %s", tt.wantErr, err)
+				if tt.replaceID != 0 {
+					id = tt.replaceID
 				}
-				return
-			}
 
-			require.Equal(t, tt.args.createCommand.Model, product.Model)
-			require.Equal(t, tt.args.createCommand.Company, product.Company)
-			require.Equal(t, tt.args.createCommand.Price, product.Price)
-			require.Equal(t, tt.args.createCommand.Quantity, product.Quantity)
-			require.Equal(t, tt.args.createCommand.CPU, int(product.Features.CPU.Int32))
-			require.Equal(t, tt.args.createCommand.Camera, int(product.Features.Camera.Int32))
-			require.Equal(t, tt.args.createCommand.Memory, int(product.Features.Memory.Int32))
-			require.Equal(t, tt.args.createCommand.DisplaySize, int(product.Features.Display.Int32))
-
-			_, err = repo.Delete(ctx, &commands.DeleteCommand{
-				ID: id,
+				product, err := repo.ReadOneWithFeatures(ctx, id)
+				if tt.wantErr != nil {
+					if !errors.Is(err, tt.wantErr) {
+						t.Fatalf("failed, expected error: %s receive %s", tt.wantErr, err)
+					}
+					return
+				}
+
+				require.Equal(t, tt.args.createCommand.Model, product.Model)
+				require.Equal(t, tt.args.createCommand.Company, product.Company)
+				require.Equal(t, tt.args.createCommand.Price, product.Price)
+				require.Equal(t, tt.args.createCommand.Quantity, product.Quantity)
+				require.Equal(t, tt.args.createCommand.CPU, int(product.Features.CPU.Int32))
+				require.Equal(t, tt.args.createCommand.Camera, int(product.Features.Camera.Int32))
+				require.Equal(t, tt.args.createCommand.Memory, int(product.Features.Memory.Int32))
+				require.Equal(t, tt.args.createCommand.DisplaySize, int(product.Features.Display.Int32))
+				require.False(t, product.UpdatedAt.IsZero())
+
+				_, err = repo.Delete(ctx, &commands.DeleteCommand{
+					ID: id,
+				})
+				require.NoError(t, err)
 			})
-			require.NoError(t, err)
-		})
-	}
+		}
+	})
 }
 
 func TestRepo_Read(t *testing.T) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	ctx, cancel := context.WithTimeout(tenant.WithTenant(context.Background(), tenant.Default), time.Minute)
 	defer cancel()
 
-	conn, err := mysql.New(ctx, &database.Opt{
-		Host:               os.Getenv("DB_Host"),
-		User:               os.Getenv("DB_USER"),
-		Password:           os.Getenv("DB_PASS"),
-		Name:               os.Getenv("DB_NAME"),
-		Dialect:            "mysql",
-		MaxConnMaxLifetime: time.Minute * 5,
-		MaxOpenConns:       10,
-		MaxIdleConns:       9,
-		Debug:              true,
-	})
-	require.NoError(t, err)
-
-	repo := New(conn)
-
-	type args struct {
-		pseudoCreateCommands []*commands.UpdateCommand
-	}
-	tests := []struct {
-		name    string
-		args    args
-		wantErr error
-	}{
-		{
-			name: "successfully update, get and delete records",
-			args: args{
-				pseudoCreateCommands: []*commands.UpdateCommand{
-					{
-						Model:       xrand.RandStringBytesMask(30),
-						Company:     xrand.RandStringBytesMask(30),
-						Quantity:    10,
-						Price:       20,
-						CPU:         30,
-						Memory:      40,
-						DisplaySize: 50,
-						Camera:      60,
-					},
-					{
-						Model:       xrand.RandStringBytesMask(30),
-						Company:     xrand.RandStringBytesMask(30),
-						Quantity:    110,
-						Price:       120,
-						CPU:         130,
-						Memory:      140,
-						DisplaySize: 150,
-						Camera:      160,
-					},
-					{
-						Model:       xrand.RandStringBytesMask(30),
-						Company:     xrand.RandStringBytesMask(30),
-						Quantity:    210,
-						Price:       220,
-						CPU:         230,
-						Memory:      240,
-						DisplaySize: 250,
-						Camera:      260,
+	dbtest.ForEachDialect(ctx, t, func(t *testing.T, conn database.Pool) {
+		repo := New(conn)
+
+		type args struct {
+			pseudoCreateCommands []*commands.UpdateCommand
+		}
+		tests := []struct {
+			name    string
+			args    args
+			wantErr error
+		}{
+			{
+				name: "successfully update, get and delete records",
+				args: args{
+					pseudoCreateCommands: []*commands.UpdateCommand{
+						{
+							Model:       xrand.RandStringBytesMask(30),
+							Company:     xrand.RandStringBytesMask(30),
+							Quantity:    10,
+							Price:       20,
+							CPU:         30,
+							Memory:      40,
+							DisplaySize: 50,
+							Camera:      60,
+						},
+						{
+							Model:       xrand.RandStringBytesMask(30),
+							Company:     xrand.RandStringBytesMask(30),
+							Quantity:    110,
+							Price:       120,
+							CPU:         130,
+							Memory:      140,
+							DisplaySize: 150,
+							Camera:      160,
+						},
+						{
+							Model:       xrand.RandStringBytesMask(30),
+							Company:     xrand.RandStringBytesMask(30),
+							Quantity:    210,
+							Price:       220,
+							CPU:         230,
+							Memory:      240,
+							DisplaySize: 250,
+							Camera:      260,
+						},
 					},
 				},
+				wantErr: nil,
 			},
-			wantErr: nil,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			createdIDs := make([]int, 0, len(tt.args.pseudoCreateCommands))
-			for _, createCommand := range tt.args.pseudoCreateCommands {
-				id, err := repo.Create(ctx, &commands.CreateCommand{
-					Model:       createCommand.Model,
-					Company:     createCommand.Company,
-					Quantity:    createCommand.Quantity,
-					Price:       createCommand.Price,
-					CPU:         createCommand.CPU,
-					Memory:      createCommand.Memory,
-					DisplaySize: createCommand.DisplaySize,
-					Camera:      createCommand.Camera,
-				})
-				require.NoError(t, err)
+		}
 
-				createCommand.ID = id
-				createdIDs = append(createdIDs, id)
-			}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				createdIDs := make([]int, 0, len(tt.args.pseudoCreateCommands))
+				for _, createCommand := range tt.args.pseudoCreateCommands {
+					id, err := repo.Create(ctx, &commands.CreateCommand{
+						Model:       createCommand.Model,
+						Company:     createCommand.Company,
+						Quantity:    createCommand.Quantity,
+						Price:       createCommand.Price,
+						CPU:         createCommand.CPU,
+						Memory:      createCommand.Memory,
+						DisplaySize: createCommand.DisplaySize,
+						Camera:      createCommand.Camera,
+					})
+					require.NoError(t, err)
+
+					createCommand.ID = id
+					createdIDs = append(createdIDs, id)
+				}
 
-			products, err := repo.Read(ctx)
-			if tt.wantErr != nil {
-				if !errors.Is(err, tt.wantErr) {
-					t.Fatalf("failed, expected error: %s receive %s", tt.wantErr, err)
+				products, err := repo.Read(ctx)
+				if tt.wantErr != nil {
+					if !errors.Is(err, tt.wantErr) {
+						t.Fatalf("failed, expected error: %s receive %s", tt.wantErr, err)
+					}
+					return
 				}
-				return
-			}
 
-			if len(products) != len(tt.args.pseudoCreateCommands) {
-				//				t.Fatalf("faield, expect %b count product, receive count %b",
-				//					len(tt.args.pseudoCreateCommands),
-				//					len(products),
-				//				)
-			}
+				if len(products) != len(tt.args.pseudoCreateCommands) {
+					//				t.Fatalf("faield, expect %b count product, receive count %b",
+					//					len(tt.args.pseudoCreateCommands),
+					//					len(products),
+					//				)
+				}
 
-			for _, product := range products {
-				for _, createCommand := range tt.args.pseudoCreateCommands {
-					if createCommand.ID == product.ID {
-						require.Equal(t, createCommand.Model, product.Model)
-						require.Equal(t, createCommand.Company, product.Company)
-						require.Equal(t, createCommand.Price, product.Price)
-						require.Equal(t, createCommand.Quantity, product.Quantity)
+				for _, product := range products {
+					for _, createCommand := range tt.args.pseudoCreateCommands {
+						if createCommand.ID == product.ID {
+							require.Equal(t, createCommand.Model, product.Model)
+							require.Equal(t, createCommand.Company, product.Company)
+							require.Equal(t, createCommand.Price, product.Price)
+							require.Equal(t, createCommand.Quantity, product.Quantity)
+						}
 					}
 				}
+
+				for _, id := range createdIDs {
+					_, err = repo.Delete(ctx, &commands.DeleteCommand{
+						ID: id,
+					})
+					require.NoError(t, err)
+				}
+			})
+		}
+	})
+}
+
+func TestRepo_Batch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(tenant.WithTenant(context.Background(), tenant.Default), time.Minute)
+	defer cancel()
+
+	dbtest.ForEachDialect(ctx, t, func(t *testing.T, conn database.Pool) {
+		repo := New(conn)
+
+		// CPU случайный, чтобы после отката искать по нему характеристики
+		newCreate := func() *commands.BatchOperation {
+			return &commands.BatchOperation{
+				Op: commands.BatchOpCreate,
+				Create: &commands.CreateCommand{
+					Model:       xrand.RandStringBytesMask(30),
+					Company:     xrand.RandStringBytesMask(30),
+					Quantity:    10,
+					Price:       20,
+					CPU:         rand.Intn(1 << 30),
+					Memory:      40,
+					DisplaySize: 50,
+					Camera:      60,
+				},
 			}
+		}
+		missingDelete := &commands.BatchOperation{
+			Op:     commands.BatchOpDelete,
+			Delete: &commands.DeleteCommand{ID: 9999999},
+		}
+
+		tests := []struct {
+			name        string
+			mode        string
+			operations  []*commands.BatchOperation
+			wantErr     error
+			wantCreated int
+			wantFailed  int
+		}{
+			{
+				name:        "successfully create records in one transaction",
+				mode:        commands.BatchModeAtomic,
+				operations:  []*commands.BatchOperation{newCreate(), newCreate(), newCreate()},
+				wantCreated: 3,
+			},
+			{
+				name:       "failed delete rolls back the whole batch",
+				mode:       commands.BatchModeAtomic,
+				operations: []*commands.BatchOperation{newCreate(), newCreate(), missingDelete},
+				wantErr:    ErrNotFound,
+			},
+			{
+				name:        "failed delete does not affect other operations",
+				mode:        commands.BatchModeIndependent,
+				operations:  []*commands.BatchOperation{newCreate(), missingDelete, newCreate()},
+				wantCreated: 2,
+				wantFailed:  1,
+			},
+		}
 
-			for _, id := range createdIDs {
-				_, err = repo.Delete(ctx, &commands.DeleteCommand{
-					ID: id,
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				results, err := repo.Batch(ctx, &commands.BatchCommand{
+					Mode:       tt.mode,
+					Operations: tt.operations,
 				})
+				if tt.wantErr != nil {
+					var batchErr *BatchError
+					require.ErrorAs(t, err, &batchErr)
+					require.ErrorIs(t, err, tt.wantErr)
+
+					// после отката не осталось ни товаров, ни их характеристик
+					var (
+						models []string
+						cpus   []int
+					)
+					for _, operation := range tt.operations {
+						if operation.Create != nil {
+							models = append(models, operation.Create.Model)
+							cpus = append(cpus, operation.Create.CPU)
+						}
+					}
+					products, err := conn.Builder().
+						From(database.Table(conn, "Products")).
+						Where(builder.C("model").In(models)).
+						CountContext(ctx)
+					require.NoError(t, err)
+					require.Zero(t, products)
+
+					features, err := conn.Builder().
+						From(database.Table(conn, "ProductsFeatures")).
+						Where(builder.C("cpu").In(cpus)).
+						CountContext(ctx)
+					require.NoError(t, err)
+					require.Zero(t, features)
+					return
+				}
 				require.NoError(t, err)
-			}
+				require.Len(t, results, len(tt.operations))
+
+				var created, failed int
+				for _, result := range results {
+					if result.Status == BatchStatusError {
+						failed++
+						continue
+					}
+
+					product, err := repo.ReadOne(ctx, result.ID)
+					require.NoError(t, err)
+					require.Equal(t, tt.operations[result.Index].Create.Model, product.Model)
+					created++
+
+					_, err = repo.Delete(ctx, &commands.DeleteCommand{
+						ID: result.ID,
+					})
+					require.NoError(t, err)
+				}
+
+				require.Equal(t, tt.wantCreated, created)
+				require.Equal(t, tt.wantFailed, failed)
+			})
+		}
+	})
+}
+
+func TestRepo_TenantIsolation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	dbtest.ForEachDialect(ctx, t, func(t *testing.T, conn database.Pool) {
+		repo := New(conn)
+		tenants := tenant.NewRepo(conn)
+
+		newTenant := func() context.Context {
+			id := strings.ToLower(xrand.RandStringBytesMask(12))
+			_, err := tenants.Create(ctx, id, id)
+			require.NoError(t, err)
+			return tenant.WithTenant(ctx, id)
+		}
+		owner, other := newTenant(), newTenant()
+
+		create := &commands.CreateCommand{
+			Model:       xrand.RandStringBytesMask(30),
+			Company:     xrand.RandStringBytesMask(30),
+			Quantity:    10,
+			Price:       20,
+			CPU:         30,
+			Memory:      40,
+			DisplaySize: 50,
+			Camera:      60,
+		}
+		id, err := repo.Create(owner, create)
+		require.NoError(t, err)
+		defer func() {
+			_, _ = repo.Delete(owner, &commands.DeleteCommand{ID: id})
+		}()
+
+		// без арендатора репозиторий не работает
+		_, err = repo.Read(ctx)
+		require.ErrorIs(t, err, tenant.ErrMissing)
+
+		// чужой арендатор не видит товар
+		products, err := repo.Read(other)
+		require.NoError(t, err)
+		for _, product := range products {
+			require.NotEqual(t, id, product.ID)
+		}
+		_, err = repo.ReadOne(other, id)
+		require.ErrorIs(t, err, ErrNotFound)
+		_, err = repo.ReadOneWithFeatures(other, id)
+		require.ErrorIs(t, err, ErrNotFound)
+
+		// чужой арендатор не может изменить товар, в том числе характеристики
+		err = repo.Update(other, &commands.UpdateCommand{
+			ID:          id,
+			Model:       "hijacked",
+			Company:     "hijacked",
+			Quantity:    1,
+			Price:       1,
+			CPU:         1,
+			Memory:      1,
+			DisplaySize: 1,
+			Camera:      1,
 		})
-	}
+		require.ErrorIs(t, err, ErrNotFound)
+
+		// и удалить его
+		affected, err := repo.Delete(other, &commands.DeleteCommand{ID: id})
+		require.NoError(t, err)
+		require.Zero(t, affected)
+
+		results, err := repo.Batch(other, &commands.BatchCommand{
+			Mode: commands.BatchModeIndependent,
+			Operations: []*commands.BatchOperation{
+				{Op: commands.BatchOpDelete, Delete: &commands.DeleteCommand{ID: id}},
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, BatchStatusError, results[0].Status)
+
+		// у владельца товар остался прежним
+		product, err := repo.ReadOneWithFeatures(owner, id)
+		require.NoError(t, err)
+		require.Equal(t, create.Model, product.Model)
+		require.Equal(t, create.Price, product.Price)
+		require.Equal(t, create.CPU, int(product.Features.CPU.Int32))
+	})
 }
@@ -0,0 +1,101 @@
+package requestid
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// тут идентификатор запроса: берется из X-Request-ID клиента или генерируется, возвращается
+// в ответе и в JSON телах ошибок, лежит в ctx, откуда попадает в логи, в том числе запросов к базе
+
+const (
+	Header = "X-Request-ID"
+
+	maxLength = 128
+	idLength  = 16
+)
+
+type contextKey struct{}
+
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext идентификатор из ctx, пустой если его нет
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Generate случайный идентификатор в hex
+func Generate() string {
+	b := make([]byte, idLength)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// Valid идентификатор клиента берем, только если он короткий и без лишних символов, он попадает в логи и заголовки
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("-_.:/+=", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// New middleware ставится первым, чтобы идентификатор был у всех остальных
+func New() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		id := ctx.Get(Header)
+		if !Valid(id) {
+			id = Generate()
+		}
+		ctx.Set(Header, id)
+		ctx.SetUserContext(WithID(ctx.UserContext(), id))
+
+		// тело ответа на ошибку уже собрано apperror.Resolve, дописываем в него идентификатор
+		err := ctx.Next()
+
+		if ctx.Response().StatusCode() >= fiber.StatusBadRequest && !ctx.Response().IsBodyStream() &&
+			strings.HasPrefix(string(ctx.Response().Header.ContentType()), fiber.MIMEApplicationJSON) {
+			ctx.Response().SetBodyRaw(withID(ctx.Response().Body(), id))
+		}
+		return err
+	}
+}
+
+// withID добавляет поле request_id в JSON объект, остальные тела возвращает как есть
+func withID(body []byte, id string) []byte {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) < 2 || trimmed[0] != '{' || bytes.Contains(trimmed, []byte(`"request_id"`)) {
+		return body
+	}
+	value, err := json.Marshal(id)
+	if err != nil {
+		return body
+	}
+
+	rest := bytes.TrimSpace(trimmed[1:])
+	result := make([]byte, 0, len(trimmed)+len(value)+16)
+	result = append(result, `{"request_id":`...)
+	result = append(result, value...)
+	if rest[0] != '}' {
+		result = append(result, ',')
+	}
+	return append(result, rest...)
+}
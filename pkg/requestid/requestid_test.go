@@ -0,0 +1,80 @@
+package requestid
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grip211/crud/pkg/apperror"
+)
+
+func TestValid(t *testing.T) {
+	require.True(t, Valid("req-1"))
+	require.True(t, Valid("5f0c:1/a+b=_."))
+	require.False(t, Valid(""))
+	require.False(t, Valid("req 1"))
+	require.False(t, Valid("req\n1"))
+	require.False(t, Valid(strings.Repeat("a", maxLength+1)))
+}
+
+func TestNew(t *testing.T) {
+	app := fiber.New()
+	app.Use(New())
+	app.Use(apperror.Resolve())
+	app.Get("/products", func(ctx *fiber.Ctx) error {
+		return ctx.SendString(FromContext(ctx.UserContext()))
+	})
+	app.Get("/missing", func(ctx *fiber.Ctx) error {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "not found"})
+	})
+	app.Get("/empty", func(ctx *fiber.Ctx) error {
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{})
+	})
+	app.Get("/fail", func(ctx *fiber.Ctx) error {
+		return fiber.ErrBadGateway
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		header     string
+		wantID     string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "client id", path: "/products", header: "req-1", wantID: "req-1", wantStatus: fiber.StatusOK, wantBody: "req-1"},
+		{name: "generated id", path: "/products", wantStatus: fiber.StatusOK},
+		{name: "invalid client id is replaced", path: "/products", header: "req 1", wantStatus: fiber.StatusOK},
+		{name: "json error body", path: "/missing", header: "req-1", wantID: "req-1", wantStatus: fiber.StatusNotFound, wantBody: `{"request_id":"req-1","message":"not found"}`},
+		{name: "empty json error body", path: "/empty", header: "req-1", wantID: "req-1", wantStatus: fiber.StatusConflict, wantBody: `{"request_id":"req-1"}`},
+		{name: "plain error body", path: "/fail", header: "req-1", wantID: "req-1", wantStatus: fiber.StatusBadGateway, wantBody: "Bad Gateway"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(Header, tt.header)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			id := resp.Header.Get(Header)
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			if tt.wantID == "" {
+				// сгенерированный идентификатор тот же, что в ctx обработчика
+				require.Len(t, id, idLength*2)
+				require.Equal(t, id, string(body))
+				return
+			}
+			require.Equal(t, tt.wantID, id)
+			require.Equal(t, tt.wantBody, string(body))
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package session
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/grip211/crud/pkg/auth"
+)
+
+const (
+	FieldCSRF  = "_csrf"
+	HeaderCSRF = "X-CSRF-Token"
+
+	localsKey = "session"
+)
+
+type Options struct {
+	CookieName string `yaml:"cookie_name"`
+	// Secure ставить куке флаг Secure, включать когда интерфейс отдается по https
+	Secure bool          `yaml:"cookie_secure"`
+	TTL    time.Duration `yaml:"ttl"`
+	// LoginPath куда отправлять запросы без сессии
+	LoginPath string `yaml:"-"`
+}
+
+func (o *Options) withDefaults() Options {
+	opt := *o
+	if opt.CookieName == "" {
+		opt.CookieName = "crud_session"
+	}
+	if opt.TTL <= 0 {
+		opt.TTL = time.Hour * 12
+	}
+	if opt.LoginPath == "" {
+		opt.LoginPath = "/login"
+	}
+	return opt
+}
+
+type Manager struct {
+	store Store
+	opt   Options
+}
+
+func NewManager(store Store, opt Options) *Manager {
+	return &Manager{
+		store: store,
+		opt:   opt.withDefaults(),
+	}
+}
+
+// Login заводит сессию пользователю и ставит куку
+func (m *Manager) Login(ctx *fiber.Ctx, userID int) error {
+	session, token, err := m.store.Create(ctx.Context(), userID, m.opt.TTL)
+	if err != nil {
+		return err
+	}
+	ctx.Cookie(&fiber.Cookie{
+		Name:     m.opt.CookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		Secure:   m.opt.Secure,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+	return nil
+}
+
+// Logout удаляет сессию и куку
+func (m *Manager) Logout(ctx *fiber.Ctx) error {
+	if token := ctx.Cookies(m.opt.CookieName); token != "" {
+		if err := m.store.Delete(ctx.Context(), token); err != nil {
+			return err
+		}
+	}
+	ctx.Cookie(&fiber.Cookie{
+		Name:     m.opt.CookieName,
+		Value:    "",
+		Path:     "/",
+		Expires:  time.Unix(0, 0),
+		Secure:   m.opt.Secure,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+	return nil
+}
+
+// Require пропускает только запросы с действующей сессией, остальных отправляет на страницу входа.
+// Изменяющие запросы дополнительно должны передать CSRF токен сессии в поле _csrf или заголовке X-CSRF-Token.
+// Токен доступен шаблонам как {{.CSRF}}, имя пользователя как {{.Username}}
+func (m *Manager) Require() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		session, err := m.current(ctx)
+		if errors.Is(err, ErrNotFound) {
+			return ctx.Redirect(m.opt.LoginPath+"?next="+url.QueryEscape(ctx.OriginalURL()), fiber.StatusSeeOther)
+		}
+		if err != nil {
+			return err
+		}
+
+		if !isSafeMethod(ctx.Method()) && !validCSRF(ctx, session.CSRFToken) {
+			return ctx.Status(fiber.StatusForbidden).SendString("invalid CSRF token")
+		}
+
+		ctx.Locals(localsKey, session)
+		ctx.Bind(fiber.Map{
+			"CSRF":     session.CSRFToken,
+			"Username": session.Username,
+		})
+		auth.SetPrincipal(ctx, &auth.Principal{
+			Method: auth.MethodSession,
+			ID:     strconv.Itoa(session.UserID),
+			Name:   session.Username,
+			Scope:  auth.RoleScope(session.Role),
+			Role:   session.Role,
+			Tenant: session.TenantID,
+		})
+
+		return ctx.Next()
+	}
+}
+
+// LoginPath страница входа
+func (m *Manager) LoginPath() string {
+	return m.opt.LoginPath
+}
+
+func (m *Manager) current(ctx *fiber.Ctx) (*Session, error) {
+	token := ctx.Cookies(m.opt.CookieName)
+	if token == "" {
+		return nil, ErrNotFound
+	}
+	return m.store.Get(ctx.Context(), token)
+}
+
+func From(ctx *fiber.Ctx) (*Session, bool) {
+	session, ok := ctx.Locals(localsKey).(*Session)
+	return session, ok
+}
+
+func validCSRF(ctx *fiber.Ctx, expected string) bool {
+	token := ctx.FormValue(FieldCSRF)
+	if token == "" {
+		token = ctx.Get(HeaderCSRF)
+	}
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+// SafeRedirect оставляет только локальные пути, чтобы next после входа нельзя было увести на чужой сайт
+func SafeRedirect(next string) string {
+	if len(next) == 0 || next[0] != '/' || (len(next) > 1 && (next[1] == '/' || next[1] == '\\')) {
+		return "/"
+	}
+	return next
+}
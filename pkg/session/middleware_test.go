@@ -0,0 +1,111 @@
+package session
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryStore map[string]*Session
+
+func (m memoryStore) Create(_ context.Context, userID int, ttl time.Duration) (*Session, string, error) {
+	token, err := NewToken()
+	if err != nil {
+		return nil, "", err
+	}
+	session := &Session{ID: hash(token), UserID: userID, Username: "user", CSRFToken: "csrf", ExpiresAt: time.Now().Add(ttl)}
+	m[token] = session
+	return session, token, nil
+}
+
+func (m memoryStore) Get(_ context.Context, token string) (*Session, error) {
+	session, ok := m[token]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return session, nil
+}
+
+func (m memoryStore) Delete(_ context.Context, token string) error {
+	delete(m, token)
+	return nil
+}
+
+func TestManager_Require(t *testing.T) {
+	store := memoryStore{}
+	manager := NewManager(store, Options{})
+	_, token, err := store.Create(context.Background(), 1, time.Hour)
+	require.NoError(t, err)
+
+	app := fiber.New()
+	app.Get("/", manager.Require(), func(ctx *fiber.Ctx) error {
+		return ctx.SendString("ok")
+	})
+	app.Post("/delete/1", manager.Require(), func(ctx *fiber.Ctx) error {
+		return ctx.SendString("deleted")
+	})
+
+	tests := []struct {
+		name     string
+		method   string
+		cookie   string
+		csrf     string
+		status   int
+		location string
+	}{
+		{name: "no session", method: fiber.MethodGet, status: fiber.StatusSeeOther, location: "/login?next=%2F"},
+		{name: "unknown session", method: fiber.MethodGet, cookie: "other", status: fiber.StatusSeeOther, location: "/login?next=%2F"},
+		{name: "read with session", method: fiber.MethodGet, cookie: token, status: fiber.StatusOK},
+		{name: "post without csrf", method: fiber.MethodPost, cookie: token, status: fiber.StatusForbidden},
+		{name: "post with wrong csrf", method: fiber.MethodPost, cookie: token, csrf: "wrong", status: fiber.StatusForbidden},
+		{name: "post with csrf", method: fiber.MethodPost, cookie: token, csrf: "csrf", status: fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/"
+			if tt.method == fiber.MethodPost {
+				path = "/delete/1"
+			}
+			form := url.Values{}
+			if tt.csrf != "" {
+				form.Set(FieldCSRF, tt.csrf)
+			}
+			req := httptest.NewRequest(tt.method, path, strings.NewReader(form.Encode()))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationForm)
+			if tt.cookie != "" {
+				req.Header.Set(fiber.HeaderCookie, "crud_session="+tt.cookie)
+			}
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			require.Equal(t, tt.status, resp.StatusCode)
+			if tt.location != "" {
+				require.Equal(t, tt.location, resp.Header.Get(fiber.HeaderLocation))
+			}
+		})
+	}
+}
+
+func TestSafeRedirect(t *testing.T) {
+	tests := []struct {
+		next string
+		want string
+	}{
+		{next: "", want: "/"},
+		{next: "/edit/1", want: "/edit/1"},
+		{next: "//evil.example", want: "/"},
+		{next: "/\\evil.example", want: "/"},
+		{next: "https://evil.example", want: "/"},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, SafeRedirect(tt.next), tt.next)
+	}
+}
@@ -0,0 +1,172 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	builder "github.com/doug-martin/goqu/v9"
+
+	"github.com/grip211/crud/pkg/database"
+	"github.com/grip211/crud/pkg/logging"
+)
+
+// тут храним сессии HTML интерфейса в таблице Sessions. В куке лежит случайный токен,
+// в базе только его sha256, вместе с сессией хранится CSRF токен для форм
+
+const tokenLength = 32
+
+var ErrNotFound = errors.New("session not found")
+
+type Session struct {
+	ID        string    `db:"id"`
+	UserID    int       `db:"user_id"`
+	Username  string    `db:"username"`
+	Role      string    `db:"role"`
+	TenantID  string    `db:"tenant_id"`
+	CSRFToken string    `db:"csrf_token"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+type Store interface {
+	// Create заводит сессию и возвращает ее вместе с токеном для куки
+	Create(ctx context.Context, userID int, ttl time.Duration) (*Session, string, error)
+	// Get возвращает действующую сессию по токену или ErrNotFound
+	Get(ctx context.Context, token string) (*Session, error)
+	Delete(ctx context.Context, token string) error
+}
+
+type Repo struct {
+	db database.Pool
+}
+
+func NewRepo(db database.Pool) *Repo {
+	return &Repo{
+		db: db,
+	}
+}
+
+func (r *Repo) Create(ctx context.Context, userID int, ttl time.Duration) (*Session, string, error) {
+	token, err := NewToken()
+	if err != nil {
+		return nil, "", err
+	}
+	csrf, err := NewToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	session := &Session{
+		ID:        hash(token),
+		UserID:    userID,
+		CSRFToken: csrf,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+
+	_, err = r.db.Builder().
+		Insert(database.Table(r.db, "Sessions")).
+		Rows(builder.Record{
+			"id":         session.ID,
+			"user_id":    session.UserID,
+			"csrf_token": session.CSRFToken,
+			"expires_at": session.ExpiresAt,
+		}).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("insert session: %w", err)
+	}
+
+	return session, token, nil
+}
+
+func (r *Repo) Get(ctx context.Context, token string) (*Session, error) {
+	var session Session
+	found, err := r.db.Builder().
+		Select(
+			builder.I("Sessions.id").As("id"),
+			builder.C("user_id"),
+			builder.C("username"),
+			builder.C("role"),
+			builder.C("tenant_id"),
+			builder.C("csrf_token"),
+			builder.C("expires_at"),
+		).
+		From(database.Table(r.db, "Sessions")).
+		InnerJoin(
+			database.Table(r.db, "Users"),
+			builder.On(builder.Ex{
+				"Sessions.user_id": builder.I("Users.id")}),
+		).
+		Where(
+			builder.I("Sessions.id").Eq(hash(token)),
+			builder.C("expires_at").Gt(time.Now()),
+		).
+		ScanStructContext(ctx, &session)
+	if err != nil {
+		return nil, fmt.Errorf("fetch session: %w", err)
+	}
+	if !found {
+		return nil, ErrNotFound
+	}
+	return &session, nil
+}
+
+func (r *Repo) Delete(ctx context.Context, token string) error {
+	_, err := r.db.Builder().
+		Delete(database.Table(r.db, "Sessions")).
+		Where(builder.C("id").Eq(hash(token))).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("delete session: %w", err)
+	}
+	return nil
+}
+
+func (r *Repo) DeleteExpired(ctx context.Context) (int64, error) {
+	res, err := r.db.Builder().
+		Delete(database.Table(r.db, "Sessions")).
+		Where(builder.C("expires_at").Lt(time.Now())).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// RunCleanup периодически удаляет просроченные сессии, пока не отменен ctx
+func (r *Repo) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.DeleteExpired(ctx); err != nil {
+				logging.FromContext(ctx).Error("delete expired sessions", "error", err)
+			}
+		}
+	}
+}
+
+// NewToken случайный токен в hex
+func NewToken() (string, error) {
+	b := make([]byte, tokenLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
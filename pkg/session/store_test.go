@@ -0,0 +1,62 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grip211/crud/pkg/auth"
+	"github.com/grip211/crud/pkg/database"
+	"github.com/grip211/crud/pkg/database/dbtest"
+	"github.com/grip211/crud/pkg/tenant"
+	"github.com/grip211/crud/pkg/user"
+	"github.com/grip211/crud/pkg/xrand"
+)
+
+func TestRepo_Get(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	dbtest.ForEachDialect(ctx, t, func(t *testing.T, conn database.Pool) {
+		account, err := user.NewRepo(conn).Create(ctx, tenant.Default, "session-"+xrand.RandStringBytesMask(12), "password-123", auth.RoleEditor)
+		require.NoError(t, err)
+
+		repo := NewRepo(conn)
+		created, token, err := repo.Create(ctx, account.ID, time.Hour)
+		require.NoError(t, err)
+		_, expiredToken, err := repo.Create(ctx, account.ID, -time.Hour)
+		require.NoError(t, err)
+
+		tests := []struct {
+			name    string
+			token   string
+			wantErr error
+		}{
+			{name: "active session with its user", token: token},
+			{name: "expired session", token: expiredToken, wantErr: ErrNotFound},
+			{name: "unknown token", token: "unknown", wantErr: ErrNotFound},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				session, err := repo.Get(ctx, tt.token)
+				if tt.wantErr != nil {
+					require.ErrorIs(t, err, tt.wantErr)
+					return
+				}
+				require.NoError(t, err)
+				require.Equal(t, created.ID, session.ID)
+				require.Equal(t, created.CSRFToken, session.CSRFToken)
+				require.Equal(t, account.Username, session.Username)
+				require.Equal(t, account.Role, session.Role)
+				require.Equal(t, tenant.Default, session.TenantID)
+			})
+		}
+
+		require.NoError(t, repo.Delete(ctx, token))
+		_, err = repo.Get(ctx, token)
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+}
@@ -2,13 +2,14 @@ package signal
 
 import (
 	"context"
-	"fmt"
 	"net"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+
+	"github.com/grip211/crud/pkg/logging"
 )
 
 const (
@@ -36,6 +37,8 @@ func Listener(ctx context.Context, listener int, uds, tcp string) (net.Listener,
 }
 
 func maybeChmodSocket(c context.Context, sock string) {
+	logger := logging.FromContext(c).With("socket", sock)
+
 	// on Linux and similar systems, there may be problems with the rights to the UDS socket
 	go func() {
 		ctx, cancel := context.WithTimeout(c, 500*time.Millisecond)
@@ -43,32 +46,27 @@ func maybeChmodSocket(c context.Context, sock string) {
 
 		var tryCount uint
 
-		fmt.Println("run chmod")
-		defer fmt.Println("stop chmod")
-
 		for {
 			select {
 			case <-ctx.Done():
-				fmt.Println("context is canceled")
+				logger.Debug("chmod unix socket canceled", "error", ctx.Err())
 				return
 			case <-time.After(time.Millisecond * 100):
-				fmt.Printf("loop %d for chmod unix socket (%s) \n", tryCount, sock)
-
 				if err := os.Chmod(sock, 0o666); err != nil {
-					fmt.Println(err)
+					logger.Debug("chmod unix socket", "attempt", tryCount, "error", err)
 					continue
 				}
 
 				_, err := os.Stat(sock)
 				// if the file exists and it already has permissions
 				if err == nil {
-					fmt.Printf("unix socket (%s) is ready for listen \n", sock)
+					logger.Debug("unix socket is ready for listen")
 					return
 				}
 
 				tryCount++
 				if tryCount > 5 {
-					fmt.Println("try count is outside for chmod unix socket")
+					logger.Warn("give up chmod unix socket", "attempts", tryCount)
 					return
 				}
 			}
@@ -0,0 +1,182 @@
+package tenant
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/grip211/crud/pkg/apperror"
+	"github.com/grip211/crud/pkg/auth"
+	"github.com/grip211/crud/pkg/logging"
+)
+
+const (
+	HeaderTenant = "X-Tenant-ID"
+	// LocalsKey под этим ключом в Locals лежит выбранный арендатор, нужен там, где нет UserContext (WebSocket)
+	LocalsKey = "tenant"
+
+	localsKey = "tenant.requested"
+	cacheTTL  = time.Minute
+)
+
+type Options struct {
+	// BaseDomain если задан, арендатор берется из поддомена: shop-a.catalog.example -> shop-a
+	BaseDomain string `yaml:"base_domain"`
+	// Default арендатор, если его не дали ни учетные данные, ни запрос
+	Default string `yaml:"default"`
+}
+
+// Resolver определяет арендатора запроса. Запрошенный арендатор берется из заголовка X-Tenant-ID
+// или поддомена, но если учетные данные (API ключ, пользователь, claim JWT) привязаны к арендатору,
+// решают они, а запрос другого арендатора отклоняется
+type Resolver struct {
+	store Store
+	opt   Options
+
+	mu    sync.Mutex
+	known map[string]time.Time
+}
+
+func NewResolver(store Store, opt Options) *Resolver {
+	return &Resolver{
+		store: store,
+		opt:   opt,
+		known: map[string]time.Time{},
+	}
+}
+
+// Requested middleware, которое запоминает запрошенного арендатора, ставится до аутентификации
+func (r *Resolver) Requested() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		id := strings.ToLower(strings.TrimSpace(ctx.Get(HeaderTenant)))
+		if id == "" {
+			id = r.subdomain(ctx.Hostname())
+		}
+		if id == "" {
+			return ctx.Next()
+		}
+
+		if !Valid(id) {
+			return ctx.Status(fiber.StatusBadRequest).JSON(apperror.NewErrorHandler(
+				ErrInvalid, "invalid tenant", ErrInvalid.Error(), "tenant_invalid",
+			))
+		}
+		exists, err := r.exists(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ctx.Status(fiber.StatusNotFound).JSON(apperror.NewErrorHandler(
+				ErrNotFound, "tenant not found", ErrNotFound.Error(), "tenant_not_found",
+			))
+		}
+
+		ctx.Locals(localsKey, id)
+		return ctx.Next()
+	}
+}
+
+// Enforce middleware после аутентификации: выбирает арендатора с учетом принципала
+func (r *Resolver) Enforce() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		var principalTenant string
+		if principal, ok := auth.PrincipalFrom(ctx); ok {
+			principalTenant = principal.Tenant
+		}
+		if err := r.Bind(ctx, principalTenant); err != nil {
+			return Respond(ctx, err)
+		}
+		return ctx.Next()
+	}
+}
+
+// Bind выбирает арендатора для запроса с учетом арендатора учетных данных и кладет его в UserContext
+func (r *Resolver) Bind(ctx *fiber.Ctx, principalTenant string) error {
+	id, err := r.Resolve(RequestedFrom(ctx), principalTenant)
+	if err != nil {
+		return err
+	}
+	ctx.Locals(LocalsKey, id)
+	ctx.SetUserContext(WithTenant(logging.With(ctx.UserContext(), "tenant", id), id))
+	return nil
+}
+
+// Resolve арендатор учетных данных главнее запрошенного, без обоих берется арендатор по умолчанию
+func (r *Resolver) Resolve(requested, principalTenant string) (string, error) {
+	switch {
+	case principalTenant != "" && requested != "" && principalTenant != requested:
+		return "", ErrMismatch
+	case principalTenant != "":
+		return principalTenant, nil
+	case requested != "":
+		return requested, nil
+	case r.opt.Default != "":
+		return r.opt.Default, nil
+	default:
+		return "", ErrMissing
+	}
+}
+
+// Respond ответ на ошибку Bind в стандартном формате
+func Respond(ctx *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, ErrMismatch):
+		return ctx.Status(fiber.StatusForbidden).JSON(apperror.NewErrorHandler(
+			err, "forbidden", err.Error(), "tenant_mismatch",
+		))
+	case errors.Is(err, ErrMissing):
+		return ctx.Status(fiber.StatusBadRequest).JSON(apperror.NewErrorHandler(
+			err, "tenant required", "pass the tenant in the X-Tenant-ID header or the subdomain", "tenant_required",
+		))
+	default:
+		return err
+	}
+}
+
+func RequestedFrom(ctx *fiber.Ctx) string {
+	id, _ := ctx.Locals(localsKey).(string)
+	return id
+}
+
+func (r *Resolver) subdomain(host string) string {
+	if r.opt.BaseDomain == "" {
+		return ""
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	suffix := "." + strings.ToLower(r.opt.BaseDomain)
+	host = strings.ToLower(host)
+	if !strings.HasSuffix(host, suffix) {
+		return ""
+	}
+	sub := strings.TrimSuffix(host, suffix)
+	if strings.Contains(sub, ".") {
+		return ""
+	}
+	return sub
+}
+
+// exists проверяет арендатора в базе и запоминает найденных на cacheTTL
+func (r *Resolver) exists(ctx *fiber.Ctx, id string) (bool, error) {
+	r.mu.Lock()
+	checked, ok := r.known[id]
+	r.mu.Unlock()
+	if ok && time.Since(checked) < cacheTTL {
+		return true, nil
+	}
+
+	exists, err := r.store.Exists(ctx.Context(), id)
+	if err != nil || !exists {
+		return false, err
+	}
+
+	r.mu.Lock()
+	r.known[id] = time.Now()
+	r.mu.Unlock()
+	return true, nil
+}
@@ -0,0 +1,110 @@
+package tenant
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grip211/crud/pkg/auth"
+)
+
+type memoryStore map[string]bool
+
+func (m memoryStore) Exists(_ context.Context, id string) (bool, error) {
+	return m[id], nil
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	tests := []struct {
+		name      string
+		defaultID string
+		requested string
+		principal string
+		want      string
+		err       error
+	}{
+		{name: "principal tenant", principal: "shop-a", want: "shop-a"},
+		{name: "requested tenant", requested: "shop-b", want: "shop-b"},
+		{name: "same tenant", requested: "shop-a", principal: "shop-a", want: "shop-a"},
+		{name: "another tenant", requested: "shop-b", principal: "shop-a", err: ErrMismatch},
+		{name: "default tenant", defaultID: Default, want: Default},
+		{name: "no tenant", err: ErrMissing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := NewResolver(memoryStore{}, Options{Default: tt.defaultID})
+			got, err := resolver.Resolve(tt.requested, tt.principal)
+			require.ErrorIs(t, err, tt.err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestResolver_Middleware(t *testing.T) {
+	resolver := NewResolver(memoryStore{"shop-a": true, "shop-b": true}, Options{BaseDomain: "catalog.example"})
+
+	app := fiber.New()
+	app.Use(resolver.Requested())
+	app.Use(func(ctx *fiber.Ctx) error {
+		if tenantID := ctx.Get("X-Principal-Tenant"); tenantID != "" {
+			auth.SetPrincipal(ctx, &auth.Principal{Method: auth.MethodAPIKey, Scope: auth.ScopeRead, Tenant: tenantID})
+		}
+		return ctx.Next()
+	})
+	app.Use(resolver.Enforce())
+	app.Get("/products", func(ctx *fiber.Ctx) error {
+		tenantID, err := Require(ctx.UserContext())
+		if err != nil {
+			return err
+		}
+		return ctx.SendString(tenantID)
+	})
+
+	tests := []struct {
+		name      string
+		host      string
+		header    string
+		principal string
+		status    int
+		tenant    string
+	}{
+		{name: "header", header: "shop-a", status: fiber.StatusOK, tenant: "shop-a"},
+		{name: "subdomain", host: "shop-b.catalog.example", status: fiber.StatusOK, tenant: "shop-b"},
+		{name: "subdomain with port", host: "shop-b.catalog.example:8181", status: fiber.StatusOK, tenant: "shop-b"},
+		{name: "header wins over subdomain", host: "shop-b.catalog.example", header: "shop-a", status: fiber.StatusOK, tenant: "shop-a"},
+		{name: "principal tenant", principal: "shop-a", status: fiber.StatusOK, tenant: "shop-a"},
+		{name: "principal of another tenant", header: "shop-b", principal: "shop-a", status: fiber.StatusForbidden},
+		{name: "unknown tenant", header: "shop-c", status: fiber.StatusNotFound},
+		{name: "invalid tenant", header: "Shop_A!", status: fiber.StatusBadRequest},
+		{name: "no tenant", status: fiber.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodGet, "/products", nil)
+			if tt.host != "" {
+				req.Host = tt.host
+			}
+			if tt.header != "" {
+				req.Header.Set(HeaderTenant, tt.header)
+			}
+			if tt.principal != "" {
+				req.Header.Set("X-Principal-Tenant", tt.principal)
+			}
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			require.Equal(t, tt.status, resp.StatusCode)
+			if tt.tenant != "" {
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				require.Equal(t, tt.tenant, string(body))
+			}
+		})
+	}
+}
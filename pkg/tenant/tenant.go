@@ -0,0 +1,123 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	builder "github.com/doug-martin/goqu/v9"
+
+	"github.com/grip211/crud/pkg/database"
+)
+
+// тут арендаторы (магазины), у каждого свой каталог. Арендатор запроса лежит в ctx,
+// репозитории берут его оттуда и без него не работают
+
+const Default = "default"
+
+var (
+	ErrMissing  = errors.New("tenant is not resolved")
+	ErrNotFound = errors.New("tenant not found")
+	ErrMismatch = errors.New("credentials belong to another tenant")
+	ErrInvalid  = errors.New("tenant id must be 1-63 lowercase letters, digits or dashes")
+	ErrExists   = errors.New("tenant already exists")
+)
+
+var idPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+func Valid(id string) bool {
+	return idPattern.MatchString(id)
+}
+
+type Tenant struct {
+	ID        string    `db:"id" json:"id"`
+	Name      string    `db:"name" json:"name"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+type contextKey struct{}
+
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(contextKey{}).(string)
+	return id, ok && id != ""
+}
+
+// Require арендатор из ctx или ErrMissing
+func Require(ctx context.Context) (string, error) {
+	id, ok := FromContext(ctx)
+	if !ok {
+		return "", ErrMissing
+	}
+	return id, nil
+}
+
+type Store interface {
+	Exists(ctx context.Context, id string) (bool, error)
+}
+
+type Repo struct {
+	db database.Pool
+}
+
+func NewRepo(db database.Pool) *Repo {
+	return &Repo{
+		db: db,
+	}
+}
+
+func (r *Repo) Create(ctx context.Context, id, name string) (*Tenant, error) {
+	if !Valid(id) {
+		return nil, ErrInvalid
+	}
+	exists, err := r.Exists(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrExists
+	}
+
+	_, err = r.db.Builder().
+		Insert(database.Table(r.db, "Tenants")).
+		Rows(builder.Record{
+			"id":   id,
+			"name": name,
+		}).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("insert tenant: %w", err)
+	}
+	return &Tenant{ID: id, Name: name, CreatedAt: time.Now()}, nil
+}
+
+func (r *Repo) List(ctx context.Context) ([]Tenant, error) {
+	var tenants []Tenant
+	err := r.db.Builder().
+		From(database.Table(r.db, "Tenants")).
+		Order(builder.C("id").Asc()).
+		ScanStructsContext(ctx, &tenants)
+	if err != nil {
+		return nil, fmt.Errorf("fetch tenants: %w", err)
+	}
+	return tenants, nil
+}
+
+func (r *Repo) Exists(ctx context.Context, id string) (bool, error) {
+	var found string
+	ok, err := r.db.Builder().
+		From(database.Table(r.db, "Tenants")).
+		Select("id").
+		Where(builder.C("id").Eq(id)).
+		ScanValContext(ctx, &found)
+	if err != nil {
+		return false, fmt.Errorf("fetch tenant: %w", err)
+	}
+	return ok, nil
+}
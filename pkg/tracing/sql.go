@@ -0,0 +1,115 @@
+package tracing
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+
+	"github.com/XSAM/otelsql"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// тут спаны SQL запросов. goqu по умолчанию подставляет значения прямо в текст запроса,
+// поэтому в спан пишем текст, в котором литералы заменены на ?. Какие кавычки означают строку,
+// а какие идентификатор, зависит от диалекта
+
+// SQLQuotes кавычки диалекта: строки в них заменяются на ?, идентификаторы остаются как есть
+type SQLQuotes struct {
+	Strings     string
+	Identifiers string
+	// Backslash обратная косая черта экранирует символ внутри строки
+	Backslash bool
+}
+
+var (
+	// QuotesMySQL "строки" в MySQL такие же строки, как 'строки'
+	QuotesMySQL = SQLQuotes{Strings: `'"`, Identifiers: "`", Backslash: true}
+	// QuotesPostgres в PostgreSQL "идентификатор" в двойных кавычках, строки только в одинарных,
+	// обратная косая черта в них обычный символ
+	QuotesPostgres = SQLQuotes{Strings: "'", Identifiers: `"`}
+)
+
+// SQLOptions настройки обертки драйвера otelsql
+func SQLOptions(system attribute.KeyValue, quotes SQLQuotes) []otelsql.Option {
+	return []otelsql.Option{
+		otelsql.WithAttributes(system),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			DisableQuery:         true,
+			OmitConnResetSession: true,
+			OmitConnectorConnect: true,
+			OmitRows:             true,
+		}),
+		otelsql.WithAttributesGetter(func(_ context.Context, _ otelsql.Method, query string, _ []driver.NamedValue) []attribute.KeyValue {
+			if query == "" {
+				return nil
+			}
+			return []attribute.KeyValue{semconv.DBStatement(quotes.Sanitize(query))}
+		}),
+	}
+}
+
+// Sanitize заменяет строковые и числовые литералы на ?, идентификаторы в кавычках
+// и параметры $1 PostgreSQL не трогает
+func (q SQLQuotes) Sanitize(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case strings.IndexByte(q.Strings, c) >= 0:
+			i = q.skipQuoted(query, i, c)
+			b.WriteByte('?')
+		case strings.IndexByte(q.Identifiers, c) >= 0:
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+2])
+			i += end + 1
+		case c == '$' && i+1 < len(query) && isDigit(query[i+1]):
+			b.WriteByte(c)
+			for i+1 < len(query) && isDigit(query[i+1]) {
+				i++
+				b.WriteByte(query[i])
+			}
+		case isDigit(c) && (i == 0 || !isWord(query[i-1])):
+			for i+1 < len(query) && (isWord(query[i+1]) || query[i+1] == '.') {
+				i++
+			}
+			b.WriteByte('?')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// skipQuoted индекс закрывающей кавычки, удвоенная кавычка и экранирование обратной косой чертой пропускаются
+func (q SQLQuotes) skipQuoted(query string, start int, quote byte) int {
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			if q.Backslash {
+				i++
+			}
+		case quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return len(query) - 1
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isWord(c byte) bool {
+	return isDigit(c) || c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
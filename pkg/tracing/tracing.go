@@ -0,0 +1,122 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// тут настройка OpenTelemetry: экспортер спанов и W3C traceparent для входящих и исходящих запросов.
+// Без настройки глобальный провайдер ничего не записывает, поэтому спаны в коде можно создавать всегда
+
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+
+	instrumentation = "github.com/grip211/crud"
+)
+
+var ErrUnknownExporter = errors.New("tracing exporter must be one of none, otlp, stdout")
+
+type Options struct {
+	Exporter string `yaml:"exporter"`
+	// Endpoint адрес коллектора OTLP/HTTP, например localhost:4318
+	Endpoint string `yaml:"endpoint"`
+	// Insecure без TLS, для локального коллектора
+	Insecure    bool    `yaml:"insecure"`
+	ServiceName string  `yaml:"service_name"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+func (o *Options) Validate() error {
+	switch o.Exporter {
+	case "", ExporterNone, ExporterOTLP, ExporterStdout:
+	default:
+		return fmt.Errorf("%w, got %q", ErrUnknownExporter, o.Exporter)
+	}
+	if o.SampleRatio < 0 || o.SampleRatio > 1 {
+		return fmt.Errorf("tracing sample_ratio must be between 0 and 1, got %v", o.SampleRatio)
+	}
+	return nil
+}
+
+// Setup настраивает глобальные провайдер и propagator, возвращает функцию, которая дописывает
+// оставшиеся спаны при остановке
+func Setup(ctx context.Context, opt Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if err := opt.Validate(); err != nil {
+		return nil, err
+	}
+	if opt.Exporter == "" || opt.Exporter == ExporterNone {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := newExporter(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(opt.ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("tracing resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opt.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, opt Options) (sdktrace.SpanExporter, error) {
+	switch opt.Exporter {
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		options := []otlptracehttp.Option{}
+		if opt.Endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(opt.Endpoint))
+		}
+		if opt.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, options...)
+	}
+}
+
+// Tracer трассировщик сервиса из глобального провайдера
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// TraceParent W3C traceparent спана из ctx, пустой если спана нет
+func TraceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// WithTraceParent ctx с удаленным родительским спаном из traceparent
+func WithTraceParent(ctx context.Context, traceParent string) context.Context {
+	if traceParent == "" {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier{"traceparent": traceParent})
+}
@@ -0,0 +1,186 @@
+package tracing
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/grip211/crud/pkg/apperror"
+)
+
+const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		_ = provider.Shutdown(context.Background())
+	})
+	return recorder
+}
+
+func TestSQLQuotes_Sanitize(t *testing.T) {
+	tests := []struct {
+		name   string
+		quotes SQLQuotes
+		query  string
+		want   string
+	}{
+		{
+			name:   "string literal",
+			quotes: QuotesMySQL,
+			query:  "SELECT * FROM `Products` WHERE `name` = 'phone'",
+			want:   "SELECT * FROM `Products` WHERE `name` = ?",
+		},
+		{
+			name:   "escaped quotes",
+			quotes: QuotesMySQL,
+			query:  `UPDATE t SET a = 'it''s', b = "say \"hi\"" WHERE id = 1`,
+			want:   "UPDATE t SET a = ?, b = ? WHERE id = ?",
+		},
+		{
+			name:   "numbers",
+			quotes: QuotesMySQL,
+			query:  "SELECT * FROM t WHERE price > 10.5 LIMIT 20 OFFSET 0",
+			want:   "SELECT * FROM t WHERE price > ? LIMIT ? OFFSET ?",
+		},
+		{
+			name:   "identifiers with digits",
+			quotes: QuotesMySQL,
+			query:  "SELECT col1 FROM `table2` WHERE t1.v2 IN (3, 4)",
+			want:   "SELECT col1 FROM `table2` WHERE t1.v2 IN (?, ?)",
+		},
+		{
+			name:   "placeholders stay",
+			quotes: QuotesMySQL,
+			query:  "SELECT * FROM t WHERE id = ?",
+			want:   "SELECT * FROM t WHERE id = ?",
+		},
+		{
+			name:   "unterminated string",
+			quotes: QuotesMySQL,
+			query:  "SELECT 'abc",
+			want:   "SELECT ?",
+		},
+		{
+			name:   "postgres quoted identifiers",
+			quotes: QuotesPostgres,
+			query:  `SELECT "id", "model" FROM "productdb"."Products" WHERE "model" = 'phone' AND "price" > 10`,
+			want:   `SELECT "id", "model" FROM "productdb"."Products" WHERE "model" = ? AND "price" > ?`,
+		},
+		{
+			name:   "postgres backslash is not an escape",
+			quotes: QuotesPostgres,
+			query:  `UPDATE "Products" SET "model" = 'C:' WHERE "id" = 7`,
+			want:   `UPDATE "Products" SET "model" = ? WHERE "id" = ?`,
+		},
+		{
+			name:   "postgres placeholders stay",
+			quotes: QuotesPostgres,
+			query:  `SELECT * FROM "Products" WHERE "id" = $1 AND "price" > $12`,
+			want:   `SELECT * FROM "Products" WHERE "id" = $1 AND "price" > $12`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.quotes.Sanitize(tt.query))
+		})
+	}
+}
+
+func TestTraceParent(t *testing.T) {
+	setupRecorder(t)
+
+	require.Empty(t, TraceParent(context.Background()))
+	require.Equal(t, context.Background(), WithTraceParent(context.Background(), ""))
+
+	ctx := WithTraceParent(context.Background(), traceParent)
+	require.Equal(t, traceParent, TraceParent(ctx))
+
+	ctx, span := Tracer().Start(ctx, "child")
+	defer span.End()
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	require.NotEqual(t, traceParent, TraceParent(ctx))
+}
+
+func TestMiddleware(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	app := fiber.New()
+	app.Use(Middleware())
+	app.Use(apperror.Resolve())
+	app.Get("/products/:id", func(ctx *fiber.Ctx) error {
+		require.True(t, trace.SpanContextFromContext(ctx.UserContext()).IsValid())
+		return ctx.SendString(ctx.Params("id"))
+	})
+	app.Get("/fail", func(ctx *fiber.Ctx) error {
+		return fiber.ErrServiceUnavailable
+	})
+
+	tests := []struct {
+		name        string
+		path        string
+		traceParent string
+		wantName    string
+		wantStatus  int
+		wantError   bool
+	}{
+		{
+			name:       "new trace",
+			path:       "/products/1",
+			wantName:   "GET /products/:id",
+			wantStatus: fiber.StatusOK,
+		},
+		{
+			name:        "continue client trace",
+			path:        "/products/2",
+			traceParent: traceParent,
+			wantName:    "GET /products/:id",
+			wantStatus:  fiber.StatusOK,
+		},
+		{
+			name:       "handler error",
+			path:       "/fail",
+			wantName:   "GET /fail",
+			wantStatus: fiber.StatusServiceUnavailable,
+			wantError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodGet, tt.path, nil)
+			if tt.traceParent != "" {
+				req.Header.Set("traceparent", tt.traceParent)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			spans := recorder.Ended()
+			require.NotEmpty(t, spans)
+			span := spans[len(spans)-1]
+			require.Equal(t, tt.wantName, span.Name())
+			require.Equal(t, trace.SpanKindServer, span.SpanKind())
+			// ошибка обработчика записана в спан, хотя ответ на нее собрал apperror.Resolve
+			require.Equal(t, tt.wantError, len(span.Events()) > 0)
+			if tt.traceParent != "" {
+				require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+				require.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+			} else {
+				require.False(t, span.Parent().IsValid())
+			}
+		})
+	}
+}
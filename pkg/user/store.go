@@ -0,0 +1,164 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	builder "github.com/doug-martin/goqu/v9"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/grip211/crud/pkg/auth"
+	"github.com/grip211/crud/pkg/database"
+)
+
+// тут храним пользователей HTML интерфейса в таблице Users, пароли только в виде bcrypt хеша
+
+const minPasswordLength = 8
+
+var (
+	ErrNotFound           = errors.New("user not found")
+	ErrExists             = errors.New("user already exists")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrInvalidUsername    = errors.New("username must not be empty")
+	ErrPasswordTooShort   = fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	ErrInvalidRole        = errors.New("role must be one of viewer, editor, pricing, admin")
+)
+
+type User struct {
+	ID           int       `db:"id" json:"id"`
+	Username     string    `db:"username" json:"username"`
+	PasswordHash string    `db:"password_hash" json:"-"`
+	Role         string    `db:"role" json:"role"`
+	TenantID     string    `db:"tenant_id" json:"tenant"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+}
+
+type Repo struct {
+	db database.Pool
+	// dummyHash сравниваем с ним пароль неизвестного пользователя, чтобы по времени ответа
+	// нельзя было понять, существует ли пользователь
+	dummyHash []byte
+}
+
+func NewRepo(db database.Pool) *Repo {
+	dummyHash, _ := bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	return &Repo{
+		db:        db,
+		dummyHash: dummyHash,
+	}
+}
+
+func (r *Repo) Create(ctx context.Context, tenantID, username, password, role string) (*User, error) {
+	if username == "" {
+		return nil, ErrInvalidUsername
+	}
+	if !auth.ValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	if len(password) < minPasswordLength {
+		return nil, ErrPasswordTooShort
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("hash password: %w", err)
+	}
+
+	id, err := database.InsertID(ctx, r.db.Builder().
+		Insert(database.Table(r.db, "Users")).
+		Rows(builder.Record{
+			"username":      username,
+			"password_hash": string(hash),
+			"role":          role,
+			"tenant_id":     tenantID,
+		}))
+	if err != nil {
+		if database.IsDuplicate(err) {
+			return nil, ErrExists
+		}
+		return nil, fmt.Errorf("insert user: %w", err)
+	}
+
+	return &User{
+		ID:           int(id),
+		Username:     username,
+		PasswordHash: string(hash),
+		Role:         role,
+		TenantID:     tenantID,
+		CreatedAt:    time.Now(),
+	}, nil
+}
+
+func (r *Repo) SetRole(ctx context.Context, username, role string) error {
+	if !auth.ValidRole(role) {
+		return ErrInvalidRole
+	}
+
+	res, err := r.db.Builder().
+		Update(database.Table(r.db, "Users")).
+		Set(builder.Record{"role": role}).
+		Where(builder.C("username").Eq(username)).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("update user role: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("update user role: %w", err)
+	}
+	if affected == 0 {
+		// MySQL не считает строку, если значение не изменилось, поэтому проверяем наличие отдельно
+		var id int
+		found, err := r.db.Builder().
+			From(database.Table(r.db, "Users")).
+			Select("id").
+			Where(builder.C("username").Eq(username)).
+			ScanValContext(ctx, &id)
+		if err != nil {
+			return fmt.Errorf("fetch user: %w", err)
+		}
+		if !found {
+			return ErrNotFound
+		}
+	}
+	return nil
+}
+
+func (r *Repo) Get(ctx context.Context, id int) (*User, error) {
+	var user User
+	found, err := r.db.Builder().
+		From(database.Table(r.db, "Users")).
+		Where(builder.C("id").Eq(id)).
+		ScanStructContext(ctx, &user)
+	if err != nil {
+		return nil, fmt.Errorf("fetch user: %w", err)
+	}
+	if !found {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+// Authenticate проверяет имя и пароль
+func (r *Repo) Authenticate(ctx context.Context, username, password string) (*User, error) {
+	var user User
+	found, err := r.db.Builder().
+		From(database.Table(r.db, "Users")).
+		Where(builder.C("username").Eq(username)).
+		ScanStructContext(ctx, &user)
+	if err != nil {
+		return nil, fmt.Errorf("fetch user: %w", err)
+	}
+	if !found {
+		_ = bcrypt.CompareHashAndPassword(r.dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+
+	if err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return &user, nil
+}
@@ -0,0 +1,248 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/grip211/crud/pkg/events"
+	"github.com/grip211/crud/pkg/logging"
+	"github.com/grip211/crud/pkg/tracing"
+)
+
+// тут реализуем отправку вебхуков: события ставятся в очередь доставок,
+// воркер забирает созревшие доставки и повторяет неудачные с экспоненциальной задержкой
+
+const maxErrorLength = 1024
+
+type Options struct {
+	// PollInterval как часто воркер проверяет очередь
+	PollInterval time.Duration `yaml:"poll_interval"`
+	// BatchSize сколько доставок забирать за один проход
+	BatchSize int `yaml:"batch_size"`
+	// MaxAttempts после стольких неудачных попыток доставка помечается failed
+	MaxAttempts int `yaml:"max_attempts"`
+	// BaseBackoff задержка перед второй попыткой, дальше она удваивается до MaxBackoff
+	BaseBackoff time.Duration `yaml:"base_backoff"`
+	MaxBackoff  time.Duration `yaml:"max_backoff"`
+	// Timeout на один HTTP запрос к получателю
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+func (o *Options) withDefaults() Options {
+	opt := *o
+	if opt.PollInterval <= 0 {
+		opt.PollInterval = time.Second
+	}
+	if opt.BatchSize <= 0 {
+		opt.BatchSize = 50
+	}
+	if opt.MaxAttempts <= 0 {
+		opt.MaxAttempts = 10
+	}
+	if opt.BaseBackoff <= 0 {
+		opt.BaseBackoff = time.Second * 5
+	}
+	if opt.MaxBackoff <= 0 {
+		opt.MaxBackoff = time.Hour
+	}
+	if opt.Timeout <= 0 {
+		opt.Timeout = time.Second * 10
+	}
+	return opt
+}
+
+type Dispatcher struct {
+	store  Store
+	client *http.Client
+	opt    Options
+	now    func() time.Time
+}
+
+func NewDispatcher(store Store, opt Options) *Dispatcher {
+	opt = opt.withDefaults()
+	return &Dispatcher{
+		store:  store,
+		client: &http.Client{Timeout: opt.Timeout},
+		opt:    opt,
+		now:    time.Now,
+	}
+}
+
+// Enqueue ставит события в очередь доставки всем активным подпискам, принимающим их тип
+func (d *Dispatcher) Enqueue(ctx context.Context, list ...events.Event) error {
+	subscriptions, err := d.store.ActiveSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	var deliveries []Delivery
+	for i := range list {
+		payload, err := json.Marshal(&list[i])
+		if err != nil {
+			return fmt.Errorf("marshal event: %w", err)
+		}
+		for j := range subscriptions {
+			if !subscriptions[j].Accepts(&list[i]) {
+				continue
+			}
+			deliveries = append(deliveries, Delivery{
+				SubscriptionID: subscriptions[j].ID,
+				EventID:        list[i].ID,
+				EventType:      list[i].Type,
+				Payload:        payload,
+				Status:         StatusPending,
+				NextAttemptAt:  d.now(),
+			})
+		}
+	}
+
+	return d.store.EnqueueDeliveries(ctx, deliveries)
+}
+
+// Run обрабатывает очередь доставок, пока не отменен ctx
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.opt.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.DeliverDue(ctx); err != nil {
+				logging.FromContext(ctx).Error("deliver webhooks", "error", err)
+			}
+		}
+	}
+}
+
+// DeliverDue делает одну попытку для каждой созревшей доставки
+func (d *Dispatcher) DeliverDue(ctx context.Context) error {
+	deliveries, err := d.store.DueDeliveries(ctx, d.now(), d.opt.BatchSize)
+	if err != nil {
+		return err
+	}
+
+	targets := make(map[int]*Target)
+	for i := range deliveries {
+		delivery := &deliveries[i]
+
+		target, ok := targets[delivery.SubscriptionID]
+		if !ok {
+			if target, err = d.store.Target(ctx, delivery.SubscriptionID); err != nil {
+				return err
+			}
+			targets[delivery.SubscriptionID] = target
+		}
+
+		d.attempt(ctx, target, delivery)
+		if err = d.store.SaveAttempt(ctx, delivery); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, target *Target, delivery *Delivery) {
+	delivery.Attempts++
+
+	// доставка продолжает трассу запроса, который породил событие
+	ctx, span := tracing.Tracer().Start(traceContext(ctx, delivery.Payload), "webhook.deliver",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.Int64("webhook.delivery_id", delivery.ID),
+			attribute.Int("webhook.subscription_id", delivery.SubscriptionID),
+			attribute.Int("webhook.attempt", delivery.Attempts),
+			attribute.String("webhook.event_type", delivery.EventType),
+		),
+	)
+	defer span.End()
+
+	statusCode, err := d.send(ctx, target, delivery)
+	delivery.LastStatusCode = statusCode
+	span.SetAttributes(semconv.HTTPResponseStatusCode(statusCode))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	if err == nil {
+		now := d.now()
+		delivery.Status = StatusDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if len(delivery.LastError) > maxErrorLength {
+		delivery.LastError = delivery.LastError[:maxErrorLength]
+	}
+	if delivery.Attempts >= d.opt.MaxAttempts {
+		delivery.Status = StatusFailed
+		return
+	}
+	delivery.NextAttemptAt = d.now().Add(d.backoff(delivery.Attempts))
+}
+
+func (d *Dispatcher) send(ctx context.Context, target *Target, delivery *Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderSignature, Sign(target.Secret, d.now().Unix(), delivery.Payload))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// traceContext ctx с трассой события из payload доставки
+func traceContext(ctx context.Context, payload []byte) context.Context {
+	var event struct {
+		TraceParent string `json:"traceparent"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return ctx
+	}
+	return tracing.WithTraceParent(ctx, event.TraceParent)
+}
+
+// backoff задержка после attempts неудачных попыток с джиттером до 20%
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.opt.BaseBackoff
+	for i := 1; i < attempts && delay < d.opt.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > d.opt.MaxBackoff {
+		delay = d.opt.MaxBackoff
+	}
+	// nolint:gosec // для джиттера криптостойкий генератор не нужен
+	return delay + time.Duration(rand.Int63n(int64(delay)/5+1))
+}
@@ -0,0 +1,272 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/grip211/crud/pkg/events"
+)
+
+type memoryStore struct {
+	mu            sync.Mutex
+	subscriptions []Subscription
+	deliveries    []Delivery
+}
+
+func (m *memoryStore) ActiveSubscriptions(context.Context) ([]Subscription, error) {
+	return m.subscriptions, nil
+}
+
+func (m *memoryStore) EnqueueDeliveries(_ context.Context, deliveries []Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, delivery := range deliveries {
+		if m.queued(delivery.SubscriptionID, delivery.EventID) {
+			continue
+		}
+		delivery.ID = int64(len(m.deliveries) + 1)
+		m.deliveries = append(m.deliveries, delivery)
+	}
+	return nil
+}
+
+// queued как уникальный ключ (subscription_id, event_id) в базе
+func (m *memoryStore) queued(subscriptionID int, eventID uint64) bool {
+	for _, delivery := range m.deliveries {
+		if delivery.SubscriptionID == subscriptionID && delivery.EventID == eventID {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *memoryStore) DueDeliveries(_ context.Context, now time.Time, limit int) ([]Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var due []Delivery
+	for _, delivery := range m.deliveries {
+		if delivery.Status == StatusPending && !delivery.NextAttemptAt.After(now) && len(due) < limit {
+			due = append(due, delivery)
+		}
+	}
+	return due, nil
+}
+
+func (m *memoryStore) Target(_ context.Context, subscriptionID int) (*Target, error) {
+	for _, subscription := range m.subscriptions {
+		if subscription.ID == subscriptionID {
+			return &Target{URL: subscription.URL, Secret: subscription.Secret}, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *memoryStore) SaveAttempt(_ context.Context, delivery *Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deliveries[delivery.ID-1] = *delivery
+	return nil
+}
+
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	received []events.Event
+	headers  []http.Header
+	errors   []error
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	// часы диспетчера в тесте переводятся, поэтому возраст подписи не проверяем
+	if err := Verify("secret", r.Header.Get(HeaderSignature), body, 0); err != nil {
+		rc.errors = append(rc.errors, err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if rc.failures > 0 {
+		rc.failures--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var event events.Event
+	_ = json.Unmarshal(body, &event)
+	rc.received = append(rc.received, event)
+	rc.headers = append(rc.headers, r.Header.Clone())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestDispatcher_DeliverDue(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	tests := []struct {
+		name          string
+		tenant        string
+		eventTypes    []string
+		failures      int
+		maxAttempts   int
+		passes        int
+		wantStatus    string
+		wantAttempts  int
+		wantDelivered int
+	}{
+		{
+			name:          "successfully deliver signed event",
+			passes:        1,
+			wantStatus:    StatusDelivered,
+			wantAttempts:  1,
+			wantDelivered: 1,
+		},
+		{
+			name:          "successfully deliver after retries",
+			failures:      2,
+			passes:        3,
+			wantStatus:    StatusDelivered,
+			wantAttempts:  3,
+			wantDelivered: 1,
+		},
+		{
+			name:         "failed delivery after max attempts",
+			failures:     10,
+			maxAttempts:  3,
+			passes:       5,
+			wantStatus:   StatusFailed,
+			wantAttempts: 3,
+		},
+		{
+			name:       "event type is not subscribed",
+			eventTypes: []string{events.TypeDeleted},
+			passes:     1,
+		},
+		{
+			name:   "event of another tenant",
+			tenant: "shop-b",
+			passes: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.tenant == "" {
+				tt.tenant = "shop-a"
+			}
+			rc := &receiver{failures: tt.failures}
+			server := httptest.NewServer(rc)
+			defer server.Close()
+
+			store := &memoryStore{
+				subscriptions: []Subscription{
+					{ID: 1, TenantID: tt.tenant, URL: server.URL, Secret: "secret", EventTypes: tt.eventTypes, Active: true},
+				},
+			}
+
+			now := time.Now()
+			dispatcher := NewDispatcher(store, Options{MaxAttempts: tt.maxAttempts, BaseBackoff: time.Second})
+			dispatcher.now = func() time.Time { return now }
+
+			err := dispatcher.Enqueue(ctx, events.Event{ID: 7, Tenant: "shop-a", Type: events.TypeCreated, ProductID: 42})
+			require.NoError(t, err)
+
+			for i := 0; i < tt.passes; i++ {
+				require.NoError(t, dispatcher.DeliverDue(ctx))
+				// переводим часы, чтобы созрела следующая попытка
+				now = now.Add(time.Hour)
+			}
+
+			require.Empty(t, rc.errors)
+			require.Len(t, rc.received, tt.wantDelivered)
+			if tt.wantDelivered > 0 {
+				require.Equal(t, 42, rc.received[0].ProductID)
+			}
+
+			if tt.wantStatus == "" {
+				require.Empty(t, store.deliveries)
+				return
+			}
+			require.Len(t, store.deliveries, 1)
+			require.Equal(t, tt.wantStatus, store.deliveries[0].Status)
+			require.Equal(t, tt.wantAttempts, store.deliveries[0].Attempts)
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name    string
+		header  string
+		wantErr error
+	}{
+		{
+			name:   "successfully verify signature",
+			header: Sign("secret", now, body),
+		},
+		{
+			name:    "failed verify signature with another secret",
+			header:  Sign("another", now, body),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "failed verify old signature",
+			header:  Sign("secret", now-3600, body),
+			wantErr: ErrSignatureTooOld,
+		},
+		{
+			name:    "failed verify malformed header",
+			header:  "v1=abc",
+			wantErr: ErrMalformedSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify("secret", tt.header, body, time.Minute*5)
+			require.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestDispatcher_TraceParent(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	ctx := context.Background()
+
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	store := &memoryStore{
+		subscriptions: []Subscription{
+			{ID: 1, TenantID: "shop-a", URL: server.URL, Secret: "secret", Active: true},
+		},
+	}
+	dispatcher := NewDispatcher(store, Options{})
+
+	const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	err := dispatcher.Enqueue(ctx, events.Event{ID: 1, Tenant: "shop-a", Type: events.TypeCreated, ProductID: 42, TraceParent: traceParent})
+	require.NoError(t, err)
+	require.NoError(t, dispatcher.DeliverDue(ctx))
+
+	// трасса события продолжается в запросе к подписчику
+	require.Len(t, rc.headers, 1)
+	require.Contains(t, rc.headers[0].Get("traceparent"), "4bf92f3577b34da6a3ce929d0e0e4736")
+}
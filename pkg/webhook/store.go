@@ -0,0 +1,263 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	builder "github.com/doug-martin/goqu/v9"
+
+	"github.com/grip211/crud/pkg/database"
+	"github.com/grip211/crud/pkg/tenant"
+)
+
+// тут храним подписки и очередь доставок в таблицах WebhookSubscriptions и WebhookDeliveries
+
+// SubscriptionStore управление подписками арендатора из ctx, им пользуются обработчики API
+type SubscriptionStore interface {
+	CreateSubscription(ctx context.Context, subscription *Subscription) (int, error)
+	Subscriptions(ctx context.Context) ([]Subscription, error)
+	DeleteSubscription(ctx context.Context, id int) error
+	Deliveries(ctx context.Context, subscriptionID, limit int) ([]Delivery, error)
+}
+
+type Store interface {
+	ActiveSubscriptions(ctx context.Context) ([]Subscription, error)
+	// EnqueueDeliveries пропускает доставки, которые уже стоят в очереди (та же подписка и то же событие):
+	// relay может передать одно событие несколько раз
+	EnqueueDeliveries(ctx context.Context, deliveries []Delivery) error
+	// DueDeliveries возвращает доставки в статусе pending, время попытки которых уже наступило
+	DueDeliveries(ctx context.Context, now time.Time, limit int) ([]Delivery, error)
+	Target(ctx context.Context, subscriptionID int) (*Target, error)
+	SaveAttempt(ctx context.Context, delivery *Delivery) error
+}
+
+type Repo struct {
+	db database.Pool
+}
+
+func NewRepo(db database.Pool) *Repo {
+	return &Repo{
+		db: db,
+	}
+}
+
+type subscriptionRow struct {
+	ID         int       `db:"id"`
+	TenantID   string    `db:"tenant_id"`
+	URL        string    `db:"url"`
+	EventTypes string    `db:"event_types"`
+	Secret     string    `db:"secret"`
+	Active     bool      `db:"active"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+func (r subscriptionRow) subscription() Subscription {
+	var eventTypes []string
+	if r.EventTypes != "" {
+		eventTypes = strings.Split(r.EventTypes, ",")
+	}
+	return Subscription{
+		ID:         r.ID,
+		TenantID:   r.TenantID,
+		URL:        r.URL,
+		EventTypes: eventTypes,
+		Secret:     r.Secret,
+		Active:     r.Active,
+		CreatedAt:  r.CreatedAt,
+	}
+}
+
+// CreateSubscription создает подписку арендатора из ctx
+func (r *Repo) CreateSubscription(ctx context.Context, subscription *Subscription) (int, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return 0, err
+	}
+	subscription.TenantID = tenantID
+
+	id, err := database.InsertID(ctx, r.db.Builder().
+		Insert(database.Table(r.db, "WebhookSubscriptions")).
+		Rows(builder.Record{
+			"tenant_id":   tenantID,
+			"url":         subscription.URL,
+			"event_types": strings.Join(subscription.EventTypes, ","),
+			"secret":      subscription.Secret,
+			"active":      true,
+		}))
+	if err != nil {
+		return 0, fmt.Errorf("insert webhook subscription: %w", err)
+	}
+	return int(id), nil
+}
+
+// Subscriptions подписки арендатора из ctx
+func (r *Repo) Subscriptions(ctx context.Context) ([]Subscription, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.subscriptions(ctx, builder.C("tenant_id").Eq(tenantID))
+}
+
+// ActiveSubscriptions активные подписки всех арендаторов для диспетчера, события своего арендатора
+// подписка отбирает сама, см. Subscription.Accepts
+func (r *Repo) ActiveSubscriptions(ctx context.Context) ([]Subscription, error) {
+	return r.subscriptions(ctx, builder.C("active").IsTrue())
+}
+
+func (r *Repo) subscriptions(ctx context.Context, where builder.Expression) ([]Subscription, error) {
+	var rows []subscriptionRow
+	err := r.db.Builder().
+		From(database.Table(r.db, "WebhookSubscriptions")).
+		Where(where).
+		Order(builder.C("id").Asc()).
+		ScanStructsContext(ctx, &rows)
+	if err != nil {
+		return nil, fmt.Errorf("fetch webhook subscriptions: %w", err)
+	}
+
+	subscriptions := make([]Subscription, 0, len(rows))
+	for _, row := range rows {
+		subscriptions = append(subscriptions, row.subscription())
+	}
+	return subscriptions, nil
+}
+
+func (r *Repo) DeleteSubscription(ctx context.Context, id int) error {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	res, err := r.db.Builder().
+		Delete(database.Table(r.db, "WebhookSubscriptions")).
+		Where(
+			builder.C("id").Eq(id),
+			builder.C("tenant_id").Eq(tenantID),
+		).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("delete webhook subscription: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete webhook subscription: %w", err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *Repo) Target(ctx context.Context, subscriptionID int) (*Target, error) {
+	var row subscriptionRow
+	found, err := r.db.Builder().
+		From(database.Table(r.db, "WebhookSubscriptions")).
+		Where(builder.C("id").Eq(subscriptionID)).
+		ScanStructContext(ctx, &row)
+	if err != nil {
+		return nil, fmt.Errorf("fetch webhook subscription: %w", err)
+	}
+	if !found {
+		return nil, ErrNotFound
+	}
+	return &Target{URL: row.URL, Secret: row.Secret}, nil
+}
+
+func (r *Repo) EnqueueDeliveries(ctx context.Context, deliveries []Delivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	rows := make([]interface{}, 0, len(deliveries))
+	for i := range deliveries {
+		rows = append(rows, builder.Record{
+			"subscription_id": deliveries[i].SubscriptionID,
+			"event_id":        deliveries[i].EventID,
+			"event_type":      deliveries[i].EventType,
+			"payload":         deliveries[i].Payload,
+			"status":          StatusPending,
+			"next_attempt_at": deliveries[i].NextAttemptAt,
+		})
+	}
+
+	// повтор события отбрасывает уникальный ключ (subscription_id, event_id)
+	_, err := r.db.Builder().
+		Insert(database.Table(r.db, "WebhookDeliveries")).
+		Rows(rows...).
+		OnConflict(builder.DoNothing()).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("enqueue webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+func (r *Repo) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]Delivery, error) {
+	var deliveries []Delivery
+	err := r.db.Builder().
+		From(database.Table(r.db, "WebhookDeliveries")).
+		Where(
+			builder.C("status").Eq(StatusPending),
+			builder.C("next_attempt_at").Lte(now),
+		).
+		Order(builder.C("id").Asc()).
+		Limit(uint(limit)).
+		ScanStructsContext(ctx, &deliveries)
+	if err != nil {
+		return nil, fmt.Errorf("fetch due webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+func (r *Repo) SaveAttempt(ctx context.Context, delivery *Delivery) error {
+	_, err := r.db.Builder().
+		Update(database.Table(r.db, "WebhookDeliveries")).
+		Set(builder.Record{
+			"status":           delivery.Status,
+			"attempts":         delivery.Attempts,
+			"next_attempt_at":  delivery.NextAttemptAt,
+			"last_status_code": delivery.LastStatusCode,
+			"last_error":       delivery.LastError,
+			"delivered_at":     delivery.DeliveredAt,
+		}).
+		Where(builder.C("id").Eq(delivery.ID)).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("save webhook delivery attempt: %w", err)
+	}
+	return nil
+}
+
+// Deliveries журнал доставок подписки арендатора из ctx, последние сверху
+func (r *Repo) Deliveries(ctx context.Context, subscriptionID, limit int) ([]Delivery, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var deliveries []Delivery
+	err = r.db.Builder().
+		From(database.Table(r.db, "WebhookDeliveries")).
+		Where(builder.C("subscription_id").In(
+			r.db.Builder().
+				From(database.Table(r.db, "WebhookSubscriptions")).
+				Select("id").
+				Where(
+					builder.C("id").Eq(subscriptionID),
+					builder.C("tenant_id").Eq(tenantID),
+				),
+		)).
+		Order(builder.C("id").Desc()).
+		Limit(uint(limit)).
+		ScanStructsContext(ctx, &deliveries)
+	if err != nil {
+		return nil, fmt.Errorf("fetch webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
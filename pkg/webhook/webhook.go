@@ -0,0 +1,140 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grip211/crud/pkg/events"
+)
+
+// тут описываем подписки на вебхуки, доставки и подпись запросов
+
+const (
+	HeaderSignature = "X-Webhook-Signature"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+var (
+	ErrNotFound           = errors.New("webhook subscription not found")
+	ErrInvalidURL         = errors.New("webhook url must be an absolute http(s) url")
+	ErrInvalidSignature   = errors.New("invalid webhook signature")
+	ErrSignatureTooOld    = errors.New("webhook signature timestamp is outside of tolerance")
+	ErrMalformedSignature = errors.New("malformed webhook signature header")
+)
+
+type Subscription struct {
+	ID       int    `json:"id"`
+	TenantID string `json:"tenant"`
+	URL      string `json:"url"`
+	// EventTypes типы событий, на которые подписан получатель, пустой список значит все события
+	EventTypes []string  `json:"event_types"`
+	Secret     string    `json:"secret,omitempty"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Accepts подписка получает только события своего арендатора и только подписанных типов
+func (s *Subscription) Accepts(event *events.Event) bool {
+	if s.TenantID != event.Tenant {
+		return false
+	}
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range s.EventTypes {
+		if t == event.Type {
+			return true
+		}
+	}
+	return false
+}
+
+type Delivery struct {
+	ID             int64      `db:"id" json:"id"`
+	SubscriptionID int        `db:"subscription_id" json:"subscription_id"`
+	EventID        uint64     `db:"event_id" json:"event_id"`
+	EventType      string     `db:"event_type" json:"event_type"`
+	Payload        []byte     `db:"payload" json:"-"`
+	Status         string     `db:"status" json:"status"`
+	Attempts       int        `db:"attempts" json:"attempts"`
+	NextAttemptAt  time.Time  `db:"next_attempt_at" json:"next_attempt_at"`
+	LastStatusCode int        `db:"last_status_code" json:"last_status_code,omitempty"`
+	LastError      string     `db:"last_error" json:"last_error,omitempty"`
+	CreatedAt      time.Time  `db:"created_at" json:"created_at"`
+	DeliveredAt    *time.Time `db:"delivered_at" json:"delivered_at,omitempty"`
+}
+
+// Target получатель доставки, подтягивается из подписки
+type Target struct {
+	URL    string
+	Secret string
+}
+
+// NewSecret генерирует случайный секрет для подписи
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign подписывает тело запроса: HMAC-SHA256 от "timestamp.body",
+// результат отправляется в заголовке X-Webhook-Signature в виде "t=<timestamp>,v1=<hex>"
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// Verify проверяет заголовок X-Webhook-Signature, пригодится получателям вебхуков
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var timestamp int64
+	var signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return ErrMalformedSignature
+		}
+		switch key {
+		case "t":
+			ts, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return ErrMalformedSignature
+			}
+			timestamp = ts
+		case "v1":
+			signature = value
+		}
+	}
+	if timestamp == 0 || signature == "" {
+		return ErrMalformedSignature
+	}
+
+	if tolerance > 0 {
+		age := time.Since(time.Unix(timestamp, 0))
+		if age > tolerance || age < -tolerance {
+			return ErrSignatureTooOld
+		}
+	}
+
+	expected := Sign(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(fmt.Sprintf("t=%d,v1=%s", timestamp, signature))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}